@@ -0,0 +1,151 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 17:42:26
+ * @LastEditTime: 2026-10-18 17:42:26
+ * @FilePath: \CloudDisk\business\accesskey.go
+ * @Description: S3访问密钥接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+)
+
+/**
+ * @description: 生成指定长度的随机字符串
+ * @param {int} n 长度
+ * @param {string} alphabet 字符集
+ * @return {string} 随机字符串
+ */
+func randomString(n int, alphabet string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = alphabet[int(buf[i])%len(alphabet)]
+	}
+	return string(buf), nil
+}
+
+/**
+ * @description: 创建访问密钥api，密钥只在创建时返回一次
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func CreateAccessKey(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 生成密钥
+	accessKeyID, err := randomString(18, "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessKeyID = "CD" + accessKeyID
+	secretAccessKey, err := randomString(40, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 保存密钥
+	if err := dbwrapper.CreateAccessKey(accessKeyID, secretAccessKey, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessKey, err := dbwrapper.QueryAccessKey(accessKeyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accessKey)
+}
+
+/**
+ * @description: 删除访问密钥api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func DeleteAccessKey(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	type DeleteAccessKeyRequest struct {
+		AccessKeyID string `json:"accessKeyId"`
+	}
+	var req DeleteAccessKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 删除密钥
+	if err := dbwrapper.DeleteAccessKey(req.AccessKeyID, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Access key deleted successfully"))
+}
+
+/**
+ * @description: 查询访问密钥列表api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryAccessKeys(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 查询密钥
+	keys, err := dbwrapper.QueryUserAccessKeys(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
@@ -0,0 +1,109 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 14:55:21
+ * @LastEditTime: 2026-10-19 10:03:18
+ * @FilePath: \CloudDisk\business\auth.go
+ * @Description: 用户认证
+ */
+package business
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/logwrapper"
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 匿名用户名，未配置用户或请求未携带凭据时使用
+const anonymousUser = ""
+
+/**
+ * @description: 校验用户名和密码
+ * @param {string} name 用户名
+ * @param {string} password 密码
+ * @return {bool} 是否通过
+ */
+func checkUserPassword(name string, password string) bool {
+	for _, user := range configwrapper.Cfg.Users {
+		if user.Name == name && subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @description: 校验用户的SSH公钥是否在配置的authorizedKeys中
+ * @param {string} name 用户名
+ * @param {ssh.PublicKey} key 客户端公钥
+ * @return {bool} 是否通过
+ */
+func checkUserPublicKey(name string, key ssh.PublicKey) bool {
+	for _, user := range configwrapper.Cfg.Users {
+		if user.Name != name {
+			continue
+		}
+		for _, line := range user.AuthorizedKeys {
+			authorizedKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+			if err != nil {
+				logwrapper.Logger.Warnf("Invalid authorized key for user %s: %v", name, err)
+				continue
+			}
+			if bytes.Equal(authorizedKey.Marshal(), key.Marshal()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/**
+ * @description: 获取请求对应的用户，使用HTTP Basic认证，未配置用户时返回匿名用户
+ * @param {*http.Request} r
+ * @return {string} 用户名
+ * @return {bool} 是否认证通过
+ */
+func currentUser(r *http.Request) (string, bool) {
+	if len(configwrapper.Cfg.Users) == 0 {
+		return anonymousUser, true
+	}
+
+	name, password, ok := r.BasicAuth()
+	if !ok || !checkUserPassword(name, password) {
+		return anonymousUser, false
+	}
+	return name, true
+}
+
+/**
+ * @description: 获取请求对应的用户，认证失败时写入401响应
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {string} 用户名
+ * @return {bool} 是否认证通过
+ */
+func requireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, ok := currentUser(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="CloudDisk", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+	return user, ok
+}
+
+/**
+ * @description: 包装处理函数，认证失败时返回401，未配置用户时直接放行
+ * @param {http.Handler} handler
+ * @return {http.Handler}
+ */
+func WithAuth(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireUser(w, r); !ok {
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
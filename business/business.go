@@ -1,7 +1,7 @@
 /*
  * @Author: shanghanjin
  * @Date: 2024-12-24 10:20:05
- * @LastEditTime: 2024-12-25 16:15:14
+ * @LastEditTime: 2026-10-19 16:48:20
  * @FilePath: \CloudDisk\business\business.go
  * @Description: 业务封装
  */
@@ -12,7 +12,6 @@ import (
 	"CloudDisk/dbwrapper"
 	"CloudDisk/logwrapper"
 	"encoding/json"
-	"io"
 	"net/http"
 	"os"
 	"path"
@@ -35,7 +34,15 @@ func QueryFolder(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	type QueryFolderRequest struct {
-		FolderID int64 `json:"folderID"`
+		FolderID int64  `json:"folderID"`
+		Path     string `json:"path"`   // 文件夹路径，可代替folderID
+		SortBy   string `json:"sortBy"` // name(默认)/size/createdAt/updatedAt/type
+		Order    string `json:"order"`  // asc(默认)/desc
+		Cursor   string `json:"cursor"` // 上一页返回的nextCursor
+		Limit    int    `json:"limit"`  // 每页条数，为0时返回全部
+
+		Tags     []string          `json:"tags"`     // 只返回包含全部标签的条目
+		Metadata map[string]string `json:"metadata"` // 只返回匹配全部元数据的条目
 	}
 	var req QueryFolderRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -44,10 +51,33 @@ func QueryFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 检查参数
+	if req.Order != "" && req.Order != "asc" && req.Order != "desc" {
+		http.Error(w, "Invalid order", http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 || req.Limit > 1000 {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	folderID, ok := requestFolderID(w, req.FolderID, req.Path)
+	if !ok {
+		return
+	}
+
 	// 查询文件夹信息
-	queryResult, err := dbwrapper.QueryFolderInfoFull(req.FolderID)
+	queryResult, err := dbwrapper.QueryFolderInfoFull(folderID, dbwrapper.ListOptions{
+		SortBy: req.SortBy,
+		Desc:   req.Order == "desc",
+		Cursor: req.Cursor,
+		Limit:  req.Limit,
+
+		Tags:     req.Tags,
+		Metadata: req.Metadata,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
@@ -73,6 +103,8 @@ func CreateFolder(w http.ResponseWriter, r *http.Request) {
 	type CreateFolderRequest struct {
 		FolderName     string `json:"folderName"`
 		ParentFolderID int64  `json:"parentFolderID"`
+		Path           string `json:"path"`       // 新文件夹的完整路径，可代替folderName和parentFolderID
+		OnConflict     string `json:"onConflict"` // 名称冲突时的处理方式：fail(默认)/rename/overwrite/skip，overwrite和skip返回已有的文件夹
 	}
 	var req CreateFolderRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -81,76 +113,32 @@ func CreateFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 查询父文件夹路径
-	parentFolderPath, err := dbwrapper.QueryFolderPath(req.ParentFolderID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// 拼接路径
-	relativePath := path.Join(parentFolderPath, req.FolderName)
-	localFullPath := path.Join(GetBaseFolderPath(), relativePath)
-
-	// 检查文件夹是否在本地存在
-	_, err = os.Stat(localFullPath)
-	if os.IsExist(err) {
-		// 检测文件夹是否在数据库中存在
-		if exists, err := dbwrapper.FolderExistByPath(relativePath); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		} else if exists {
-			// 如果在数据库中也存在，则返回错误
-			http.Error(w, "Folder already exists", http.StatusInternalServerError)
+	if req.Path != "" {
+		if req.FolderName != "" || req.ParentFolderID != 0 {
+			http.Error(w, "Specify either an ID or a path, not both", http.StatusBadRequest)
 			return
 		}
-
-		// 如果在数据库中不存在，则尝试删除本地文件夹
-		err = os.RemoveAll(localFullPath)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		var ok bool
+		if req.ParentFolderID, req.FolderName, ok = requestParentByPath(w, req.Path); !ok {
 			return
 		}
 	}
 
-	// 创建本地文件夹
-	err = os.MkdirAll(localFullPath, os.ModePerm)
+	policy, err := parseConflictPolicy(req.OnConflict)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// 如果后续操作没有正常完成，则删除已创建的本地文件夹
-	var operationSuc bool = false
-	defer func() {
-		if !operationSuc {
-			// 删除文件夹及其内容
-			removeErr := os.RemoveAll(localFullPath)
-			if removeErr != nil {
-				// 删除失败时记录日志
-				logwrapper.Logger.Errorf("Failed to clean up folder %s: %v", localFullPath, removeErr)
-			}
-		}
-	}()
-
-	// 数据库新建文件夹
-	folderID, err := dbwrapper.CreateFolder(req.FolderName, req.ParentFolderID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 查询文件夹信息
-	folderInfo, err := dbwrapper.QueryFolderInfo(folderID)
+	// 新建文件夹
+	folderInfo, created, err := createFolderWithPolicy(req.ParentFolderID, req.FolderName, policy)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
-	// 标记操作成功
-	operationSuc = true
-
 	// 结果写入响应体
+	setConflictSkipped(w, policy == conflictSkip && !created)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(folderInfo)
 }
@@ -176,107 +164,51 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// 获取父文件夹id字段
+	// 获取父文件夹id字段，也可以用path字段指定文件的完整路径
 	parentFolderIDStr := r.FormValue("parentFolderID")
-	if parentFolderIDStr == "" {
-		http.Error(w, "parentFolderID is required", http.StatusBadRequest)
-		return
-	}
-
-	parentFolderID, err := strconv.ParseInt(parentFolderIDStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid parentFolderID", http.StatusBadRequest)
-		return
-	}
-
-	// 获取文件大小字段
-	fileSizeStr := r.FormValue("fileSize")
-	if fileSizeStr == "" {
-		http.Error(w, "fileSize is required", http.StatusBadRequest)
-		return
-	}
-
-	fileSize, err := strconv.ParseInt(fileSizeStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid fileSize", http.StatusBadRequest)
-		return
-	}
-
-	// 查询父文件夹路径
-	parentFolderPath, err := dbwrapper.QueryFolderPath(parentFolderID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// 拼接写入路径
-	relativePath := path.Join(parentFolderPath, handler.Filename) // path不会自动转换路径分隔符
-	localFullPath := path.Join(GetBaseFolderPath(), relativePath) // filepath会自动转换路径分隔符
-	MkPathParentFolder(localFullPath)
-
-	// 检查文件是否在本地存在
-	if _, err := os.Stat(localFullPath); err == nil {
-		// 检测文件是否在数据库中存在
-		if exists, err := dbwrapper.FileExistByPath(relativePath); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		} else if exists {
-			// 如果在数据库中也存在，则返回错误
-			http.Error(w, "File already exists", http.StatusInternalServerError)
+	filePath := r.FormValue("path")
+	fileName := handler.Filename
+	var parentFolderID int64
+	switch {
+	case filePath != "" && parentFolderIDStr != "":
+		http.Error(w, "Specify either an ID or a path, not both", http.StatusBadRequest)
+		return
+	case filePath != "":
+		var ok bool
+		if parentFolderID, fileName, ok = requestParentByPath(w, filePath); !ok {
 			return
 		}
-
-		// 如果在数据库中不存在，则尝试删除本地文件
-		err = RemoveFileIgnoreNotExist(localFullPath)
+	case parentFolderIDStr == "":
+		http.Error(w, "parentFolderID is required", http.StatusBadRequest)
+		return
+	default:
+		parentFolderID, err = strconv.ParseInt(parentFolderIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Invalid parentFolderID", http.StatusBadRequest)
 			return
 		}
 	}
 
-	// 标记操作是否成功，如果后续操作没有成功，则删除本地文件
-	var operationSuc bool
-	defer func() {
-		if !operationSuc {
-			// 删除文件
-			removeErr := RemoveFileIgnoreNotExist(localFullPath)
-			if removeErr != nil {
-				// 删除失败时记录日志
-				logwrapper.Logger.Errorf("Failed to clean up file %s: %v", localFullPath, removeErr)
-			}
-		}
-	}()
-
-	// 创建本地文件
-	fileWrite, err := os.Create(localFullPath)
+	// 名称冲突时的处理方式
+	policy, err := parseConflictPolicy(r.FormValue("onConflict"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer fileWrite.Close()
-
-	// 将上传的文件内容写入本地文件
-	if _, err := io.Copy(fileWrite, file); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 写入数据库
-	fileID, err := dbwrapper.CreateFile(handler.Filename, fileSize, parentFolderID)
+	// 写入本地文件和数据库，文件大小以实际写入的字节数为准
+	fileInfo, created, err := saveFileWithPolicy(parentFolderID, fileName, file, policy)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
-
-	// 查询文件信息
-	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if policy == conflictSkip && !created {
+		setConflictSkipped(w, true)
+	} else {
+		// 记录最近访问
+		recordRecent(r, fileInfo.ID, "upload")
 	}
 
-	operationSuc = true
-
 	// 写入响应
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(fileInfo)
@@ -299,6 +231,8 @@ func RenameFolder(w http.ResponseWriter, r *http.Request) {
 	type RenameFolderRequest struct {
 		FolderName string `json:"folderName"`
 		FolderID   int64  `json:"folderID"`
+		Path       string `json:"path"`       // 文件夹路径，可代替folderID
+		OnConflict string `json:"onConflict"` // 名称冲突时的处理方式：fail(默认)/rename/overwrite/skip
 	}
 	var req RenameFolderRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -307,50 +241,36 @@ func RenameFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var ok bool
+	if req.FolderID, ok = requestFolderID(w, req.FolderID, req.Path); !ok {
+		return
+	}
+
 	// root文件夹无法重命名
 	if req.FolderID == 1 {
 		http.Error(w, "Cannot rename root folder", http.StatusBadRequest)
 		return
 	}
 
-	// 查询父文件夹路径
-	parentFolderPath, err := dbwrapper.QueryFolderPath(req.FolderID)
+	policy, err := parseConflictPolicy(req.OnConflict)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 重命名文件夹
-	var oldPath = path.Join(GetBaseFolderPath(), parentFolderPath)
-	var newPath = path.Join(path.Dir(oldPath), req.FolderName)
-	err = os.Rename(oldPath, newPath)
+	// 重命名本地文件夹和数据库中的文件夹
+	skipped, err := renameFolder(req.FolderID, req.FolderName, policy)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
-	// 如果后续操作没有正常完成，则回滚重命名操作
-	var operationSuc bool
-	defer func() {
-		if !operationSuc {
-			// 回滚重命名操作
-			err := os.Rename(newPath, oldPath)
-			if err != nil {
-				logwrapper.Logger.Errorf("Failed to rollback folder rename: %v", err)
-			}
-		}
-	}()
-
-	// 更新数据库中的文件夹名称
-	err = dbwrapper.RenameFolder(req.FolderID, req.FolderName)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// 返回成功信息，因名称冲突跳过时文件夹保持原名
+	if skipped {
+		setConflictSkipped(w, true)
+		w.Write([]byte("Folder rename skipped: name already exists"))
 		return
 	}
-
-	operationSuc = true
-
-	// 返回成功信息
 	w.Write([]byte("Folder renamed successfully"))
 }
 
@@ -369,8 +289,10 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	type RenameFileRequest struct {
-		FileName string `json:"fileName"`
-		FileID   int64  `json:"fileID"`
+		FileName   string `json:"fileName"`
+		FileID     int64  `json:"fileID"`
+		Path       string `json:"path"`       // 文件路径，可代替fileID
+		OnConflict string `json:"onConflict"` // 名称冲突时的处理方式：fail(默认)/rename/overwrite/skip
 	}
 	var req RenameFileRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -379,44 +301,30 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 查询文件信息
-	file, err := dbwrapper.QueryFileInfo(req.FileID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var ok bool
+	if req.FileID, ok = requestFileID(w, req.FileID, req.Path); !ok {
 		return
 	}
 
-	// 重命名文件
-	var oldPath = path.Join(GetBaseFolderPath(), file.Path)
-	var newPath = path.Join(path.Dir(oldPath), req.FileName)
-	err = os.Rename(oldPath, newPath)
+	policy, err := parseConflictPolicy(req.OnConflict)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 如果后续操作没有正常完成，则回滚重命名操作
-	var operationSuc bool
-	defer func() {
-		if !operationSuc {
-			// 回滚重命名操作
-			err := os.Rename(newPath, oldPath)
-			if err != nil {
-				logwrapper.Logger.Errorf("Failed to rollback file rename: %v", err)
-			}
-		}
-	}()
-
-	// 更新数据库中的文件名称
-	err = dbwrapper.RenameFile(req.FileID, req.FileName)
+	// 重命名本地文件和数据库中的文件
+	skipped, err := renameFile(req.FileID, req.FileName, policy)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
-	operationSuc = true
-
-	// 返回成功信息
+	// 返回成功信息，因名称冲突跳过时文件保持原名
+	if skipped {
+		setConflictSkipped(w, true)
+		w.Write([]byte("File rename skipped: name already exists"))
+		return
+	}
 	w.Write([]byte("File renamed successfully"))
 }
 
@@ -435,7 +343,8 @@ func DeleteFolder(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	type DeleteFolderRequest struct {
-		FolderID int64 `json:"folderID"`
+		FolderID int64  `json:"folderID"`
+		Path     string `json:"path"` // 文件夹路径，可代替folderID
 	}
 	var req DeleteFolderRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -444,31 +353,21 @@ func DeleteFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 根文件夹不允许删除
-	if req.FolderID == 1 {
-		http.Error(w, "Cannot delete root folder", http.StatusBadRequest)
-		return
-	}
-
-	// 查询文件夹信息
-	folder, err := dbwrapper.QueryFolderInfo(req.FolderID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var ok bool
+	if req.FolderID, ok = requestFolderID(w, req.FolderID, req.Path); !ok {
 		return
 	}
 
-	// 删除本地文件夹
-	var localPath = path.Join(GetBaseFolderPath(), folder.Path)
-	err = os.RemoveAll(localPath) // 路径不存在时，os.RemoveAll也会返回nil
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// 根文件夹不允许删除
+	if req.FolderID == 1 {
+		http.Error(w, "Cannot delete root folder", http.StatusBadRequest)
 		return
 	}
 
-	// 删除数据库中的文件夹
-	err = dbwrapper.DeleteFolder(req.FolderID)
+	// 删除本地文件夹和数据库中的文件夹
+	err = deleteFolder(req.FolderID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
@@ -491,7 +390,8 @@ func DeleteFile(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	type DeleteFileRequest struct {
-		FileID int64 `json:"fileID"`
+		FileID int64  `json:"fileID"`
+		Path   string `json:"path"` // 文件路径，可代替fileID
 	}
 	var req DeleteFileRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -500,25 +400,15 @@ func DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 查询文件信息
-	fileInfo, err := dbwrapper.QueryFileInfo(req.FileID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var ok bool
+	if req.FileID, ok = requestFileID(w, req.FileID, req.Path); !ok {
 		return
 	}
 
-	// 删除本地文件
-	var localPath = path.Join(GetBaseFolderPath(), fileInfo.Path)
-	err = RemoveFileIgnoreNotExist(localPath)
+	// 删除本地文件和数据库中的文件
+	err = deleteFile(req.FileID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// 删除数据库中的文件
-	err = dbwrapper.DeleteFile(req.FileID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
@@ -541,7 +431,8 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	type DownloadFileRequest struct {
-		FileID int64 `json:"fileID"`
+		FileID int64  `json:"fileID"`
+		Path   string `json:"path"` // 文件路径，可代替fileID
 	}
 	var req DownloadFileRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -550,16 +441,24 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var ok bool
+	if req.FileID, ok = requestFileID(w, req.FileID, req.Path); !ok {
+		return
+	}
+
 	// 查询文件信息
 	fileInfo, err := dbwrapper.QueryFileInfo(req.FileID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), operationStatus(err))
 		return
 	}
 
+	// 记录最近访问
+	recordRecent(r, fileInfo.ID, "download")
+
 	// 提供下载文件响应
 	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
-	w.Header().Set("Content-Disposition", "attachment; filename="+fileInfo.Name)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", fileInfo.Name))
 	w.Header().Set("Content-Type", "application/octet-stream")
 	http.ServeFile(w, r, path.Join(GetBaseFolderPath(), fileInfo.Path))
 }
@@ -580,6 +479,25 @@ func GetBaseFolderPath() string {
 	return baseFolderPath
 }
 
+/**
+ * @description: 获取缓存文件夹的本地路径
+ * @return {string} 路径
+ */
+func GetCacheFolderPath() string {
+	exePath, err := os.Executable()
+	if err != nil {
+		logwrapper.Logger.Fatal(err)
+	}
+
+	exePath = strings.ReplaceAll(exePath, "\\", "/")
+	exeFolder := path.Dir(exePath)
+	cacheFolder := configwrapper.Cfg.Local.CacheFolder
+	if cacheFolder == "" {
+		cacheFolder = "cache"
+	}
+	return path.Join(exeFolder, cacheFolder)
+}
+
 /**
  * @description: 创建父文件夹
  * @param {string} pathStr
@@ -0,0 +1,88 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 22:10:45
+ * @LastEditTime: 2026-10-19 10:11:40
+ * @FilePath: \CloudDisk\business\change.go
+ * @Description: 变更日志接口，供同步客户端和缓存失效使用
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// 每次最多返回的变更数
+const maxChangesLimit = 1000
+
+/**
+ * @description: 查询变更api，GET /api/changes?cursor=&limit=
+ * 不带cursor时只返回当前游标，客户端应先完整查询一次，再从该游标开始增量获取
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryChanges(w http.ResponseWriter, r *http.Request) {
+	// 只支持GET请求
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+	if _, ok := requireUser(w, r); !ok {
+		return
+	}
+
+	// 检查参数
+	limit := maxChangesLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 || limit > maxChangesLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	type QueryChangesResponse struct {
+		Changes []dbwrapper.Change `json:"changes"`
+		Cursor  string             `json:"cursor"`  // 下次请求使用的游标
+		HasMore bool               `json:"hasMore"` // 是否还有未返回的变更
+	}
+	resp := QueryChangesResponse{Changes: []dbwrapper.Change{}}
+
+	cursorStr := r.URL.Query().Get("cursor")
+	if cursorStr == "" {
+		latest, err := dbwrapper.LatestChangeID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Cursor = strconv.FormatInt(latest, 10)
+	} else {
+		cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil || cursor < 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+
+		// 多查一条用于判断是否还有更多
+		changes, err := dbwrapper.QueryChanges(cursor, limit+1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(changes) > limit {
+			changes, resp.HasMore = changes[:limit], true
+		}
+		resp.Changes = changes
+		if len(changes) > 0 {
+			cursor = changes[len(changes)-1].ID
+		}
+		resp.Cursor = strconv.FormatInt(cursor, 10)
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,139 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 04:55:27
+ * @LastEditTime: 2026-10-19 11:37:05
+ * @FilePath: \CloudDisk\business\conflict.go
+ * @Description: 新建、上传和移动时名称冲突的处理方式
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"unicode/utf8"
+)
+
+// 名称冲突时的处理方式
+type conflictPolicy string
+
+const (
+	conflictFail      conflictPolicy = "fail"      // 返回409，默认
+	conflictRename    conflictPolicy = "rename"    // 自动改名为"name (1).ext"
+	conflictOverwrite conflictPolicy = "overwrite" // 替换同名的文件或文件夹，新建文件夹时使用已有的同名文件夹
+	conflictSkip      conflictPolicy = "skip"      // 不做修改，新建时返回已有的同名项
+)
+
+var errInvalidConflictPolicy = errors.New("onConflict must be one of fail, rename, overwrite, skip")
+
+// 自动改名的最多尝试次数
+const maxConflictRenames = 1000
+
+// 策略为skip且因名称冲突没有做任何修改时设置的响应头，值为true，响应中的条目是已有的同名项或未修改的条目
+const conflictSkippedHeader = "X-Conflict-Skipped"
+
+/**
+ * @description: 因名称冲突跳过时设置响应头
+ * @param {http.ResponseWriter} w
+ * @param {bool} skipped 是否跳过
+ * @return {*}
+ */
+func setConflictSkipped(w http.ResponseWriter, skipped bool) {
+	if skipped {
+		w.Header().Set(conflictSkippedHeader, "true")
+	}
+}
+
+/**
+ * @description: 解析请求中的冲突处理方式
+ * @param {string} value 请求参数，为空时为fail
+ * @return {conflictPolicy}
+ */
+func parseConflictPolicy(value string) (conflictPolicy, error) {
+	switch policy := conflictPolicy(value); policy {
+	case "":
+		return conflictFail, nil
+	case conflictFail, conflictRename, conflictOverwrite, conflictSkip:
+		return policy, nil
+	}
+	return "", errInvalidConflictPolicy
+}
+
+/**
+ * @description: 判断是否为名称冲突的错误
+ * @param {error} err
+ * @return {bool}
+ */
+func isNameConflict(err error) bool {
+	return err == dbwrapper.ErrFolderExist || err == dbwrapper.ErrFileExist
+}
+
+/**
+ * @description: 查找父文件夹下占用名称的文件夹或文件，本地磁盘上文件和文件夹不能同名，按名称唯一性策略比较
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} name 名称
+ * @param {int64} excludeFolderID 不参与比较的文件夹ID，移动文件夹时为其自身
+ * @param {int64} excludeFileID 不参与比较的文件ID，移动文件时为其自身
+ * @return {int64} 占用名称的文件夹ID，没有时为0
+ * @return {int64} 占用名称的文件ID，没有时为0
+ */
+func findNameConflict(parentFolderID int64, name string, excludeFolderID int64, excludeFileID int64) (int64, int64, error) {
+	folderID, err := dbwrapper.QueryFolderIDByName(parentFolderID, name, excludeFolderID)
+	if err != nil || folderID != 0 {
+		return folderID, 0, err
+	}
+	fileID, err := dbwrapper.QueryFileIDByName(parentFolderID, name, excludeFileID)
+	return 0, fileID, err
+}
+
+/**
+ * @description: 名称冲突时返回的错误
+ * @param {int64} folderID 占用名称的文件夹ID，为0时表示被文件占用
+ * @return {error}
+ */
+func nameConflictError(folderID int64) error {
+	if folderID != 0 {
+		return dbwrapper.ErrFolderExist
+	}
+	return dbwrapper.ErrFileExist
+}
+
+/**
+ * @description: 生成第attempt次尝试使用的名称，第0次为原名称，之后为"name (n).ext"，文件夹不区分扩展名
+ * 超出名称长度限制时截短扩展名前的部分
+ * @param {string} name 原名称
+ * @param {int} attempt 尝试次数
+ * @param {bool} isFolder 是否为文件夹
+ * @return {string}
+ */
+func conflictCandidate(name string, attempt int, isFolder bool) (string, error) {
+	if attempt == 0 {
+		return name, nil
+	}
+	if attempt > maxConflictRenames {
+		if isFolder {
+			return "", dbwrapper.ErrFolderExist
+		}
+		return "", dbwrapper.ErrFileExist
+	}
+
+	base, ext := name, ""
+	if !isFolder {
+		if ext = path.Ext(name); ext == name {
+			// 以点开头且没有其他点的名称，如.gitignore，整体作为名称
+			ext = ""
+		}
+		base = name[:len(name)-len(ext)]
+	}
+	suffix := fmt.Sprintf(" (%d)", attempt)
+	if len(suffix)+len(ext) >= maxNameBytes {
+		base, ext = name, ""
+	}
+	for len(base)+len(suffix)+len(ext) > maxNameBytes {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	return base + suffix + ext, nil
+}
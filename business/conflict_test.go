@@ -0,0 +1,120 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 19:02:45
+ * @LastEditTime: 2026-10-19 19:02:45
+ * @FilePath: \CloudDisk\business\conflict_test.go
+ * @Description: 名称冲突处理的判断逻辑测试：冲突策略解析、自动改名和各接口的错误映射，不需要数据库
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	tests := []struct {
+		value string
+		want  conflictPolicy
+		err   error
+	}{
+		{"", conflictFail, nil},
+		{"fail", conflictFail, nil},
+		{"rename", conflictRename, nil},
+		{"overwrite", conflictOverwrite, nil},
+		{"skip", conflictSkip, nil},
+		{"Overwrite", "", errInvalidConflictPolicy},
+		{"replace", "", errInvalidConflictPolicy},
+	}
+	for _, tt := range tests {
+		if got, err := parseConflictPolicy(tt.value); got != tt.want || err != tt.err {
+			t.Errorf("parseConflictPolicy(%q) = %q, %v, want %q, %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestConflictCandidate(t *testing.T) {
+	long := strings.Repeat("a", maxNameBytes-4) + ".txt"
+	tests := []struct {
+		name     string
+		attempt  int
+		isFolder bool
+		want     string
+		err      error
+	}{
+		{"report.pdf", 0, false, "report.pdf", nil},
+		{"report.pdf", 1, false, "report (1).pdf", nil},
+		{"archive.tar.gz", 2, false, "archive.tar (2).gz", nil},
+		{".gitignore", 1, false, ".gitignore (1)", nil},
+		{"v1.0", 1, true, "v1.0 (1)", nil},
+		{"report.pdf", maxConflictRenames, false, fmt.Sprintf("report (%d).pdf", maxConflictRenames), nil},
+		{"report.pdf", maxConflictRenames + 1, false, "", dbwrapper.ErrFileExist},
+		{"docs", maxConflictRenames + 1, true, "", dbwrapper.ErrFolderExist},
+		// 超过名称长度上限时截短主名，保留扩展名
+		{long, 1, false, strings.Repeat("a", maxNameBytes-8) + " (1).txt", nil},
+		{strings.Repeat("文", 85), 1, true, strings.Repeat("文", 83) + " (1)", nil},
+	}
+	for _, tt := range tests {
+		got, err := conflictCandidate(tt.name, tt.attempt, tt.isFolder)
+		if got != tt.want || err != tt.err {
+			t.Errorf("conflictCandidate(%q, %d, %v) = %q, %v, want %q, %v", tt.name, tt.attempt, tt.isFolder, got, err, tt.want, tt.err)
+		}
+		if len(got) > maxNameBytes || !utf8.ValidString(got) {
+			t.Errorf("conflictCandidate(%q, %d) returned an invalid name of %d bytes", tt.name, tt.attempt, len(got))
+		}
+	}
+}
+
+func TestNameConflictError(t *testing.T) {
+	if err := nameConflictError(5); err != dbwrapper.ErrFolderExist {
+		t.Errorf("conflict with a folder: %v", err)
+	}
+	if err := nameConflictError(0); err != dbwrapper.ErrFileExist {
+		t.Errorf("conflict with a file: %v", err)
+	}
+}
+
+func TestOperationErrorMapping(t *testing.T) {
+	// v1接口、WebDAV/SFTP和gRPC对同一错误的映射
+	tests := []struct {
+		err    error
+		status int
+		osErr  error
+		code   codes.Code
+	}{
+		{dbwrapper.ErrFolderNotExist, http.StatusNotFound, os.ErrNotExist, codes.NotFound},
+		{dbwrapper.ErrFileNotExist, http.StatusNotFound, os.ErrNotExist, codes.NotFound},
+		{dbwrapper.ErrParentFolderNotExist, http.StatusNotFound, os.ErrNotExist, codes.NotFound},
+		{dbwrapper.ErrFolderExist, http.StatusConflict, os.ErrExist, codes.AlreadyExists},
+		{dbwrapper.ErrFileExist, http.StatusConflict, os.ErrExist, codes.AlreadyExists},
+		{dbwrapper.ErrMoveFolderIntoSelf, http.StatusConflict, os.ErrInvalid, codes.FailedPrecondition},
+		{errRootFolder, http.StatusForbidden, os.ErrPermission, codes.PermissionDenied},
+		{fmt.Errorf("%w: a/b", errInvalidName), http.StatusBadRequest, os.ErrInvalid, codes.InvalidArgument},
+		{errInvalidConflictPolicy, http.StatusBadRequest, errInvalidConflictPolicy, codes.InvalidArgument},
+		{dbwrapper.ErrInvalidCursor, http.StatusBadRequest, dbwrapper.ErrInvalidCursor, codes.InvalidArgument},
+		{errPreconditionFailed, http.StatusPreconditionFailed, errPreconditionFailed, codes.Internal},
+		{errors.New("disk full"), http.StatusInternalServerError, nil, codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := operationStatus(tt.err); got != tt.status {
+				t.Errorf("operationStatus() = %d, want %d", got, tt.status)
+			}
+			if got := osError(tt.err); tt.osErr != nil && !errors.Is(got, tt.osErr) {
+				t.Errorf("osError() = %v, want %v", got, tt.osErr)
+			}
+			if got := status.Code(grpcError(tt.err)); got != tt.code {
+				t.Errorf("grpcError() code = %v, want %v", got, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 14:05:12
+ * @LastEditTime: 2026-10-19 14:05:12
+ * @FilePath: \CloudDisk\business\davlock.go
+ * @Description: WebDAV锁系统，锁保存在数据库中，重启后保留并在多个实例之间共享
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"errors"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// 锁的最长时长，请求无限时长或更长时长时按此时长保存，客户端需在到期前刷新
+// webdav.Handler为没有If请求头的写请求创建临时的无限时长锁，实例在请求中途退出时这些锁最多保留这么久
+const davMaxLockDuration = time.Hour
+
+// 实现webdav.LockSystem，锁只约束WebDAV请求，JSON、S3、SFTP和gRPC接口不检查锁
+// 与webdav.NewMemLS不同，Confirm不标记锁为使用中，同一令牌可以同时用于多个请求
+type davLockSystem struct{}
+
+func (davLockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	tokens := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		if c.Token != "" {
+			tokens = append(tokens, c.Token)
+		}
+	}
+	locks, err := dbwrapper.QueryDAVLocks(tokens, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个非空路径都要有一个覆盖它的锁
+	for _, name := range []string{name0, name1} {
+		if name == "" {
+			continue
+		}
+		covered := false
+		for i := range locks {
+			if locks[i].Covers(name) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return nil, webdav.ErrConfirmationFailed
+		}
+	}
+	return func() {}, nil
+}
+
+func (davLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	token, err := randomString(32, "abcdefghijklmnopqrstuvwxyz0123456789")
+	if err != nil {
+		return "", err
+	}
+
+	duration := davLockDuration(details.Duration)
+	lock := &dbwrapper.DAVLock{
+		Token:     "opaquelocktoken:" + token,
+		Root:      details.Root,
+		ZeroDepth: details.ZeroDepth,
+		OwnerXML:  details.OwnerXML,
+		Duration:  duration,
+		ExpiresAt: now.Add(duration),
+	}
+	if err := dbwrapper.CreateDAVLock(lock, now); errors.Is(err, dbwrapper.ErrDAVLocked) {
+		return "", webdav.ErrLocked
+	} else if err != nil {
+		return "", err
+	}
+	return lock.Token, nil
+}
+
+func (davLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	lock, err := dbwrapper.RefreshDAVLock(token, now, davLockDuration(duration))
+	if errors.Is(err, dbwrapper.ErrDAVLockNotExist) {
+		return webdav.LockDetails{}, webdav.ErrNoSuchLock
+	} else if err != nil {
+		return webdav.LockDetails{}, err
+	}
+	return webdav.LockDetails{Root: lock.Root, Duration: lock.Duration, OwnerXML: lock.OwnerXML, ZeroDepth: lock.ZeroDepth}, nil
+}
+
+func (davLockSystem) Unlock(now time.Time, token string) error {
+	err := dbwrapper.DeleteDAVLock(token, now)
+	if errors.Is(err, dbwrapper.ErrDAVLockNotExist) {
+		return webdav.ErrNoSuchLock
+	}
+	return err
+}
+
+/**
+ * @description: 计算保存的锁时长，负数表示无限时长
+ * @param {time.Duration} duration 请求的时长
+ * @return {time.Duration}
+ */
+func davLockDuration(duration time.Duration) time.Duration {
+	if duration < 0 || duration > davMaxLockDuration {
+		return davMaxLockDuration
+	}
+	return duration
+}
@@ -0,0 +1,104 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 02:14:09
+ * @LastEditTime: 2026-10-19 02:14:09
+ * @FilePath: \CloudDisk\business\disposition.go
+ * @Description: 符合RFC 6266/5987的Content-Disposition响应头
+ */
+package business
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 文件名为空或全部被过滤时使用的名称
+const defaultDispositionName = "download"
+
+// RFC 5987 attr-char中除字母数字外的字符，其余字节需要百分号编码
+const dispositionAttrChars = "!#$&+-.^_`|~"
+
+/**
+ * @description: 生成Content-Disposition响应头
+ * filename为ASCII回退名称，供不支持RFC 5987的客户端使用；文件名含非ASCII字符时附加UTF-8编码的filename*，现代浏览器优先使用
+ * @param {string} dispositionType attachment或inline
+ * @param {string} name 文件名
+ * @return {string}
+ */
+func contentDisposition(dispositionType string, name string) string {
+	name = sanitizeDispositionName(name)
+	fallback := asciiDispositionName(name)
+
+	var header strings.Builder
+	header.WriteString(dispositionType)
+	header.WriteString(`; filename="`)
+	header.WriteString(fallback)
+	header.WriteString(`"`)
+	if fallback != name {
+		header.WriteString("; filename*=UTF-8''")
+		header.WriteString(percentEncodeDisposition(name))
+	}
+	return header.String()
+}
+
+/**
+ * @description: 清理文件名，路径分隔符和控制字符替换为下划线，去掉首尾空白和点，保存时会被浏览器改写
+ * @param {string} name 文件名
+ * @return {string}
+ */
+func sanitizeDispositionName(name string) string {
+	if !utf8.ValidString(name) {
+		name = strings.ToValidUTF8(name, "_")
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return defaultDispositionName
+	}
+	return name
+}
+
+/**
+ * @description: 生成ASCII回退名称，非ASCII字符和会被误解析的引号、反斜杠、百分号替换为下划线，保留扩展名
+ * @param {string} name 已清理的文件名
+ * @return {string}
+ */
+func asciiDispositionName(name string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, name)
+	if strings.Trim(fallback, "_. ") == "" {
+		return defaultDispositionName
+	}
+	return fallback
+}
+
+/**
+ * @description: 按RFC 5987对文件名的UTF-8字节做百分号编码
+ * @param {string} name 文件名
+ * @return {string}
+ */
+func percentEncodeDisposition(name string) string {
+	const hex = "0123456789ABCDEF"
+	var encoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < utf8.RuneSelf && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(dispositionAttrChars, c) >= 0) {
+			encoded.WriteByte(c)
+			continue
+		}
+		encoded.WriteByte('%')
+		encoded.WriteByte(hex[c>>4])
+		encoded.WriteByte(hex[c&0x0f])
+	}
+	return encoded.String()
+}
@@ -0,0 +1,75 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 12:44:30
+ * @LastEditTime: 2026-10-19 12:44:30
+ * @FilePath: \CloudDisk\business\disposition_test.go
+ * @Description: Content-Disposition响应头测试
+ */
+package business
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		saved  string // 客户端解析出的文件名
+	}{
+		// 纯ASCII只有filename
+		{"report.pdf", `attachment; filename="report.pdf"`, "report.pdf"},
+		{"my file.txt", `attachment; filename="my file.txt"`, "my file.txt"},
+		{"a;b.txt", `attachment; filename="a;b.txt"`, "a;b.txt"},
+
+		// 非ASCII附加filename*，回退名称保留扩展名
+		{"年度报告.pdf", `attachment; filename="____.pdf"; filename*=UTF-8''%E5%B9%B4%E5%BA%A6%E6%8A%A5%E5%91%8A.pdf`, "年度报告.pdf"},
+		{"😀 smile.png", `attachment; filename="_ smile.png"; filename*=UTF-8''%F0%9F%98%80%20smile.png`, "😀 smile.png"},
+
+		// 引号和百分号在回退名称中替换，filename*中编码
+		{`say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`, `say "hi".txt`},
+		{"100%.txt", `attachment; filename="100_.txt"; filename*=UTF-8''100%25.txt`, "100%.txt"},
+
+		// 路径分隔符、控制字符和无效UTF-8替换为下划线
+		{`a\b.txt`, `attachment; filename="a_b.txt"`, "a_b.txt"},
+		{"a/b.txt", `attachment; filename="a_b.txt"`, "a_b.txt"},
+		{"line\r\nbreak.txt", `attachment; filename="line__break.txt"`, "line__break.txt"},
+		{"tab\tname\x7f.txt", `attachment; filename="tab_name_.txt"`, "tab_name_.txt"},
+		{"a\xffb.txt", `attachment; filename="a_b.txt"`, "a_b.txt"},
+
+		// 首尾的空白和点被去掉
+		{" .hidden. ", `attachment; filename="hidden"`, "hidden"},
+
+		// 清理后为空的名称
+		{"", `attachment; filename="download"`, "download"},
+		{"...", `attachment; filename="download"`, "download"},
+		{"   ", `attachment; filename="download"`, "download"},
+		{"中文", `attachment; filename="download"; filename*=UTF-8''%E4%B8%AD%E6%96%87`, "中文"},
+		{"\x01", `attachment; filename="download"; filename*=UTF-8''_`, "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := contentDisposition("attachment", tt.name)
+			if header != tt.header {
+				t.Errorf("got  %s\nwant %s", header, tt.header)
+			}
+
+			// 按RFC 6266解析时得到清理后的文件名
+			dispositionType, params, err := mime.ParseMediaType(header)
+			if err != nil {
+				t.Fatalf("header does not parse: %v", err)
+			}
+			if dispositionType != "attachment" || params["filename"] != tt.saved {
+				t.Errorf("parsed as %s %q, want attachment %q", dispositionType, params["filename"], tt.saved)
+			}
+		})
+	}
+}
+
+func TestContentDispositionInline(t *testing.T) {
+	if header := contentDisposition("inline", "photo.jpg"); header != `inline; filename="photo.jpg"` {
+		t.Errorf("got %s", header)
+	}
+}
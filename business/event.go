@@ -0,0 +1,172 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 22:48:17
+ * @LastEditTime: 2026-10-19 10:17:02
+ * @FilePath: \CloudDisk\business\event.go
+ * @Description: 通过Server-Sent Events实时推送文件夹下的变更
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 没有事件时发送注释的间隔，防止连接被代理断开
+const eventKeepAlive = 30 * time.Second
+
+// 断线后建议客户端重连的等待时间，单位毫秒
+const eventRetry = 3000
+
+/**
+ * @description: 路径是否为文件夹本身或其下的条目
+ * @param {string} p 路径
+ * @param {string} folderPath 文件夹路径
+ * @return {bool}
+ */
+func underFolder(p string, folderPath string) bool {
+	return p == folderPath || strings.HasPrefix(p, strings.TrimSuffix(folderPath, "/")+"/")
+}
+
+/**
+ * @description: 变更是否在订阅的文件夹下，移入和移出都算
+ * @param {string} folderPath 订阅的文件夹路径
+ * @param {dbwrapper.Change} change 变更
+ * @return {bool}
+ */
+func changeInFolder(folderPath string, change dbwrapper.Change) bool {
+	return underFolder(change.Path, folderPath) || (change.OldPath != "" && underFolder(change.OldPath, folderPath))
+}
+
+/**
+ * @description: 事件流api，GET /api/events?folderId=&path=
+ * 每个事件的id为变更ID，事件类型为变更类型，数据为变更的JSON
+ * 重连时通过Last-Event-ID请求头或lastEventId参数从断开处继续，不带时从当前开始
+ * 所有用户共享同一棵目录树，没有按用户的访问控制，认证通过即可订阅任意文件夹的全部变更
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func Events(w http.ResponseWriter, r *http.Request) {
+	// 只支持GET请求
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+	if _, ok := requireUser(w, r); !ok {
+		return
+	}
+
+	// 确定订阅的文件夹，默认为根目录
+	query := r.URL.Query()
+	var folderID int64
+	if folderIDStr := query.Get("folderId"); folderIDStr != "" {
+		var err error
+		if folderID, err = strconv.ParseInt(folderIDStr, 10, 64); err != nil {
+			http.Error(w, "Invalid folderId", http.StatusBadRequest)
+			return
+		}
+	}
+	folderID, ok := requestFolderID(w, folderID, query.Get("path"))
+	if !ok {
+		return
+	}
+	if folderID == 0 {
+		folderID = 1
+	}
+	folder, err := dbwrapper.QueryFolderInfo(folderID)
+	if err == dbwrapper.ErrFolderNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 确定起始位置
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("lastEventId")
+	}
+	var cursor int64
+	if lastEventID != "" {
+		if cursor, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || cursor < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	} else if cursor, err = dbwrapper.LatestChangeID(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		// 先取通知通道再查询，查询之后写入的变更一定会触发通知
+		signal := dbwrapper.ChangeSignal()
+		changes, err := dbwrapper.QueryChanges(cursor, maxChangesLimit)
+		if err != nil {
+			// 客户端会按retry重连并从最后收到的事件继续
+			return
+		}
+
+		for _, change := range changes {
+			cursor = change.ID
+			if changeInFolder(folder.Path, change) {
+				data, err := json.Marshal(change)
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Action, data); err != nil {
+					return
+				}
+			}
+
+			// 订阅的文件夹或其上级改名、移动后继续跟随，删除后结束
+			if change.ItemType != "folder" {
+				continue
+			}
+			if change.Action == dbwrapper.ChangeDelete && underFolder(folder.Path, change.Path) {
+				rc.Flush()
+				return
+			}
+			if change.OldPath != "" && underFolder(folder.Path, change.OldPath) {
+				folder.Path = change.Path + strings.TrimPrefix(folder.Path, change.OldPath)
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if len(changes) == maxChangesLimit {
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-signal:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,613 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 11:02:16
+ * @LastEditTime: 2026-10-19 17:42:10
+ * @FilePath: \CloudDisk\business\extract.go
+ * @Description: 文件文本内容提取
+ */
+package business
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// 参与内容提取的文件大小上限，同时是解压后数据的大小上限，防止压缩炸弹
+	maxExtractFileSize = 64 << 20
+	// 提取文本的长度上限
+	maxExtractTextSize = 1 << 20
+)
+
+// 按纯文本处理的扩展名
+var plainTextExts = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".csv": true, ".tsv": true, ".log": true,
+	".json": true, ".xml": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".conf": true,
+	".html": true, ".htm": true, ".css": true, ".sql": true, ".sh": true, ".bat": true, ".ps1": true,
+	".go": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true,
+	".java": true, ".kt": true, ".py": true, ".rb": true, ".php": true, ".rs": true, ".swift": true,
+	".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".vue": true, ".lua": true, ".proto": true,
+}
+
+/**
+ * @description: 提取本地文件的文本内容，不支持的格式返回空字符串
+ * @param {string} localPath 本地文件路径
+ * @param {string} fileName 文件名，用于判断格式
+ * @return {string} 文本内容
+ */
+func extractText(localPath string, fileName string) (string, error) {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return "", err
+	}
+	if stat.Size() > maxExtractFileSize {
+		return "", nil
+	}
+
+	var text string
+	switch ext := strings.ToLower(path.Ext(fileName)); ext {
+	case ".docx":
+		text, err = extractZipXML(localPath, func(name string) bool { return name == "word/document.xml" }, "t", "p")
+	case ".xlsx":
+		text, err = extractZipXML(localPath, func(name string) bool {
+			return name == "xl/sharedStrings.xml" || strings.HasPrefix(name, "xl/worksheets/sheet")
+		}, "t", "si")
+	case ".pptx":
+		text, err = extractZipXML(localPath, func(name string) bool {
+			return strings.HasPrefix(name, "ppt/slides/slide") && strings.HasSuffix(name, ".xml")
+		}, "t", "p")
+	case ".odt", ".ods", ".odp":
+		text, err = extractZipXML(localPath, func(name string) bool { return name == "content.xml" }, "", "p")
+	case ".pdf":
+		text, err = extractPDF(localPath)
+	default:
+		text, err = extractPlainText(localPath, ext)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// 截断时保证不截断在多字节字符中间
+	if len(text) > maxExtractTextSize {
+		text = text[:maxExtractTextSize]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	return text, nil
+}
+
+/**
+ * @description: 提取纯文本文件内容，扩展名未知时根据内容嗅探
+ * @param {string} localPath 本地文件路径
+ * @param {string} ext 扩展名
+ * @return {string} 文本内容
+ */
+func extractPlainText(localPath string, ext string) (string, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !plainTextExts[ext] && !strings.HasPrefix(http.DetectContentType(data), "text/") {
+		return "", nil
+	}
+
+	return strings.ToValidUTF8(string(data), ""), nil
+}
+
+/**
+ * @description: 提取zip包内xml文件的文本内容，适用于Office Open XML和OpenDocument格式
+ * @param {string} localPath 本地文件路径
+ * @param {func(string) bool} match 需要提取的包内文件
+ * @param {string} textElem 文本元素名，为空时提取全部文本
+ * @param {string} breakElem 段落元素名，元素结束时换行
+ * @return {string} 文本内容
+ */
+func extractZipXML(localPath string, match func(string) bool, textElem string, breakElem string) (string, error) {
+	reader, err := zip.OpenReader(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	// 按文件名排序，保证幻灯片等按顺序输出
+	var entries []*zip.File
+	for _, f := range reader.File {
+		if match(f.Name) {
+			entries = append(entries, f)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	var sb strings.Builder
+	remaining := int64(maxExtractFileSize)
+	for _, entry := range entries {
+		if remaining <= 0 || sb.Len() >= maxExtractTextSize {
+			break
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return "", err
+		}
+
+		limited := &io.LimitedReader{R: rc, N: remaining}
+		decoder := xml.NewDecoder(limited)
+		inText := textElem == ""
+		for sb.Len() < maxExtractTextSize {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			} else if err != nil && limited.N <= 0 {
+				// 解压数据超过上限，保留已提取的文本
+				break
+			} else if err != nil {
+				rc.Close()
+				return "", err
+			}
+
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == textElem {
+					inText = true
+				}
+			case xml.EndElement:
+				if t.Name.Local == textElem {
+					inText = false
+				} else if t.Name.Local == breakElem {
+					sb.WriteByte('\n')
+				}
+			case xml.CharData:
+				if inText {
+					sb.Write(t)
+				}
+			}
+		}
+		remaining = limited.N
+		rc.Close()
+	}
+
+	return sb.String(), nil
+}
+
+var (
+	pdfObjRe    = regexp.MustCompile(`(?s)(\d+)\s+\d+\s+obj\b(.*?)\bendobj`)
+	pdfStreamRe = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextRe   = regexp.MustCompile(`(?s)\[(.*?)\]\s*TJ|(\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)\s*(?:Tj|'|")|/([^\s/\[\]()<>{}%]+)\s+[-+\d.]+\s+Tf|(T\*|Td|TD|ET)`)
+	pdfStringRe = regexp.MustCompile(`\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>`)
+	pdfRefRe    = regexp.MustCompile(`/([^\s/\[\]()<>{}%]+)\s+(\d+)\s+\d+\s+R`)
+	pdfFontsRe  = regexp.MustCompile(`(?s)/Font\s*(?:<<(.*?)>>|(\d+)\s+\d+\s+R)`)
+	pdfHexRe    = regexp.MustCompile(`<([0-9A-Fa-f\s]*)>`)
+	pdfRangeRe  = regexp.MustCompile(`<([0-9A-Fa-f\s]*)>\s*<([0-9A-Fa-f\s]*)>\s*(<[0-9A-Fa-f\s]*>|\[[^\]]*\])`)
+	pdfIntRe    = regexp.MustCompile(`/(N|First)\s+(\d+)`)
+
+	pdfToUnicodeRe = regexp.MustCompile(`/ToUnicode\s+(\d+)\s+\d+\s+R`)
+	pdfCodespaceRe = regexp.MustCompile(`(?s)begincodespacerange(.*?)endcodespacerange`)
+	pdfBFCharRe    = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	pdfBFRangeRe   = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+)
+
+// bfrange中一个范围最多展开的编码数
+const maxPDFRangeSize = 1 << 16
+
+// pdf对象，dict为字典，stream为解码后的流，没有流或流的压缩格式不支持时为nil
+type pdfObject struct {
+	dict   []byte
+	stream []byte
+}
+
+// pdf字体，字符串中的编码通过ToUnicode CMap转为文本
+type pdfFont struct {
+	cid      bool              // Type0字体，编码是字形ID而不是字符
+	cmap     map[string]string // 编码到文本，没有ToUnicode时为nil
+	codeLens []int             // 编码的字节数，从小到大
+}
+
+/**
+ * @description: 提取pdf文件的文本内容，只处理未压缩和FlateDecode压缩的内容流中的文本操作符
+ * 字符串按当前字体的ToUnicode CMap解码，没有ToUnicode的字体按单字节编码处理
+ * Type0字体没有ToUnicode时编码无法转为文本，整个文件不建立索引，避免写入乱码
+ * 字体资源名按名称在整个文件中查找，不同页面以同一名称引用不同字体时使用先出现的字体
+ * @param {string} localPath 本地文件路径
+ * @return {string} 文本内容
+ */
+func extractPDF(localPath string) (string, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	remaining := int64(maxExtractFileSize)
+	objects, order := readPDFObjects(data, &remaining)
+	fonts := readPDFFonts(objects, order)
+
+	var sb strings.Builder
+	for _, num := range order {
+		// 跳过对象流和CMap流，其他流按内容流查找文本操作符
+		obj := objects[num]
+		content := obj.stream
+		if content == nil || bytes.Contains(obj.dict, []byte("/ObjStm")) || bytes.Contains(content, []byte("begincmap")) {
+			continue
+		}
+		if sb.Len() >= maxExtractTextSize {
+			break
+		}
+
+		var font *pdfFont
+		for _, op := range pdfTextRe.FindAllSubmatch(content, -1) {
+			var strs [][]byte
+			switch {
+			case op[1] != nil:
+				strs = pdfStringRe.FindAll(op[1], -1)
+			case op[2] != nil:
+				strs = [][]byte{op[2]}
+			case op[3] != nil:
+				font = fonts[string(op[3])]
+				continue
+			default:
+				sb.WriteByte('\n')
+				continue
+			}
+
+			for _, str := range strs {
+				text, ok := font.decode(decodePDFString(str))
+				if !ok {
+					return "", nil
+				}
+				sb.WriteString(text)
+			}
+		}
+	}
+
+	return strings.ToValidUTF8(sb.String(), ""), nil
+}
+
+/**
+ * @description: 读取pdf文件中的对象，包括对象流中压缩保存的对象
+ * @param {[]byte} data 文件内容
+ * @param {*int64} remaining 剩余可解压的字节数
+ * @return {map[int]*pdfObject} 按对象号索引的对象
+ * @return {[]int} 对象号，按在文件中出现的顺序，对象流中的对象排在对象流之后
+ */
+func readPDFObjects(data []byte, remaining *int64) (map[int]*pdfObject, []int) {
+	objects := make(map[int]*pdfObject)
+	var order []int
+	for _, m := range pdfObjRe.FindAllSubmatch(data, -1) {
+		num, _ := strconv.Atoi(string(m[1]))
+		if _, ok := objects[num]; ok {
+			continue
+		}
+		obj := &pdfObject{dict: m[2]}
+		if sm := pdfStreamRe.FindSubmatch(m[2]); sm != nil {
+			obj.dict = sm[1]
+			obj.stream = decodePDFStream(sm[1], sm[2], remaining)
+		}
+		objects[num] = obj
+		order = append(order, num)
+		if obj.stream == nil || !bytes.Contains(obj.dict, []byte("/ObjStm")) {
+			continue
+		}
+
+		// 对象流开头是N对对象号和偏移量，偏移量从First开始计算
+		var n, first int
+		for _, kv := range pdfIntRe.FindAllSubmatch(obj.dict, -1) {
+			v, _ := strconv.Atoi(string(kv[2]))
+			if string(kv[1]) == "N" {
+				n = v
+			} else {
+				first = v
+			}
+		}
+		if first > len(obj.stream) {
+			continue
+		}
+		header := strings.Fields(string(obj.stream[:first]))
+		for i := 0; i+1 < len(header) && i/2 < n; i += 2 {
+			num, err1 := strconv.Atoi(header[i])
+			start, err2 := strconv.Atoi(header[i+1])
+			if err1 != nil || err2 != nil || first+start > len(obj.stream) {
+				break
+			}
+			end := len(obj.stream)
+			if i+3 < len(header) {
+				if next, err := strconv.Atoi(header[i+3]); err == nil && first+next >= first+start && first+next <= end {
+					end = first + next
+				}
+			}
+			if _, ok := objects[num]; !ok {
+				objects[num] = &pdfObject{dict: obj.stream[first+start : end]}
+				order = append(order, num)
+			}
+		}
+	}
+	return objects, order
+}
+
+/**
+ * @description: 解码pdf流，支持未压缩和FlateDecode压缩的流
+ * @param {[]byte} dict 流字典
+ * @param {[]byte} content 流数据
+ * @param {*int64} remaining 剩余可解压的字节数
+ * @return {[]byte} 解码后的数据，不支持或数据损坏时为nil
+ */
+func decodePDFStream(dict []byte, content []byte, remaining *int64) []byte {
+	if bytes.Contains(dict, []byte("/FlateDecode")) {
+		if *remaining <= 0 {
+			return nil
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil
+		}
+		// 压缩流可能被截断，能解出多少用多少，数据损坏时跳过该流
+		content, err = io.ReadAll(io.LimitReader(zr, *remaining))
+		zr.Close()
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil
+		}
+		*remaining -= int64(len(content))
+		return content
+	} else if bytes.Contains(dict, []byte("/Filter")) {
+		return nil
+	}
+	return content
+}
+
+/**
+ * @description: 读取字体资源，资源名对应字体对象，字体对象的ToUnicode流解析为编码表
+ * @param {map[int]*pdfObject} objects 对象
+ * @param {[]int} order 对象号，按在文件中出现的顺序
+ * @return {map[string]*pdfFont} 按资源名索引的字体
+ */
+func readPDFFonts(objects map[int]*pdfObject, order []int) map[string]*pdfFont {
+	fonts := make(map[string]*pdfFont)
+	addFonts := func(dict []byte) {
+		for _, ref := range pdfRefRe.FindAllSubmatch(dict, -1) {
+			name := string(ref[1])
+			num, _ := strconv.Atoi(string(ref[2]))
+			obj := objects[num]
+			if _, ok := fonts[name]; ok || obj == nil {
+				continue
+			}
+
+			font := &pdfFont{cid: bytes.Contains(obj.dict, []byte("/Type0"))}
+			if m := pdfToUnicodeRe.FindSubmatch(obj.dict); m != nil {
+				num, _ := strconv.Atoi(string(m[1]))
+				if cmap := objects[num]; cmap != nil && cmap.stream != nil {
+					font.cmap, font.codeLens = parsePDFCMap(cmap.stream)
+				}
+			}
+			fonts[name] = font
+		}
+	}
+
+	// 资源字典中的字体字典可能直接写出，也可能是间接引用，资源字典也可能在对象流中
+	for _, num := range order {
+		for _, m := range pdfFontsRe.FindAllSubmatch(objects[num].dict, -1) {
+			if m[1] != nil {
+				addFonts(m[1])
+			} else if ref, _ := strconv.Atoi(string(m[2])); objects[ref] != nil {
+				addFonts(objects[ref].dict)
+			}
+		}
+	}
+	return fonts
+}
+
+/**
+ * @description: 解析ToUnicode CMap中的bfchar和bfrange
+ * @param {[]byte} data CMap流
+ * @return {map[string]string} 编码到文本，没有映射时为nil
+ * @return {[]int} 编码的字节数，从小到大，没有codespacerange时按映射中的编码计算
+ */
+func parsePDFCMap(data []byte) (map[string]string, []int) {
+	cmap := make(map[string]string)
+	for _, section := range pdfBFCharRe.FindAllSubmatch(data, -1) {
+		hexes := pdfHexRe.FindAllSubmatch(section[1], -1)
+		for i := 0; i+1 < len(hexes); i += 2 {
+			if code := decodePDFHex(hexes[i][1]); len(code) > 0 {
+				cmap[string(code)] = decodeUTF16BE(decodePDFHex(hexes[i+1][1]))
+			}
+		}
+	}
+	for _, section := range pdfBFRangeRe.FindAllSubmatch(data, -1) {
+		for _, m := range pdfRangeRe.FindAllSubmatch(section[1], -1) {
+			lo, hi := decodePDFHex(m[1]), decodePDFHex(m[2])
+			if len(lo) == 0 || len(lo) != len(hi) || len(lo) > 4 {
+				continue
+			}
+			first, last := pdfCode(lo), pdfCode(hi)
+			if last < first || last-first >= maxPDFRangeSize {
+				continue
+			}
+
+			// 目标为数组时逐个对应，否则目标的最后一个UTF-16码元随编码递增
+			if m[3][0] == '[' {
+				for i, dst := range pdfHexRe.FindAllSubmatch(m[3], -1) {
+					if first+uint32(i) > last {
+						break
+					}
+					cmap[pdfCodeBytes(first+uint32(i), len(lo))] = decodeUTF16BE(decodePDFHex(dst[1]))
+				}
+				continue
+			}
+			dst := decodePDFHex(m[3][1 : len(m[3])-1])
+			if len(dst) < 2 {
+				continue
+			}
+			for code := first; code <= last; code++ {
+				units := append([]byte(nil), dst...)
+				unit := uint16(units[len(units)-2])<<8 | uint16(units[len(units)-1]) + uint16(code-first)
+				units[len(units)-2], units[len(units)-1] = byte(unit>>8), byte(unit)
+				cmap[pdfCodeBytes(code, len(lo))] = decodeUTF16BE(units)
+			}
+		}
+	}
+	if len(cmap) == 0 {
+		return nil, nil
+	}
+
+	lens := make(map[int]bool)
+	for _, section := range pdfCodespaceRe.FindAllSubmatch(data, -1) {
+		for _, m := range pdfHexRe.FindAllSubmatch(section[1], -1) {
+			if n := len(decodePDFHex(m[1])); n > 0 {
+				lens[n] = true
+			}
+		}
+	}
+	if len(lens) == 0 {
+		for code := range cmap {
+			lens[len(code)] = true
+		}
+	}
+	codeLens := make([]int, 0, len(lens))
+	for n := range lens {
+		codeLens = append(codeLens, n)
+	}
+	sort.Ints(codeLens)
+	return cmap, codeLens
+}
+
+/**
+ * @description: 将字符串中的编码转为文本
+ * @param {[]byte} s 字符串的字节
+ * @return {string} 文本
+ * @return {bool} 能否解码，没有ToUnicode的Type0字体编码是字形ID，无法解码
+ */
+func (f *pdfFont) decode(s []byte) (string, bool) {
+	if f == nil || f.cmap == nil {
+		return string(s), f == nil || !f.cid
+	}
+
+	// 按从短到长的编码长度匹配，编码表中没有的编码按最短长度跳过
+	var sb strings.Builder
+	for len(s) > 0 {
+		n := f.codeLens[0]
+		for _, l := range f.codeLens {
+			if l > len(s) {
+				break
+			}
+			if text, ok := f.cmap[string(s[:l])]; ok {
+				sb.WriteString(text)
+				n = l
+				break
+			}
+		}
+		s = s[min(n, len(s)):]
+	}
+	return sb.String(), true
+}
+
+/**
+ * @description: 取出pdf字符串的字节，支持字面字符串和十六进制字符串
+ * @param {[]byte} s 带括号或尖括号的字符串
+ * @return {[]byte}
+ */
+func decodePDFString(s []byte) []byte {
+	if s[0] == '<' {
+		return decodePDFHex(s[1 : len(s)-1])
+	}
+	return []byte(unescapePDFString(s[1 : len(s)-1]))
+}
+
+/**
+ * @description: 解码十六进制字符串，忽略空白，奇数位时末尾补0
+ * @param {[]byte} s 十六进制字符
+ * @return {[]byte} 格式错误时为nil
+ */
+func decodePDFHex(s []byte) []byte {
+	digits := strings.Join(strings.Fields(string(s)), "")
+	if len(digits)%2 == 1 {
+		digits += "0"
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+/**
+ * @description: 解码UTF-16BE文本，末尾不完整的字节被忽略
+ * @param {[]byte} b
+ * @return {string}
+ */
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// 大端字节转为编码值
+func pdfCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+// 编码值转为n字节大端字节，作为编码表的键
+func pdfCodeBytes(code uint32, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(code)
+		code >>= 8
+	}
+	return string(b)
+}
+
+/**
+ * @description: 反转义pdf字符串
+ * @param {[]byte} s pdf字符串
+ * @return {string} 反转义后的字符串
+ */
+func unescapePDFString(s []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// 最多三位的八进制编码
+			code := s[i] - '0'
+			for j := 0; j < 2 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7'; j++ {
+				i++
+				code = code<<3 | (s[i] - '0')
+			}
+			sb.WriteByte(code)
+		case '\r':
+			// 行尾的反斜杠表示续行
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r', 't', 'b', 'f':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,157 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 17:42:10
+ * @LastEditTime: 2026-10-19 17:42:10
+ * @FilePath: \CloudDisk\business\extract_test.go
+ * @Description: pdf文本提取测试：字面和十六进制字符串、ToUnicode CMap、对象流和无法解码的字体
+ */
+package business
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Type0字体的ToUnicode CMap，两字节编码
+const testToUnicode = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+3 beginbfchar
+<0003> <0020>
+<0010> <4F60>
+<0011> <597D>
+endbfchar
+2 beginbfrange
+<0024> <0026> <0041>
+<0030> <0031> [<0058> <0059>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+// 使用F1（Type0字体）和F2（单字节字体）显示文本的内容流
+const testContent = `BT /F1 12 Tf <0010 0011> Tj [<0003>] TJ <00240025 0026> Tj [<0030> -100 <0031>] TJ ET
+BT /F2 12 Tf (Hello \(PDF\) \101) Tj ET`
+
+/**
+ * @description: 生成pdf流对象
+ * @param {string} dict 流字典中除Length以外的条目
+ * @param {[]byte} content 流数据
+ * @return {string}
+ */
+func pdfTestStream(dict string, content []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(content), content)
+}
+
+/**
+ * @description: 按顺序生成对象号从1开始的pdf文件
+ * @param {*testing.T} t
+ * @param {...string} objects 对象内容，为空时跳过该对象号
+ * @return {string} 文件路径
+ */
+func writeTestPDF(t *testing.T, objects ...string) string {
+	t.Helper()
+	var sb strings.Builder
+	sb.WriteString("%PDF-1.5\n")
+	for i, obj := range objects {
+		if obj == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	sb.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	localPath := filepath.Join(t.TempDir(), "test.pdf")
+	if err := os.WriteFile(localPath, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return localPath
+}
+
+/**
+ * @description: 按FlateDecode压缩数据
+ * @param {*testing.T} t
+ * @param {string} data 原始数据
+ * @return {[]byte}
+ */
+func flateTestData(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	const want = "你好 ABCXY\nHello (PDF) A\n"
+	page := "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> /Contents 4 0 R >>"
+	type0 := "<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H /ToUnicode 7 0 R >>"
+	simple := "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+
+	t.Run("uncompressed", func(t *testing.T) {
+		localPath := writeTestPDF(t,
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			page,
+			pdfTestStream("", []byte(testContent)),
+			type0,
+			simple,
+			pdfTestStream("", []byte(testToUnicode)),
+		)
+		if got, err := extractText(localPath, "a.pdf"); err != nil || got != want {
+			t.Errorf("extractText() = %q, %v, want %q", got, err, want)
+		}
+	})
+
+	t.Run("compressed with object stream", func(t *testing.T) {
+		// 页面和字体对象保存在对象流8中，内容流和CMap流压缩
+		var header, body strings.Builder
+		for i, obj := range []string{page, type0, simple} {
+			fmt.Fprintf(&header, "%d %d ", []int{3, 5, 6}[i], body.Len())
+			body.WriteString(obj + "\n")
+		}
+		objStm := header.String() + body.String()
+		localPath := writeTestPDF(t,
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"",
+			pdfTestStream("/Filter /FlateDecode", flateTestData(t, testContent)),
+			"",
+			"",
+			pdfTestStream("/Filter /FlateDecode", flateTestData(t, testToUnicode)),
+			pdfTestStream(fmt.Sprintf("/Type /ObjStm /N 3 /First %d /Filter /FlateDecode", header.Len()), flateTestData(t, objStm)),
+		)
+		if got, err := extractText(localPath, "a.pdf"); err != nil || got != want {
+			t.Errorf("extractText() = %q, %v, want %q", got, err, want)
+		}
+	})
+
+	t.Run("type0 font without ToUnicode", func(t *testing.T) {
+		// 编码是字形ID，无法转为文本，不建立索引
+		localPath := writeTestPDF(t,
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			page,
+			pdfTestStream("", []byte(testContent)),
+			"<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H >>",
+			simple,
+		)
+		if got, err := extractText(localPath, "a.pdf"); err != nil || got != "" {
+			t.Errorf("extractText() = %q, %v, want empty", got, err)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 15:15:02
+ * @LastEditTime: 2026-10-19 09:46:52
+ * @FilePath: \CloudDisk\business\favorite.go
+ * @Description: 收藏和最近访问接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/logwrapper"
+	"encoding/json"
+	"net/http"
+)
+
+/**
+ * @description: 收藏api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func AddFavorite(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	type AddFavoriteRequest struct {
+		ItemType string `json:"itemType"` // file/folder
+		ItemID   int64  `json:"itemID"`
+	}
+	var req AddFavoriteRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 添加收藏
+	if err := dbwrapper.AddFavorite(user, req.ItemType, req.ItemID); err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Favorite added successfully"))
+}
+
+/**
+ * @description: 取消收藏api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	type RemoveFavoriteRequest struct {
+		ItemType string `json:"itemType"` // file/folder
+		ItemID   int64  `json:"itemID"`
+	}
+	var req RemoveFavoriteRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 取消收藏
+	if err := dbwrapper.RemoveFavorite(user, req.ItemType, req.ItemID); err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Favorite removed successfully"))
+}
+
+/**
+ * @description: 查询收藏列表api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryFavorites(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 查询收藏
+	queryResult, err := dbwrapper.QueryFavorites(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queryResult)
+}
+
+/**
+ * @description: 查询最近访问列表api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryRecent(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体，请求体可以为空
+	type QueryRecentRequest struct {
+		Limit int `json:"limit"`
+	}
+	var req QueryRecentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 查询最近访问
+	queryResult, err := dbwrapper.QueryRecentFiles(user, req.Limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queryResult)
+}
+
+/**
+ * @description: 记录最近访问，失败只记录日志不影响主流程
+ * @param {*http.Request} r
+ * @param {int64} fileID 文件ID
+ * @param {string} action 访问方式：upload/download
+ * @return {*}
+ */
+func recordRecent(r *http.Request, fileID int64, action string) {
+	user, _ := currentUser(r)
+	recordRecentFile(user, fileID, action)
+}
+
+/**
+ * @description: 记录用户的最近访问，失败只记录日志不影响主流程
+ * @param {string} user 用户名
+ * @param {int64} fileID 文件ID
+ * @param {string} action 访问方式：upload/download
+ * @return {*}
+ */
+func recordRecentFile(user string, fileID int64, action string) {
+	if err := dbwrapper.RecordRecentFile(user, fileID, action); err != nil {
+		logwrapper.Logger.Warnf("Failed to record recent file %d: %v", fileID, err)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 19:02:15
+ * @LastEditTime: 2026-10-19 16:30:52
+ * @FilePath: \CloudDisk\business\grpc.go
+ * @Description: gRPC服务，与JSON接口共用业务操作
+ */
+package business
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"CloudDisk/grpcapi"
+	"CloudDisk/logwrapper"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 下载时每条消息携带的数据大小
+const grpcChunkSize = 64 * 1024
+
+// context中保存用户名的key
+type grpcUserKey struct{}
+
+/**
+ * @description: 启动gRPC服务
+ * @param {string} address 监听地址
+ * @return {*}
+ */
+func ServeGRPC(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcUnaryAuth),
+		grpc.StreamInterceptor(grpcStreamAuth),
+	)
+	grpcapi.RegisterCloudDiskServer(server, &grpcServer{})
+
+	logwrapper.Logger.Infof("gRPC server is running on %s", address)
+	return server.Serve(listener)
+}
+
+/**
+ * @description: 从metadata的authorization中读取Basic认证信息，未配置用户时返回匿名用户
+ * @param {context.Context} ctx
+ * @return {string} 用户名
+ */
+func grpcAuthenticate(ctx context.Context) (string, error) {
+	if len(configwrapper.Cfg.Users) == 0 {
+		return anonymousUser, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		encoded, ok := strings.CutPrefix(auth, "Basic ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		name, password, ok := strings.Cut(string(decoded), ":")
+		if ok && checkUserPassword(name, password) {
+			return name, nil
+		}
+	}
+	return anonymousUser, status.Error(codes.Unauthenticated, "Unauthorized")
+}
+
+func grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := grpcAuthenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, grpcUserKey{}, user), req)
+}
+
+// 替换context的ServerStream
+type grpcAuthStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcAuthStream) Context() context.Context { return s.ctx }
+
+func grpcStreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	user, err := grpcAuthenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &grpcAuthStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), grpcUserKey{}, user)})
+}
+
+/**
+ * @description: 将业务错误转换为gRPC状态
+ * @param {error} err 业务错误
+ * @return {error}
+ */
+func grpcError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case dbwrapper.ErrFolderNotExist, dbwrapper.ErrFileNotExist, dbwrapper.ErrParentFolderNotExist:
+		return status.Error(codes.NotFound, err.Error())
+	case dbwrapper.ErrFolderExist, dbwrapper.ErrFileExist:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errRootFolder:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case dbwrapper.ErrMoveFolderIntoSelf:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, errInvalidName) || errors.Is(err, errInvalidConflictPolicy) || errors.Is(err, dbwrapper.ErrInvalidSortField) || errors.Is(err, dbwrapper.ErrInvalidCursor) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+/**
+ * @description: 因名称冲突跳过时在响应头metadata中设置x-conflict-skipped
+ * @param {context.Context} ctx 请求的context
+ * @param {bool} skipped 是否跳过
+ * @return {*}
+ */
+func grpcSetConflictSkipped(ctx context.Context, skipped bool) {
+	if !skipped {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(conflictSkippedHeader), "true")); err != nil {
+		logwrapper.Logger.Warnf("Failed to set gRPC header: %v", err)
+	}
+}
+
+func folderToProto(folder *dto.Folder) *grpcapi.Folder {
+	return &grpcapi.Folder{
+		Id:             folder.ID,
+		ParentFolderId: folder.ParentFolderID,
+		Name:           folder.Name,
+		Path:           folder.Path,
+		CreatedAt:      timestamppb.New(folder.CreatedAt),
+		UpdatedAt:      timestamppb.New(folder.UpdatedAt),
+		Tags:           folder.Tags,
+		Metadata:       folder.Metadata,
+	}
+}
+
+func fileToProto(file *dto.File) *grpcapi.File {
+	return &grpcapi.File{
+		Id:             file.ID,
+		ParentFolderId: file.ParentFolderID,
+		Name:           file.Name,
+		Path:           file.Path,
+		Size:           file.Size,
+		CreatedAt:      timestamppb.New(file.CreatedAt),
+		UpdatedAt:      timestamppb.New(file.UpdatedAt),
+		Tags:           file.Tags,
+		Metadata:       file.Metadata,
+	}
+}
+
+// 实现grpcapi.CloudDiskServer
+type grpcServer struct {
+	grpcapi.UnimplementedCloudDiskServer
+}
+
+func (s *grpcServer) QueryFolder(ctx context.Context, req *grpcapi.QueryFolderRequest) (*grpcapi.QueryFolderResponse, error) {
+	// 检查参数
+	if req.Limit < 0 || req.Limit > 1000 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid limit")
+	}
+
+	// 查询文件夹信息
+	queryResult, err := dbwrapper.QueryFolderInfoFull(req.FolderId, dbwrapper.ListOptions{
+		SortBy: req.SortBy,
+		Desc:   req.Desc,
+		Cursor: req.Cursor,
+		Limit:  int(req.Limit),
+
+		Tags:     req.Tags,
+		Metadata: req.Metadata,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &grpcapi.QueryFolderResponse{
+		Self:       folderToProto(queryResult.Self),
+		Total:      queryResult.Total,
+		NextCursor: queryResult.NextCursor,
+	}
+	for i := range queryResult.Folders {
+		resp.Folders = append(resp.Folders, folderToProto(&queryResult.Folders[i]))
+	}
+	for i := range queryResult.Files {
+		resp.Files = append(resp.Files, fileToProto(&queryResult.Files[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) CreateFolder(ctx context.Context, req *grpcapi.CreateFolderRequest) (*grpcapi.Folder, error) {
+	policy, err := parseConflictPolicy(req.OnConflict)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	folderInfo, created, err := createFolderWithPolicy(req.ParentFolderId, req.FolderName, policy)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	grpcSetConflictSkipped(ctx, policy == conflictSkip && !created)
+	return folderToProto(folderInfo), nil
+}
+
+// 将上传流中的数据块转换为io.Reader
+type grpcUploadReader struct {
+	stream grpc.ClientStreamingServer[grpcapi.UploadFileRequest, grpcapi.File]
+	buf    []byte
+}
+
+func (u *grpcUploadReader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		req, err := u.stream.Recv()
+		if err != nil {
+			return 0, err // 客户端关闭发送时为io.EOF
+		}
+		if req.GetInfo() != nil {
+			return 0, status.Error(codes.InvalidArgument, "file info must only be sent once")
+		}
+		u.buf = req.GetChunk()
+	}
+
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+func (s *grpcServer) UploadFile(stream grpc.ClientStreamingServer[grpcapi.UploadFileRequest, grpcapi.File]) error {
+	// 第一条消息为文件信息
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	info := req.GetInfo()
+	if info == nil || info.FileName == "" {
+		return status.Error(codes.InvalidArgument, "the first message must contain file info")
+	}
+
+	policy, err := parseConflictPolicy(info.OnConflict)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	// 保存文件
+	fileInfo, created, err := saveFileWithPolicy(info.ParentFolderId, info.FileName, &grpcUploadReader{stream: stream}, policy)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return grpcError(err)
+	}
+
+	if policy == conflictSkip && !created {
+		grpcSetConflictSkipped(stream.Context(), true)
+	} else {
+		// 记录最近访问
+		user, _ := stream.Context().Value(grpcUserKey{}).(string)
+		recordRecentFile(user, fileInfo.ID, "upload")
+	}
+
+	return stream.SendAndClose(fileToProto(fileInfo))
+}
+
+func (s *grpcServer) DownloadFile(req *grpcapi.DownloadFileRequest, stream grpc.ServerStreamingServer[grpcapi.DownloadFileResponse]) error {
+	// 查询文件信息
+	fileInfo, err := dbwrapper.QueryFileInfo(req.FileId)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	localFile, err := os.Open(path.Join(GetBaseFolderPath(), fileInfo.Path))
+	if err != nil {
+		return grpcError(err)
+	}
+	defer localFile.Close()
+
+	// 记录最近访问
+	user, _ := stream.Context().Value(grpcUserKey{}).(string)
+	recordRecentFile(user, fileInfo.ID, "download")
+
+	// 先发送文件信息，再分块发送内容
+	if err := stream.Send(&grpcapi.DownloadFileResponse{Data: &grpcapi.DownloadFileResponse_Info{Info: fileToProto(fileInfo)}}); err != nil {
+		return err
+	}
+	buf := make([]byte, grpcChunkSize)
+	for {
+		n, err := localFile.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&grpcapi.DownloadFileResponse{Data: &grpcapi.DownloadFileResponse_Chunk{Chunk: buf[:n]}}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return grpcError(err)
+		}
+	}
+}
+
+func (s *grpcServer) RenameFolder(ctx context.Context, req *grpcapi.RenameFolderRequest) (*grpcapi.Folder, error) {
+	policy, err := parseConflictPolicy(req.OnConflict)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	skipped, err := renameFolder(req.FolderId, req.FolderNewName, policy)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	grpcSetConflictSkipped(ctx, skipped)
+
+	folderInfo, err := dbwrapper.QueryFolderInfo(req.FolderId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return folderToProto(folderInfo), nil
+}
+
+func (s *grpcServer) RenameFile(ctx context.Context, req *grpcapi.RenameFileRequest) (*grpcapi.File, error) {
+	policy, err := parseConflictPolicy(req.OnConflict)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	skipped, err := renameFile(req.FileId, req.FileNewName, policy)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	grpcSetConflictSkipped(ctx, skipped)
+
+	fileInfo, err := dbwrapper.QueryFileInfo(req.FileId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fileToProto(fileInfo), nil
+}
+
+func (s *grpcServer) DeleteFolder(ctx context.Context, req *grpcapi.DeleteFolderRequest) (*grpcapi.DeleteFolderResponse, error) {
+	if err := deleteFolder(req.FolderId); err != nil {
+		return nil, grpcError(err)
+	}
+	return &grpcapi.DeleteFolderResponse{}, nil
+}
+
+func (s *grpcServer) DeleteFile(ctx context.Context, req *grpcapi.DeleteFileRequest) (*grpcapi.DeleteFileResponse, error) {
+	if err := deleteFile(req.FileId); err != nil {
+		return nil, grpcError(err)
+	}
+	return &grpcapi.DeleteFileResponse{}, nil
+}
@@ -0,0 +1,215 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 11:25:33
+ * @LastEditTime: 2026-10-19 15:02:44
+ * @FilePath: \CloudDisk\business\index.go
+ * @Description: 文件内容索引及全文搜索接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/logwrapper"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// 待建立索引的文件ID队列
+var indexQueue = make(chan int64, 4096)
+
+/**
+ * @description: 启动内容索引协程，并将尚未索引的文件加入队列
+ * @return {*}
+ */
+func StartIndexer() {
+	go func() {
+		for fileID := range indexQueue {
+			indexFile(fileID)
+		}
+	}()
+
+	// 补建启动前遗漏的索引
+	go func() {
+		fileIDs, err := dbwrapper.QueryUnindexedFileIDs()
+		if err != nil {
+			logwrapper.Logger.Errorf("Failed to query unindexed files: %v", err)
+			return
+		}
+		for _, fileID := range fileIDs {
+			indexQueue <- fileID
+		}
+	}()
+}
+
+/**
+ * @description: 将文件加入索引队列，队列满时等待而不阻塞调用方
+ * @param {int64} fileID 文件ID
+ * @return {*}
+ */
+func enqueueIndex(fileID int64) {
+	select {
+	case indexQueue <- fileID:
+	default:
+		go func() { indexQueue <- fileID }()
+	}
+}
+
+/**
+ * @description: 提取文件文本内容并写入索引
+ * @param {int64} fileID 文件ID
+ * @return {*}
+ */
+func indexFile(fileID int64) {
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		// 文件在排队期间被删除
+		return
+	}
+
+	text, err := extractText(path.Join(GetBaseFolderPath(), fileInfo.Path), fileInfo.Name)
+	if err != nil {
+		logwrapper.Logger.Warnf("Failed to extract text of file %d: %v", fileID, err)
+	}
+
+	// 不支持的格式也写入空内容，避免每次启动重复提取
+	if err := dbwrapper.SaveFileContent(fileID, text); err != nil {
+		logwrapper.Logger.Errorf("Failed to save content of file %d: %v", fileID, err)
+	}
+}
+
+/**
+ * @description: 全文搜索api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func SearchContent(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type SearchContentRequest struct {
+		Keyword      string `json:"keyword"`
+		RootFolderID int64  `json:"rootFolderID"`
+		Page         int    `json:"page"`
+		PageSize     int    `json:"pageSize"`
+	}
+	var req SearchContentRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(req.Keyword) == "" {
+		http.Error(w, "keyword is required", http.StatusBadRequest)
+		return
+	}
+
+	// 未指定根目录时搜索整棵树
+	if req.RootFolderID == 0 {
+		req.RootFolderID = 1
+	}
+
+	// 搜索
+	searchResult, err := dbwrapper.SearchFileContent(dbwrapper.ContentSearchOptions{
+		Keyword:      req.Keyword,
+		RootFolderID: req.RootFolderID,
+		Page:         req.Page,
+		PageSize:     req.PageSize,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 生成摘要
+	for i := range searchResult.Items {
+		searchResult.Items[i].Snippet = makeSnippet(searchResult.Items[i].Content, req.Keyword, searchResult.Items[i].CutBefore, searchResult.Items[i].CutAfter)
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(searchResult)
+}
+
+/**
+ * @description: 截取关键字附近的文本作为摘要，关键字整体未命中时依次尝试各个词
+ * @param {string} content 文本内容，可以是原文中截取的一段
+ * @param {string} keyword 搜索关键字
+ * @param {bool} cutBefore content之前是否还有原文
+ * @param {bool} cutAfter content之后是否还有原文
+ * @return {string} 摘要
+ */
+func makeSnippet(content string, keyword string, cutBefore bool, cutAfter bool) string {
+	const (
+		before = 40
+		after  = 120
+	)
+
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+
+	// ToLower可能改变字符数，此时无法用下标对应原文，直接返回开头
+	pos := -1
+	if len(lower) == len(runes) {
+		terms := append([]string{keyword}, strings.Fields(keyword)...)
+		for _, term := range terms {
+			if pos = indexRunes(lower, []rune(strings.ToLower(term))); pos >= 0 {
+				break
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if pos > before {
+		start = pos - before
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	if end > pos+after {
+		end = pos + after
+	}
+
+	// 合并空白字符
+	snippet := strings.Join(strings.FieldsFunc(string(runes[start:end]), unicode.IsSpace), " ")
+	if start > 0 || cutBefore {
+		snippet = "..." + snippet
+	}
+	if end < len(runes) || cutAfter {
+		snippet += "..."
+	}
+	return snippet
+}
+
+/**
+ * @description: 查找子串在字符数组中的位置
+ * @param {[]rune} s 字符数组
+ * @param {[]rune} sub 子串
+ * @return {int} 位置，未找到返回-1
+ */
+func indexRunes(s []rune, sub []rune) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
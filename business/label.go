@@ -0,0 +1,235 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 14:20:06
+ * @LastEditTime: 2026-10-19 09:46:52
+ * @FilePath: \CloudDisk\business\label.go
+ * @Description: 标签和元数据接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// 标签和元数据键的长度上限
+	maxLabelKeyLength = 64
+	// 元数据值的长度上限
+	maxLabelValueLength = 1024
+)
+
+/**
+ * @description: 添加标签api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func AddTags(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type AddTagsRequest struct {
+		ItemType string   `json:"itemType"` // file/folder
+		ItemID   int64    `json:"itemID"`
+		Tags     []string `json:"tags"`
+	}
+	var req AddTagsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 检查标签
+	tags, err := normalizeLabelKeys(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 添加标签
+	if err := dbwrapper.AddTags(req.ItemType, req.ItemID, tags); err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Tags added successfully"))
+}
+
+/**
+ * @description: 删除标签api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func RemoveTags(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type RemoveTagsRequest struct {
+		ItemType string   `json:"itemType"` // file/folder
+		ItemID   int64    `json:"itemID"`
+		Tags     []string `json:"tags"`
+	}
+	var req RemoveTagsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 删除标签
+	if err := dbwrapper.RemoveTags(req.ItemType, req.ItemID, req.Tags); err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Tags removed successfully"))
+}
+
+/**
+ * @description: 查询全部标签api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryTags(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 查询标签
+	tags, err := dbwrapper.QueryAllTags()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+/**
+ * @description: 设置元数据api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func SetMetadata(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type SetMetadataRequest struct {
+		ItemType string            `json:"itemType"` // file/folder
+		ItemID   int64             `json:"itemID"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	var req SetMetadataRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 检查元数据
+	metadata := make(map[string]string, len(req.Metadata))
+	for key, value := range req.Metadata {
+		keys, err := normalizeLabelKeys([]string{key})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(value) > maxLabelValueLength {
+			http.Error(w, "metadata value is too long", http.StatusBadRequest)
+			return
+		}
+		metadata[keys[0]] = value
+	}
+
+	// 设置元数据
+	if err := dbwrapper.SetMetadata(req.ItemType, req.ItemID, metadata); err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Metadata set successfully"))
+}
+
+/**
+ * @description: 删除元数据api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func DeleteMetadata(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type DeleteMetadataRequest struct {
+		ItemType string   `json:"itemType"` // file/folder
+		ItemID   int64    `json:"itemID"`
+		Keys     []string `json:"keys"`
+	}
+	var req DeleteMetadataRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 删除元数据
+	if err := dbwrapper.DeleteMetadata(req.ItemType, req.ItemID, req.Keys); err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Metadata deleted successfully"))
+}
+
+/**
+ * @description: 去除标签和元数据键首尾空白并检查长度
+ * @param {[]string} keys 标签或元数据键
+ * @return {[]string} 处理后的标签或元数据键
+ */
+func normalizeLabelKeys(keys []string) ([]string, error) {
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, errors.New("tag or metadata key cannot be empty")
+		}
+		if utf8.RuneCountInString(key) > maxLabelKeyLength {
+			return nil, errors.New("tag or metadata key is too long")
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
@@ -0,0 +1,150 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 04:12:46
+ * @LastEditTime: 2026-10-19 17:05:36
+ * @FilePath: \CloudDisk\business\lock.go
+ * @Description: 按父文件夹和名称加锁，串行化对同一本地路径的新建、覆盖、移动和删除
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 引用计数的互斥锁，没有等待者时从表中删除
+type nameLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	nameLocksMu sync.Mutex
+	nameLocks   = make(map[string]*nameLock)
+)
+
+// 需要锁定的名称
+type nameKey struct {
+	parentFolderID int64
+	name           string
+}
+
+func (k nameKey) String() string {
+	return strconv.FormatInt(k.parentFolderID, 10) + "/" + dbwrapper.NormalizeName(k.name)
+}
+
+/**
+ * @description: 锁定父文件夹下的名称，文件和文件夹共用同一把锁，因为它们在本地磁盘上共用同一个路径
+ * 数据库的唯一索引和names表保证文件和文件夹的记录不重名，多个实例之间也成立
+ * 锁保证检查、写入本地磁盘和写入数据库之间不会有本实例的其他请求修改同一路径
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} name 名称
+ * @return {func()} 解锁函数
+ */
+func lockName(parentFolderID int64, name string) func() {
+	return lockNames(nameKey{parentFolderID, name})
+}
+
+/**
+ * @description: 同时锁定多个名称，按键排序后依次加锁避免死锁，重复的名称只加一次锁
+ * 锁不可重入，持有锁时不能再锁定同一名称
+ * @param {...nameKey} keys 名称
+ * @return {func()} 解锁函数
+ */
+func lockNames(keys ...nameKey) func() {
+	var lockKeys []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if k := key.String(); !seen[k] {
+			seen[k] = true
+			lockKeys = append(lockKeys, k)
+		}
+	}
+	sort.Strings(lockKeys)
+
+	locks := make([]*nameLock, len(lockKeys))
+	for i, key := range lockKeys {
+		nameLocksMu.Lock()
+		lock, ok := nameLocks[key]
+		if !ok {
+			lock = &nameLock{}
+			nameLocks[key] = lock
+		}
+		lock.refs++
+		nameLocksMu.Unlock()
+
+		lock.mu.Lock()
+		locks[i] = lock
+	}
+
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].mu.Unlock()
+
+			nameLocksMu.Lock()
+			locks[i].refs--
+			if locks[i].refs == 0 {
+				delete(nameLocks, lockKeys[i])
+			}
+			nameLocksMu.Unlock()
+		}
+	}
+}
+
+/**
+ * @description: 锁定文件当前的名称和其他名称，加锁后重新查询文件，等待期间文件被移动时按新名称重新加锁
+ * @param {int64} fileID 文件ID
+ * @param {...nameKey} others 同时锁定的其他名称，如移动的目标名称
+ * @return {*dto.File} 加锁后的文件信息
+ * @return {func()} 解锁函数
+ */
+func lockFile(fileID int64, others ...nameKey) (*dto.File, func(), error) {
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		unlock := lockNames(append(others, nameKey{fileInfo.ParentFolderID, fileInfo.Name})...)
+		current, err := dbwrapper.QueryFileInfo(fileID)
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+		if current.ParentFolderID == fileInfo.ParentFolderID && current.Name == fileInfo.Name {
+			return current, unlock, nil
+		}
+		unlock()
+		fileInfo = current
+	}
+}
+
+/**
+ * @description: 锁定文件夹当前的名称和其他名称，加锁后重新查询文件夹，等待期间文件夹被移动时按新名称重新加锁
+ * 只锁定文件夹自身的名称，不锁定其下的条目
+ * @param {int64} folderID 文件夹ID
+ * @param {...nameKey} others 同时锁定的其他名称，如移动的目标名称
+ * @return {*dto.Folder} 加锁后的文件夹信息
+ * @return {func()} 解锁函数
+ */
+func lockFolder(folderID int64, others ...nameKey) (*dto.Folder, func(), error) {
+	folderInfo, err := dbwrapper.QueryFolderInfo(folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		unlock := lockNames(append(others, nameKey{folderInfo.ParentFolderID, folderInfo.Name})...)
+		current, err := dbwrapper.QueryFolderInfo(folderID)
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+		if current.ParentFolderID == folderInfo.ParentFolderID && current.Name == folderInfo.Name {
+			return current, unlock, nil
+		}
+		unlock()
+		folderInfo = current
+	}
+}
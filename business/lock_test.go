@@ -0,0 +1,93 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 11:14:38
+ * @LastEditTime: 2026-10-19 19:20:08
+ * @FilePath: \CloudDisk\business\lock_test.go
+ * @Description: 名称锁测试
+ */
+package business
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockNamesOppositeOrder(t *testing.T) {
+	// 以相反的顺序同时锁定两个名称，按键排序加锁时不会死锁
+	a := nameKey{1, "a"}
+	b := nameKey{1, "b"}
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 1000; i++ {
+			wg.Add(2)
+			go func() { defer wg.Done(); lockNames(a, b)() }()
+			go func() { defer wg.Done(); lockNames(b, a)() }()
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock locking names in opposite order")
+	}
+
+	nameLocksMu.Lock()
+	defer nameLocksMu.Unlock()
+	if len(nameLocks) != 0 {
+		t.Errorf("%d locks left in table after unlocking", len(nameLocks))
+	}
+}
+
+func TestLockNamesSameKey(t *testing.T) {
+	// 同一名称的不同写法只加一次锁，不可重入的锁不会自己死锁
+	done := make(chan struct{})
+	go func() {
+		lockNames(nameKey{1, "Same.txt"}, nameKey{1, "same.txt"})()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock locking the same name twice")
+	}
+}
+
+func TestLockNameExcludes(t *testing.T) {
+	unlock := lockName(1, "x")
+	acquired := make(chan struct{})
+	go func() {
+		lockName(1, "X")()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-acquired
+}
+
+func TestNameKeyNormalized(t *testing.T) {
+	// 按唯一性策略视为同一名称的文件和文件夹共用一把锁，不同父文件夹下的同名条目互不影响
+	tests := []struct {
+		a, b nameKey
+		same bool
+	}{
+		{nameKey{1, "Report.pdf"}, nameKey{1, "report.pdf"}, true},
+		{nameKey{1, "caf\u00e9"}, nameKey{1, "CAFE\u0301"}, true},
+		{nameKey{1, "report.pdf"}, nameKey{2, "report.pdf"}, false},
+		{nameKey{1, "a"}, nameKey{11, ""}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.String() == tt.b.String(); got != tt.same {
+			t.Errorf("%v and %v share a lock = %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
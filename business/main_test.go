@@ -0,0 +1,108 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 11:14:38
+ * @LastEditTime: 2026-10-19 11:14:38
+ * @FilePath: \CloudDisk\business\main_test.go
+ * @Description: 测试初始化，日志和存储目录写入临时目录，设置CLOUDDISK_TEST_CONFIG时连接配置文件中的数据库
+ */
+package business
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/dbwrapper"
+	"CloudDisk/logwrapper"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 指向测试配置文件的环境变量，未设置时跳过需要数据库的测试，配置文件中的数据库会被写入测试数据
+const testConfigEnv = "CLOUDDISK_TEST_CONFIG"
+
+// 是否已连接测试数据库
+var testDBReady bool
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	tempDir, err := os.MkdirTemp("", "clouddisk-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := logwrapper.Init(filepath.Join(tempDir, "log", "log.log"), logrus.WarnLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	configwrapper.Cfg = &configwrapper.Config{}
+	if configPath := os.Getenv(testConfigEnv); configPath != "" {
+		if err := configwrapper.Init(configPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	// 存储目录和缓存目录按程序目录的相对路径配置，指向临时目录
+	exePath, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for folder, name := range map[*string]string{&configwrapper.Cfg.Local.BaseFolder: "storage", &configwrapper.Cfg.Local.CacheFolder: "cache"} {
+		rel, err := filepath.Rel(filepath.Dir(exePath), filepath.Join(tempDir, name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		*folder = filepath.ToSlash(rel)
+	}
+	MakeAbsoluteFolder("/")
+
+	if os.Getenv(testConfigEnv) != "" {
+		dbwrapper.InitDB()
+		defer dbwrapper.CloseDB()
+		testDBReady = true
+	}
+
+	return m.Run()
+}
+
+/**
+ * @description: 未连接测试数据库时跳过测试
+ * @param {*testing.T} t
+ * @return {*}
+ */
+func requireTestDB(t *testing.T) {
+	t.Helper()
+	if !testDBReady {
+		t.Skipf("%s not set", testConfigEnv)
+	}
+}
+
+/**
+ * @description: 在根文件夹下新建测试专用的文件夹，测试结束后删除
+ * @param {*testing.T} t
+ * @return {int64} 文件夹ID
+ */
+func createTestFolder(t *testing.T) int64 {
+	t.Helper()
+	folder, err := createFolder(1, fmt.Sprintf("%s-%d", strings.ReplaceAll(t.Name(), "/", "-"), os.Getpid()))
+	if err != nil {
+		t.Fatalf("createFolder: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := deleteFolder(folder.ID); err != nil {
+			t.Errorf("deleteFolder: %v", err)
+		}
+	})
+	return folder.ID
+}
@@ -0,0 +1,92 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 02:51:33
+ * @LastEditTime: 2026-10-19 02:51:33
+ * @FilePath: \CloudDisk\business\name.go
+ * @Description: 文件名和文件夹名校验，防止路径穿越和客户端无法表示的名称
+ */
+package business
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var errInvalidName = errors.New("invalid name")
+
+// 名称的最大字节数，与常见文件系统一致
+const maxNameBytes = 255
+
+// 相对路径的最大字符数，与数据库path字段一致
+const maxPathChars = 1024
+
+// Windows保留的设备名，带扩展名时同样保留
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Windows文件名中不允许的字符，路径分隔符单独检查
+const reservedChars = `<>:"|?*`
+
+/**
+ * @description: 校验文件名或文件夹名，返回NFC规范化后的名称，同一名称的不同Unicode组合形式保存为同一个名称
+ * @param {string} name 名称
+ * @return {string} 规范化后的名称
+ */
+func validateName(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: name is not valid UTF-8", errInvalidName)
+	}
+	name = norm.NFC.String(name)
+
+	switch {
+	case name == "" || name == "." || name == "..":
+		return "", fmt.Errorf("%w: %q", errInvalidName, name)
+	case len(name) > maxNameBytes:
+		return "", fmt.Errorf("%w: name is longer than %d bytes", errInvalidName, maxNameBytes)
+	case strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("%w: %q contains a path separator", errInvalidName, name)
+	case strings.ContainsAny(name, reservedChars):
+		return "", fmt.Errorf("%w: %q contains one of %s", errInvalidName, name, reservedChars)
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return "", fmt.Errorf("%w: %q contains a control character", errInvalidName, name)
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") || strings.HasPrefix(name, " "):
+		// Windows会去掉末尾的点和空格，与其他名称冲突
+		return "", fmt.Errorf("%w: %q starts with a space or ends with a dot or space", errInvalidName, name)
+	}
+
+	base, _, _ := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(base)] {
+		return "", fmt.Errorf("%w: %q is a reserved device name", errInvalidName, name)
+	}
+	return name, nil
+}
+
+/**
+ * @description: 拼接父文件夹路径和名称，得到新的相对路径和本地路径，本地路径必须在存储根目录内
+ * @param {string} parentFolderPath 父文件夹的相对路径
+ * @param {string} name 已校验的名称
+ * @return {string} 相对路径
+ * @return {string} 本地路径
+ */
+func joinStoragePath(parentFolderPath string, name string) (string, string, error) {
+	relativePath := path.Join(parentFolderPath, name)
+	if utf8.RuneCountInString(relativePath) > maxPathChars {
+		return "", "", fmt.Errorf("%w: path is longer than %d characters", errInvalidName, maxPathChars)
+	}
+
+	baseFolder := GetBaseFolderPath()
+	localFullPath := path.Join(baseFolder, relativePath)
+	if !strings.HasPrefix(localFullPath, strings.TrimSuffix(baseFolder, "/")+"/") {
+		return "", "", fmt.Errorf("%w: path %q is outside the storage root", errInvalidName, relativePath)
+	}
+	return relativePath, localFullPath, nil
+}
@@ -0,0 +1,1005 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 16:12:08
+ * @LastEditTime: 2026-10-19 19:02:45
+ * @FilePath: \CloudDisk\business\operation.go
+ * @Description: 文件/文件夹操作，同时维护本地磁盘和数据库，供各类接口共用
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"CloudDisk/logwrapper"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+var errRootFolder = errors.New("cannot modify root folder")
+
+// 条件写入时文件的ETag与If-Match不符
+var errPreconditionFailed = errors.New("file has been modified")
+
+/**
+ * @description: 新建文件夹，同名文件夹已存在时失败
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} folderName 文件夹名称
+ * @return {*} dto.Folder 新建的文件夹信息
+ */
+func createFolder(parentFolderID int64, folderName string) (*dto.Folder, error) {
+	folderInfo, _, err := createFolderWithPolicy(parentFolderID, folderName, conflictFail)
+	return folderInfo, err
+}
+
+/**
+ * @description: 新建文件夹，名称冲突时按策略处理
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} folderName 文件夹名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {*} dto.Folder 新建或已有的文件夹信息
+ * @return {bool} 是否新建了文件夹
+ */
+func createFolderWithPolicy(parentFolderID int64, folderName string, policy conflictPolicy) (*dto.Folder, bool, error) {
+	// 校验名称
+	folderName, err := validateName(folderName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 查询父文件夹路径
+	parentFolderPath, err := dbwrapper.QueryFolderPath(parentFolderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 策略为rename时依次尝试"name (1)"、"name (2)"...
+	for attempt := 0; ; attempt++ {
+		name, err := conflictCandidate(folderName, attempt, true)
+		if err != nil {
+			return nil, false, err
+		}
+		folderInfo, created, err := createFolderNamed(parentFolderID, parentFolderPath, name, policy)
+		if policy == conflictRename && isNameConflict(err) {
+			continue
+		}
+		return folderInfo, created, err
+	}
+}
+
+/**
+ * @description: 以指定名称新建文件夹
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} parentFolderPath 父文件夹路径
+ * @param {string} folderName 已校验的文件夹名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {*} dto.Folder 新建或已有的文件夹信息
+ * @return {bool} 是否新建了文件夹
+ */
+func createFolderNamed(parentFolderID int64, parentFolderPath string, folderName string, policy conflictPolicy) (*dto.Folder, bool, error) {
+	// 拼接路径
+	_, localFullPath, err := joinStoragePath(parentFolderPath, folderName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 锁定名称，检查到写入数据库期间其他请求不能使用同一本地路径
+	unlock := lockName(parentFolderID, folderName)
+	defer unlock()
+
+	// 检查名称是否已被占用，策略为overwrite或skip时使用已有的同名文件夹
+	conflictFolderID, conflictFileID, err := findNameConflict(parentFolderID, folderName, 0, 0)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case conflictFolderID != 0 && (policy == conflictOverwrite || policy == conflictSkip):
+		folderInfo, err := dbwrapper.QueryFolderInfo(conflictFolderID)
+		return folderInfo, false, err
+	case conflictFolderID != 0 || conflictFileID != 0:
+		return nil, false, nameConflictError(conflictFolderID)
+	}
+
+	// 本地存在但数据库中不存在时是残留的文件夹，尝试删除
+	if _, err := os.Stat(localFullPath); err == nil {
+		if err := os.RemoveAll(localFullPath); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// 创建本地文件夹
+	if err := os.MkdirAll(localFullPath, os.ModePerm); err != nil {
+		return nil, false, err
+	}
+
+	// 如果后续操作没有正常完成，则删除已创建的本地文件夹
+	var operationSuc bool
+	defer func() {
+		if !operationSuc {
+			// 删除文件夹及其内容
+			removeErr := os.RemoveAll(localFullPath)
+			if removeErr != nil {
+				// 删除失败时记录日志
+				logwrapper.Logger.Errorf("Failed to clean up folder %s: %v", localFullPath, removeErr)
+			}
+		}
+	}()
+
+	// 数据库新建文件夹
+	folderID, err := dbwrapper.CreateFolder(folderName, parentFolderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 查询文件夹信息
+	folderInfo, err := dbwrapper.QueryFolderInfo(folderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 标记操作成功
+	operationSuc = true
+	return folderInfo, true, nil
+}
+
+/**
+ * @description: 新建文件并写入内容，文件已存在时失败
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} fileName 文件名
+ * @param {io.Reader} src 文件内容
+ * @return {*} dto.File 新建的文件信息
+ */
+func saveFile(parentFolderID int64, fileName string, src io.Reader) (*dto.File, error) {
+	fileInfo, _, err := saveFileWithPolicy(parentFolderID, fileName, src, conflictFail)
+	return fileInfo, err
+}
+
+/**
+ * @description: 新建文件并写入内容，名称冲突时按策略处理
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} fileName 文件名
+ * @param {io.Reader} src 文件内容
+ * @param {conflictPolicy} policy 名称冲突时的处理方式，overwrite时覆盖同名文件的内容
+ * @return {*} dto.File 新建、覆盖或已有的文件信息
+ * @return {bool} 是否新建了文件
+ */
+func saveFileWithPolicy(parentFolderID int64, fileName string, src io.Reader, policy conflictPolicy) (*dto.File, bool, error) {
+	// 校验名称
+	fileName, err := validateName(fileName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 查询父文件夹路径
+	parentFolderPath, err := dbwrapper.QueryFolderPath(parentFolderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 拼接写入路径，path不会自动转换路径分隔符
+	_, localFullPath, err := joinStoragePath(parentFolderPath, fileName)
+	if err != nil {
+		return nil, false, err
+	}
+	MkPathParentFolder(localFullPath)
+
+	// 名称已被占用且不会改名或覆盖时不必接收文件内容
+	if policy != conflictRename {
+		conflictFolderID, conflictFileID, err := findNameConflict(parentFolderID, fileName, 0, 0)
+		switch {
+		case err != nil:
+			return nil, false, err
+		case conflictFileID != 0 && policy == conflictSkip:
+			fileInfo, err := dbwrapper.QueryFileInfo(conflictFileID)
+			return fileInfo, false, err
+		case conflictFolderID != 0 || (conflictFileID != 0 && policy != conflictOverwrite):
+			return nil, false, nameConflictError(conflictFolderID)
+		}
+	}
+
+	// 先写入同一目录下的临时文件，写入期间不占用名称，同名的并发上传不会互相覆盖
+	tempFile, err := os.CreateTemp(path.Dir(localFullPath), ".upload-*")
+	if err != nil {
+		return nil, false, err
+	}
+	tempPath := tempFile.Name()
+	defer RemoveFileIgnoreNotExist(tempPath)
+
+	fileSize, err := io.Copy(tempFile, src)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 策略为rename时依次尝试"name (1).ext"、"name (2).ext"...
+	for attempt := 0; ; attempt++ {
+		name, err := conflictCandidate(fileName, attempt, false)
+		if err != nil {
+			return nil, false, err
+		}
+		fileInfo, created, err := saveTempFileAs(parentFolderID, parentFolderPath, name, tempPath, fileSize, policy)
+		if policy == conflictRename && isNameConflict(err) {
+			continue
+		}
+		return fileInfo, created, err
+	}
+}
+
+/**
+ * @description: 将临时文件以指定名称保存到文件夹下
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} parentFolderPath 父文件夹路径
+ * @param {string} fileName 已校验的文件名
+ * @param {string} tempPath 临时文件路径，与目标在同一目录下
+ * @param {int64} fileSize 文件大小
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {*} dto.File 新建、覆盖或已有的文件信息
+ * @return {bool} 是否新建了文件
+ */
+func saveTempFileAs(parentFolderID int64, parentFolderPath string, fileName string, tempPath string, fileSize int64, policy conflictPolicy) (*dto.File, bool, error) {
+	_, localFullPath, err := joinStoragePath(parentFolderPath, fileName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 锁定名称，检查到写入数据库期间其他请求不能使用同一本地路径
+	unlock := lockName(parentFolderID, fileName)
+	defer unlock()
+
+	// 写入临时文件期间名称可能已被其他请求占用，重新检查
+	conflictFolderID, conflictFileID, err := findNameConflict(parentFolderID, fileName, 0, 0)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case conflictFileID != 0 && policy == conflictOverwrite:
+		fileInfo, err := dbwrapper.QueryFileInfo(conflictFileID)
+		if err != nil {
+			return nil, false, err
+		}
+		fileInfo, err = replaceFileContent(fileInfo, tempPath, fileSize)
+		return fileInfo, false, err
+	case conflictFileID != 0 && policy == conflictSkip:
+		fileInfo, err := dbwrapper.QueryFileInfo(conflictFileID)
+		return fileInfo, false, err
+	case conflictFolderID != 0 || conflictFileID != 0:
+		return nil, false, nameConflictError(conflictFolderID)
+	}
+
+	// 临时文件替换残留的本地文件
+	if err := os.Rename(tempPath, localFullPath); err != nil {
+		return nil, false, err
+	}
+
+	// 标记操作是否成功，如果后续操作没有成功，则删除本地文件
+	var operationSuc bool
+	defer func() {
+		if !operationSuc {
+			// 删除文件
+			removeErr := RemoveFileIgnoreNotExist(localFullPath)
+			if removeErr != nil {
+				// 删除失败时记录日志
+				logwrapper.Logger.Errorf("Failed to clean up file %s: %v", localFullPath, removeErr)
+			}
+		}
+	}()
+
+	// 写入数据库
+	fileID, err := dbwrapper.CreateFile(fileName, fileSize, parentFolderID)
+	if err != nil {
+		return nil, false, err
+	}
+	recordMimeType(fileID, fileName, localFullPath)
+
+	// 查询文件信息
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	operationSuc = true
+
+	// 建立内容索引，生成缩略图和视频转码
+	enqueueIndex(fileID)
+	enqueueThumbnail(fileInfo)
+	enqueueTranscode(fileInfo)
+	return fileInfo, true, nil
+}
+
+/**
+ * @description: 覆盖已有文件的内容，先写入临时文件再替换，写入失败时原文件不受影响
+ * @param {int64} fileID 文件ID
+ * @param {io.Reader} src 文件内容
+ * @return {*} dto.File 更新后的文件信息
+ */
+func overwriteFile(fileID int64, src io.Reader) (*dto.File, error) {
+	return overwriteFileIfMatch(fileID, src, "")
+}
+
+/**
+ * @description: 文件的ETag与If-Match请求头匹配时覆盖内容，写入前和替换前各检查一次
+ * @param {int64} fileID 文件ID
+ * @param {io.Reader} src 文件内容
+ * @param {string} ifMatch If-Match请求头，为空时不检查，为*时匹配任何已存在的文件
+ * @return {*} dto.File 更新后的文件信息，不匹配时返回errPreconditionFailed
+ */
+func overwriteFileIfMatch(fileID int64, src io.Reader, ifMatch string) (*dto.File, error) {
+	// 查询文件信息
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !etagMatches(fileInfo, ifMatch) {
+		return nil, errPreconditionFailed
+	}
+
+	// 在同一目录下创建临时文件，保证重命名是原子操作
+	localFullPath := path.Join(GetBaseFolderPath(), fileInfo.Path)
+	MkPathParentFolder(localFullPath)
+	tempFile, err := os.CreateTemp(path.Dir(localFullPath), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	tempPath := tempFile.Name()
+	defer RemoveFileIgnoreNotExist(tempPath)
+
+	// 写入临时文件
+	fileSize, err := io.Copy(tempFile, src)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 锁定文件名，写入临时文件期间文件可能已被移动，加锁后按最新的文件信息替换
+	fileInfo, unlock, err := lockFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// 写入临时文件期间文件可能已被其他请求修改
+	if !etagMatches(fileInfo, ifMatch) {
+		return nil, errPreconditionFailed
+	}
+	return replaceFileContent(fileInfo, tempPath, fileSize)
+}
+
+/**
+ * @description: 判断文件的ETag是否与If-Match请求头匹配
+ * @param {*dto.File} fileInfo 文件信息
+ * @param {string} ifMatch If-Match请求头，逗号分隔的多个标签任一匹配即可，为空或*时总是匹配
+ * @return {bool}
+ */
+func etagMatches(fileInfo *dto.File, ifMatch string) bool {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+	etag := fileInfo.ETag()
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @description: 以临时文件替换已有文件的内容，调用方需持有文件名的锁
+ * @param {*dto.File} fileInfo 文件信息
+ * @param {string} tempPath 临时文件路径，与原文件在同一目录下
+ * @param {int64} fileSize 文件大小
+ * @return {*} dto.File 更新后的文件信息
+ */
+func replaceFileContent(fileInfo *dto.File, tempPath string, fileSize int64) (*dto.File, error) {
+	// 替换原文件
+	localFullPath := path.Join(GetBaseFolderPath(), fileInfo.Path)
+	if err := os.Rename(tempPath, localFullPath); err != nil {
+		return nil, err
+	}
+
+	// 更新数据库中的文件大小
+	fileID := fileInfo.ID
+	if err := dbwrapper.UpdateFileUpdateTimeAndSize(fileID, fileSize); err != nil {
+		return nil, err
+	}
+	recordMimeType(fileID, fileInfo.Name, localFullPath)
+
+	// 重新建立内容索引，旧内容的缩略图和转码结果作废
+	enqueueIndex(fileID)
+	removeFileCache(fileID)
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		return nil, err
+	}
+	enqueueThumbnail(fileInfo)
+	enqueueTranscode(fileInfo)
+	return fileInfo, nil
+}
+
+/**
+ * @description: 移动并重命名文件夹，新名称已被占用时失败
+ * @param {int64} folderID 文件夹ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} folderNewName 新的文件夹名称
+ * @return
+ */
+func moveFolder(folderID int64, newParentFolderID int64, folderNewName string) error {
+	_, err := moveFolderWithPolicy(folderID, newParentFolderID, folderNewName, conflictFail)
+	return err
+}
+
+/**
+ * @description: 移动并重命名文件夹，名称冲突时按策略处理
+ * @param {int64} folderID 文件夹ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} folderNewName 新的文件夹名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式，overwrite时替换同名文件夹，skip时不移动
+ * @return {bool} 是否因名称冲突跳过了移动
+ */
+func moveFolderWithPolicy(folderID int64, newParentFolderID int64, folderNewName string, policy conflictPolicy) (bool, error) {
+	// root文件夹无法移动
+	if folderID == 1 {
+		return false, errRootFolder
+	}
+
+	// 校验名称
+	folderNewName, err := validateName(folderNewName)
+	if err != nil {
+		return false, err
+	}
+
+	// 查询新的父文件夹路径
+	parentFolderPath, err := dbwrapper.QueryFolderPath(newParentFolderID)
+	if err != nil {
+		return false, err
+	}
+
+	// 策略为rename时依次尝试"name (1)"、"name (2)"...
+	for attempt := 0; ; attempt++ {
+		name, err := conflictCandidate(folderNewName, attempt, true)
+		if err != nil {
+			return false, err
+		}
+		skipped, err := moveFolderNamed(folderID, newParentFolderID, parentFolderPath, name, policy)
+		if policy == conflictRename && isNameConflict(err) {
+			continue
+		}
+		return skipped, err
+	}
+}
+
+/**
+ * @description: 以指定名称移动文件夹
+ * @param {int64} folderID 文件夹ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} parentFolderPath 新的父文件夹路径
+ * @param {string} folderNewName 已校验的新名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {bool} 是否因名称冲突跳过了移动
+ */
+func moveFolderNamed(folderID int64, newParentFolderID int64, parentFolderPath string, folderNewName string, policy conflictPolicy) (bool, error) {
+	_, newPath, err := joinStoragePath(parentFolderPath, folderNewName)
+	if err != nil {
+		return false, err
+	}
+
+	// 锁定原名称和新名称，检查到写入数据库期间其他请求不能使用这两个本地路径
+	folder, unlock, err := lockFolder(folderID, nameKey{newParentFolderID, folderNewName})
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	// 不能移动到自身或子文件夹下，在修改本地文件夹之前检查
+	if moveIntoSelf(folder, newParentFolderID, parentFolderPath) {
+		return false, dbwrapper.ErrMoveFolderIntoSelf
+	}
+
+	// 新名称已被占用时按策略处理，避免覆盖本地文件夹
+	var replaced *dto.Folder
+	conflictFolderID, conflictFileID, err := findNameConflict(newParentFolderID, folderNewName, folderID, 0)
+	switch {
+	case err != nil:
+		return false, err
+	case conflictFolderID == 0 && conflictFileID == 0:
+	case policy == conflictSkip:
+		return true, nil
+	case conflictFolderID != 0 && policy == conflictOverwrite:
+		replaced, err = dbwrapper.QueryFolderInfo(conflictFolderID)
+		if err != nil {
+			return false, err
+		}
+		// 同名文件夹是正在移动的文件夹的上级时不能替换
+		if strings.HasPrefix(folder.Path, replaced.Path+"/") {
+			return false, dbwrapper.ErrFolderExist
+		}
+	default:
+		return false, nameConflictError(conflictFolderID)
+	}
+
+	// 被替换文件夹下的文件，替换后清理缓存
+	var replacedFiles []dto.File
+	if replaced != nil {
+		_, replacedFiles, err = dbwrapper.QueryByPathPrefix(replaced.Path + "/")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// 被替换的本地文件夹先移到临时名称，数据库更新成功后才删除
+	var (
+		replacedPath string
+		asidePath    string
+	)
+	if replaced != nil {
+		replacedPath = path.Join(GetBaseFolderPath(), replaced.Path)
+		if asidePath, err = moveAside(replacedPath); err != nil {
+			return false, err
+		}
+	}
+
+	// 如果后续操作没有正常完成，则移回被替换的文件夹
+	var operationSuc bool
+	defer func() {
+		if !operationSuc {
+			restoreAside(asidePath, replacedPath)
+		}
+	}()
+
+	// 移动本地文件夹
+	var oldPath = path.Join(GetBaseFolderPath(), folder.Path)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return false, err
+	}
+
+	// 如果后续操作没有正常完成，则先回滚移动操作，再移回被替换的文件夹
+	defer func() {
+		if !operationSuc {
+			// 回滚移动操作
+			err := os.Rename(newPath, oldPath)
+			if err != nil {
+				logwrapper.Logger.Errorf("Failed to rollback folder rename: %v", err)
+			}
+		}
+	}()
+
+	// 更新数据库中的文件夹，替换时在同一事务中删除被替换的文件夹
+	if replaced != nil {
+		err = dbwrapper.MoveFolderReplacing(folderID, newParentFolderID, folderNewName, replaced.ID)
+	} else {
+		err = dbwrapper.MoveFolder(folderID, newParentFolderID, folderNewName)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	operationSuc = true
+
+	// 删除被替换的文件夹
+	removeAside(asidePath)
+	for _, file := range replacedFiles {
+		removeFileCache(file.ID)
+	}
+	return false, nil
+}
+
+/**
+ * @description: 判断是否要将文件夹移动到自身或其子文件夹下
+ * @param {*dto.Folder} folder 要移动的文件夹
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} parentFolderPath 新的父文件夹路径
+ * @return {bool}
+ */
+func moveIntoSelf(folder *dto.Folder, newParentFolderID int64, parentFolderPath string) bool {
+	return newParentFolderID == folder.ID || parentFolderPath == folder.Path || strings.HasPrefix(parentFolderPath, folder.Path+"/")
+}
+
+/**
+ * @description: 重命名文件夹
+ * @param {int64} folderID 文件夹ID
+ * @param {string} folderNewName 新的文件夹名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {bool} 是否因名称冲突跳过了重命名
+ */
+func renameFolder(folderID int64, folderNewName string, policy conflictPolicy) (bool, error) {
+	folder, err := dbwrapper.QueryFolderInfo(folderID)
+	if err != nil {
+		return false, err
+	}
+
+	return moveFolderWithPolicy(folderID, folder.ParentFolderID, folderNewName, policy)
+}
+
+/**
+ * @description: 移动并重命名文件，新名称已被占用时失败
+ * @param {int64} fileID 文件ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} fileNewName 新的文件名称
+ * @return
+ */
+func moveFile(fileID int64, newParentFolderID int64, fileNewName string) error {
+	_, err := moveFileWithPolicy(fileID, newParentFolderID, fileNewName, conflictFail)
+	return err
+}
+
+/**
+ * @description: 移动并重命名文件，名称冲突时按策略处理
+ * @param {int64} fileID 文件ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} fileNewName 新的文件名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式，overwrite时替换同名文件，skip时不移动
+ * @return {bool} 是否因名称冲突跳过了移动
+ */
+func moveFileWithPolicy(fileID int64, newParentFolderID int64, fileNewName string, policy conflictPolicy) (bool, error) {
+	// 校验名称
+	fileNewName, err := validateName(fileNewName)
+	if err != nil {
+		return false, err
+	}
+
+	// 查询新的父文件夹路径
+	parentFolderPath, err := dbwrapper.QueryFolderPath(newParentFolderID)
+	if err != nil {
+		return false, err
+	}
+
+	// 策略为rename时依次尝试"name (1).ext"、"name (2).ext"...
+	for attempt := 0; ; attempt++ {
+		name, err := conflictCandidate(fileNewName, attempt, false)
+		if err != nil {
+			return false, err
+		}
+		skipped, err := moveFileNamed(fileID, newParentFolderID, parentFolderPath, name, policy)
+		if policy == conflictRename && isNameConflict(err) {
+			continue
+		}
+		return skipped, err
+	}
+}
+
+/**
+ * @description: 以指定名称移动文件
+ * @param {int64} fileID 文件ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} parentFolderPath 新的父文件夹路径
+ * @param {string} fileNewName 已校验的新名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {bool} 是否因名称冲突跳过了移动
+ */
+func moveFileNamed(fileID int64, newParentFolderID int64, parentFolderPath string, fileNewName string, policy conflictPolicy) (bool, error) {
+	_, newPath, err := joinStoragePath(parentFolderPath, fileNewName)
+	if err != nil {
+		return false, err
+	}
+
+	// 锁定原名称和新名称，检查到写入数据库期间其他请求不能使用这两个本地路径
+	file, unlock, err := lockFile(fileID, nameKey{newParentFolderID, fileNewName})
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	// 新名称已被占用时按策略处理，避免覆盖本地文件
+	var replaced *dto.File
+	conflictFolderID, conflictFileID, err := findNameConflict(newParentFolderID, fileNewName, 0, file.ID)
+	switch {
+	case err != nil:
+		return false, err
+	case conflictFolderID == 0 && conflictFileID == 0:
+	case policy == conflictSkip:
+		return true, nil
+	case conflictFileID != 0 && policy == conflictOverwrite:
+		replaced, err = dbwrapper.QueryFileInfo(conflictFileID)
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, nameConflictError(conflictFolderID)
+	}
+
+	// 被替换的本地文件先移到临时名称，数据库更新成功后才删除
+	var (
+		replacedPath string
+		asidePath    string
+	)
+	if replaced != nil {
+		replacedPath = path.Join(GetBaseFolderPath(), replaced.Path)
+		if asidePath, err = moveAside(replacedPath); err != nil {
+			return false, err
+		}
+	}
+
+	// 如果后续操作没有正常完成，则移回被替换的文件
+	var operationSuc bool
+	defer func() {
+		if !operationSuc {
+			restoreAside(asidePath, replacedPath)
+		}
+	}()
+
+	// 移动本地文件
+	var oldPath = path.Join(GetBaseFolderPath(), file.Path)
+	MkPathParentFolder(newPath)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return false, err
+	}
+
+	// 如果后续操作没有正常完成，则先回滚移动操作，再移回被替换的文件
+	defer func() {
+		if !operationSuc {
+			// 回滚移动操作
+			err := os.Rename(newPath, oldPath)
+			if err != nil {
+				logwrapper.Logger.Errorf("Failed to rollback file rename: %v", err)
+			}
+		}
+	}()
+
+	// 更新数据库中的文件，替换时在同一事务中删除被替换的文件
+	if replaced != nil {
+		err = dbwrapper.MoveFileReplacing(file.ID, newParentFolderID, fileNewName, replaced.ID)
+	} else {
+		err = dbwrapper.MoveFile(file.ID, newParentFolderID, fileNewName)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	operationSuc = true
+
+	// 删除被替换的文件
+	if replaced != nil {
+		removeAside(asidePath)
+		removeFileCache(replaced.ID)
+	}
+
+	// 扩展名可能改变，重新建立内容索引
+	enqueueIndex(file.ID)
+	return false, nil
+}
+
+/**
+ * @description: 重命名文件
+ * @param {int64} fileID 文件ID
+ * @param {string} fileNewName 新的文件名称
+ * @param {conflictPolicy} policy 名称冲突时的处理方式
+ * @return {bool} 是否因名称冲突跳过了重命名
+ */
+func renameFile(fileID int64, fileNewName string, policy conflictPolicy) (bool, error) {
+	file, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		return false, err
+	}
+
+	return moveFileWithPolicy(fileID, file.ParentFolderID, fileNewName, policy)
+}
+
+/**
+ * @description: 将要被替换的本地文件或文件夹移到同一目录下的临时名称，替换成功后再删除，失败时可以移回
+ * @param {string} localPath 本地路径
+ * @return {string} 临时路径，本地路径不存在时为空
+ */
+func moveAside(localPath string) (string, error) {
+	suffix, err := randomString(16, "abcdefghijklmnopqrstuvwxyz0123456789")
+	if err != nil {
+		return "", err
+	}
+	asidePath := path.Join(path.Dir(localPath), ".replaced-"+suffix)
+	if err := os.Rename(localPath, asidePath); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return asidePath, nil
+}
+
+/**
+ * @description: 替换失败时将临时名称下的文件或文件夹移回原路径
+ * @param {string} asidePath 临时路径，为空时不处理
+ * @param {string} localPath 原路径
+ * @return {*}
+ */
+func restoreAside(asidePath string, localPath string) {
+	if asidePath == "" {
+		return
+	}
+	if err := os.Rename(asidePath, localPath); err != nil {
+		logwrapper.Logger.Errorf("Failed to restore %s to %s: %v", asidePath, localPath, err)
+	}
+}
+
+/**
+ * @description: 替换成功后删除临时名称下的文件或文件夹
+ * @param {string} asidePath 临时路径，为空时不处理
+ * @return {*}
+ */
+func removeAside(asidePath string) {
+	if asidePath == "" {
+		return
+	}
+	if err := os.RemoveAll(asidePath); err != nil {
+		logwrapper.Logger.Errorf("Failed to clean up replaced item %s: %v", asidePath, err)
+	}
+}
+
+/**
+ * @description: 删除文件夹及其全部内容
+ * @param {int64} folderID 文件夹ID
+ * @return
+ */
+func deleteFolder(folderID int64) error {
+	// 根文件夹不允许删除
+	if folderID == 1 {
+		return errRootFolder
+	}
+
+	// 锁定文件夹名称，删除期间其他请求不能使用同一本地路径
+	folder, unlock, err := lockFolder(folderID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return deleteFolderLocked(folder)
+}
+
+/**
+ * @description: 删除文件夹及其全部内容，调用方需持有文件夹名称的锁
+ * @param {*dto.Folder} folder 文件夹信息
+ * @return
+ */
+func deleteFolderLocked(folder *dto.Folder) error {
+	// 查询文件夹下的文件，删除后清理缓存
+	_, files, err := dbwrapper.QueryByPathPrefix(folder.Path + "/")
+	if err != nil {
+		return err
+	}
+
+	// 删除本地文件夹
+	var localPath = path.Join(GetBaseFolderPath(), folder.Path)
+	if err := os.RemoveAll(localPath); err != nil { // 路径不存在时，os.RemoveAll也会返回nil
+		return err
+	}
+
+	// 删除数据库中的文件夹
+	if err := dbwrapper.DeleteFolder(folder.ID); err != nil {
+		return err
+	}
+	for _, file := range files {
+		removeFileCache(file.ID)
+	}
+	return nil
+}
+
+/**
+ * @description: 删除文件
+ * @param {int64} fileID 文件ID
+ * @return
+ */
+func deleteFile(fileID int64) error {
+	// 锁定文件名，删除期间其他请求不能使用同一本地路径
+	fileInfo, unlock, err := lockFile(fileID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return deleteFileLocked(fileInfo)
+}
+
+/**
+ * @description: 删除文件，调用方需持有文件名的锁
+ * @param {*dto.File} fileInfo 文件信息
+ * @return
+ */
+func deleteFileLocked(fileInfo *dto.File) error {
+	// 删除本地文件
+	var localPath = path.Join(GetBaseFolderPath(), fileInfo.Path)
+	if err := RemoveFileIgnoreNotExist(localPath); err != nil {
+		return err
+	}
+
+	// 删除数据库中的文件
+	if err := dbwrapper.DeleteFile(fileInfo.ID); err != nil {
+		return err
+	}
+	removeFileCache(fileInfo.ID)
+	return nil
+}
+
+/**
+ * @description: 删除由文件内容生成的缓存，包括缩略图和转码结果
+ * @param {int64} fileID 文件ID
+ * @return {*}
+ */
+func removeFileCache(fileID int64) {
+	removeThumbnails(fileID)
+	removeTranscodes(fileID)
+}
+
+/**
+ * @description: 按路径查找文件夹或文件
+ * @param {string} name 路径
+ * @return {*dto.Folder} 文件夹信息，路径是文件时为nil
+ * @return {*dto.File} 文件信息，路径是文件夹时为nil
+ */
+func lookupPath(name string) (*dto.Folder, *dto.File, error) {
+	name = path.Clean("/" + name)
+	folder, err := dbwrapper.QueryFolderInfoByPath(name)
+	if err == nil {
+		return folder, nil, nil
+	} else if err != dbwrapper.ErrFolderNotExist {
+		return nil, nil, err
+	}
+
+	file, err := dbwrapper.QueryFileInfoByPath(name)
+	if err == nil {
+		return nil, file, nil
+	} else if err != dbwrapper.ErrFileNotExist {
+		return nil, nil, err
+	}
+
+	return nil, nil, os.ErrNotExist
+}
+
+/**
+ * @description: 查找路径的父文件夹
+ * @param {string} name 路径
+ * @return {*dto.Folder} 父文件夹信息
+ */
+func lookupParent(name string) (*dto.Folder, error) {
+	parent, err := dbwrapper.QueryFolderInfoByPath(path.Dir(path.Clean("/" + name)))
+	if err == dbwrapper.ErrFolderNotExist {
+		return nil, os.ErrNotExist
+	}
+	return parent, err
+}
+
+/**
+ * @description: 将业务错误转换为os错误，供WebDAV和SFTP等文件系统协议使用
+ * @param {error} err 业务错误
+ * @return {error}
+ */
+func osError(err error) error {
+	switch err {
+	case dbwrapper.ErrFolderNotExist, dbwrapper.ErrFileNotExist, dbwrapper.ErrParentFolderNotExist:
+		return os.ErrNotExist
+	case dbwrapper.ErrFolderExist, dbwrapper.ErrFileExist:
+		return os.ErrExist
+	case errRootFolder:
+		return os.ErrPermission
+	}
+	if errors.Is(err, errInvalidName) || errors.Is(err, dbwrapper.ErrMoveFolderIntoSelf) {
+		return os.ErrInvalid
+	}
+	return err
+}
+
+/**
+ * @description: 将业务错误转换为HTTP状态码，供v1接口使用
+ * @param {error} err 业务错误
+ * @return {int}
+ */
+func operationStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidName), errors.Is(err, errInvalidConflictPolicy):
+		return http.StatusBadRequest
+	case errors.Is(err, dbwrapper.ErrFolderExist), errors.Is(err, dbwrapper.ErrFileExist), errors.Is(err, dbwrapper.ErrMoveFolderIntoSelf):
+		return http.StatusConflict
+	case errors.Is(err, errRootFolder):
+		return http.StatusForbidden
+	case errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, dbwrapper.ErrInvalidSortField), errors.Is(err, dbwrapper.ErrInvalidCursor), errors.Is(err, dbwrapper.ErrInvalidItemType):
+		return http.StatusBadRequest
+	case errors.Is(err, dbwrapper.ErrFolderNotExist), errors.Is(err, dbwrapper.ErrFileNotExist), errors.Is(err, dbwrapper.ErrParentFolderNotExist):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
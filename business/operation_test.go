@@ -0,0 +1,449 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 11:14:38
+ * @LastEditTime: 2026-10-19 17:05:36
+ * @FilePath: \CloudDisk\business\operation_test.go
+ * @Description: 文件/文件夹操作的并发和名称冲突测试
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 并发请求数
+const raceWorkers = 16
+
+func TestSaveFileConcurrentSameName(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	// 每个请求的内容长度不同，磁盘上的内容只能属于一个请求
+	var (
+		wg      sync.WaitGroup
+		results = make([]*dto.File, raceWorkers)
+		errs    = make([]error, raceWorkers)
+	)
+	for i := 0; i < raceWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = saveFile(parentID, "same.txt", bytes.NewReader(raceContent(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && winner == -1:
+			winner = i
+		case err == nil:
+			t.Fatalf("requests %d and %d both created the file", winner, i)
+		case !errors.Is(err, dbwrapper.ErrFileExist):
+			t.Errorf("request %d: got %v, want ErrFileExist", i, err)
+		case operationStatus(err) != 409:
+			t.Errorf("request %d: status %d, want 409", i, operationStatus(err))
+		}
+	}
+	if winner == -1 {
+		t.Fatal("no request created the file")
+	}
+
+	want := raceContent(winner)
+	got, err := os.ReadFile(path.Join(GetBaseFolderPath(), results[winner].Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("file on disk is %q, want the winner's content %q", got, want)
+	}
+	if results[winner].Size != int64(len(want)) {
+		t.Errorf("size is %d, want %d", results[winner].Size, len(want))
+	}
+	assertFolderEntries(t, parentID, "same.txt")
+}
+
+func TestCreateFolderConcurrentSameName(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	var (
+		wg      sync.WaitGroup
+		results = make([]*dto.Folder, raceWorkers)
+		errs    = make([]error, raceWorkers)
+	)
+	for i := 0; i < raceWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = createFolder(parentID, "same")
+		}(i)
+	}
+	wg.Wait()
+
+	var created int
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			created++
+			info, statErr := os.Stat(path.Join(GetBaseFolderPath(), results[i].Path))
+			if statErr != nil || !info.IsDir() {
+				t.Errorf("folder %s is missing on disk: %v", results[i].Path, statErr)
+			}
+		case !errors.Is(err, dbwrapper.ErrFolderExist):
+			t.Errorf("request %d: got %v, want ErrFolderExist", i, err)
+		case operationStatus(err) != 409:
+			t.Errorf("request %d: status %d, want 409", i, operationStatus(err))
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d requests created the folder, want 1", created)
+	}
+}
+
+func TestFileFolderSameNameInDatabase(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	// 直接写数据库不经过名称锁，由names表保证文件和文件夹不重名，模拟多个实例同时写入
+	if _, err := dbwrapper.CreateFolder("Same", parentID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbwrapper.CreateFile("same", 0, parentID); !errors.Is(err, dbwrapper.ErrFileExist) {
+		t.Errorf("CreateFile with a folder's name returned %v", err)
+	}
+
+	fileID, err := dbwrapper.CreateFile("other", 0, parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dbwrapper.MoveFile(fileID, parentID, "SAME"); !errors.Is(err, dbwrapper.ErrFileExist) {
+		t.Errorf("MoveFile onto a folder's name returned %v", err)
+	}
+}
+
+func TestOverwriteFileConcurrent(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	file, err := saveFile(parentID, "same.txt", bytes.NewReader([]byte("original")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < raceWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := overwriteFile(file.ID, bytes.NewReader(raceContent(i))); err != nil {
+				t.Errorf("request %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 最后写入的内容和数据库中的大小必须来自同一个请求
+	file, err = dbwrapper.QueryFileInfo(file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path.Join(GetBaseFolderPath(), file.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(got)) != file.Size {
+		t.Errorf("file on disk has %d bytes, database says %d", len(got), file.Size)
+	}
+	if !bytes.Equal(got, raceContent(len(got)-len("content-"))) {
+		t.Errorf("file on disk is %q, not one of the written contents", got)
+	}
+	assertFolderEntries(t, parentID, "same.txt")
+}
+
+func TestOverwriteFileIfMatch(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	file, err := saveFile(parentID, "a.txt", strings.NewReader("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := file.ETag()
+
+	// 其他客户端修改后，旧的ETag不再匹配
+	if _, err := overwriteFile(file.ID, strings.NewReader("edited by someone else")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := overwriteFileIfMatch(file.ID, strings.NewReader("stale"), etag); err != errPreconditionFailed {
+		t.Fatalf("overwrite with a stale ETag returned %v", err)
+	}
+	assertFileContent(t, file.Path, "edited by someone else")
+
+	// 当前的ETag匹配时覆盖
+	current, err := dbwrapper.QueryFileInfo(file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := overwriteFileIfMatch(file.ID, strings.NewReader("fresh"), `"other", `+current.ETag()); err != nil {
+		t.Fatalf("overwrite with the current ETag: %v", err)
+	}
+	assertFileContent(t, file.Path, "fresh")
+	assertFolderEntries(t, parentID, "a.txt")
+}
+
+/**
+ * @description: 第i个请求写入的内容，长度各不相同
+ * @param {int} i 请求序号
+ * @return {[]byte}
+ */
+func raceContent(i int) []byte {
+	return []byte(fmt.Sprintf("content-%s", bytes.Repeat([]byte{'a' + byte(i%26)}, i)))
+}
+
+/**
+ * @description: 检查本地文件夹下恰好是指定的条目，没有残留的临时文件
+ * @param {*testing.T} t
+ * @param {int64} folderID 文件夹ID
+ * @param {...string} want 应有的条目名称
+ * @return {*}
+ */
+func assertFolderEntries(t *testing.T, folderID int64, want ...string) {
+	t.Helper()
+	folderPath, err := dbwrapper.QueryFolderPath(folderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(path.Join(GetBaseFolderPath(), folderPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("folder %s contains %q, want %q", folderPath, got, want)
+	}
+}
+
+/**
+ * @description: 检查本地文件的内容
+ * @param {*testing.T} t
+ * @param {string} filePath 文件路径
+ * @param {string} want 应有的内容
+ * @return {*}
+ */
+func assertFileContent(t *testing.T, filePath string, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path.Join(GetBaseFolderPath(), filePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s contains %q, want %q", filePath, got, want)
+	}
+}
+
+func TestMoveFileOverwrite(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := saveFile(parentID, "b.txt", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped, err := moveFileWithPolicy(src.ID, parentID, "b.txt", conflictOverwrite)
+	if err != nil || skipped {
+		t.Fatalf("moveFileWithPolicy = %v, %v", skipped, err)
+	}
+
+	moved, err := dbwrapper.QueryFileInfo(src.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Name != "b.txt" {
+		t.Errorf("moved file is named %s", moved.Name)
+	}
+	if _, err := dbwrapper.QueryFileInfo(target.ID); err != dbwrapper.ErrFileNotExist {
+		t.Errorf("replaced file still in database: %v", err)
+	}
+	assertFileContent(t, moved.Path, "new")
+	assertFolderEntries(t, parentID, "b.txt")
+}
+
+func TestMoveFileOverwriteFailureKeepsTarget(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := saveFile(parentID, "b.txt", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 源文件的本地文件丢失时移动失败，被替换的文件必须保持原样
+	if err := os.Remove(path.Join(GetBaseFolderPath(), src.Path)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moveFileWithPolicy(src.ID, parentID, "b.txt", conflictOverwrite); err == nil {
+		t.Fatal("move of a missing local file succeeded")
+	}
+
+	if _, err := dbwrapper.QueryFileInfo(target.ID); err != nil {
+		t.Errorf("replaced file removed from database: %v", err)
+	}
+	assertFileContent(t, target.Path, "old")
+	assertFolderEntries(t, parentID, "b.txt")
+}
+
+func TestMoveFolderOverwrite(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	src, err := createFolder(parentID, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saveFile(src.ID, "from-x.txt", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+	target, err := createFolder(parentID, "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacedFile, err := saveFile(target.ID, "from-y.txt", strings.NewReader("y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped, err := moveFolderWithPolicy(src.ID, parentID, "y", conflictOverwrite)
+	if err != nil || skipped {
+		t.Fatalf("moveFolderWithPolicy = %v, %v", skipped, err)
+	}
+
+	if _, err := dbwrapper.QueryFolderInfo(target.ID); err != dbwrapper.ErrFolderNotExist {
+		t.Errorf("replaced folder still in database: %v", err)
+	}
+	if _, err := dbwrapper.QueryFileInfo(replacedFile.ID); err != dbwrapper.ErrFileNotExist {
+		t.Errorf("file in replaced folder still in database: %v", err)
+	}
+	assertFolderEntries(t, parentID, "y")
+	assertFolderEntries(t, src.ID, "from-x.txt")
+}
+
+func TestMoveIntoSelf(t *testing.T) {
+	folder := &dto.Folder{ID: 5, Path: "/a/b"}
+	tests := []struct {
+		name             string
+		parentID         int64
+		parentFolderPath string
+		want             bool
+	}{
+		{"into itself", 5, "/a/b", true},
+		{"into child", 6, "/a/b/c", true},
+		{"into grandchild", 7, "/a/b/c/d", true},
+		{"into parent", 2, "/a", false},
+		{"into sibling with common prefix", 8, "/a/bc", false},
+		{"into root", 1, "/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moveIntoSelf(folder, tt.parentID, tt.parentFolderPath); got != tt.want {
+				t.Errorf("moveIntoSelf(%s) = %v, want %v", tt.parentFolderPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveFolderIntoSelf(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	folder, err := createFolder(parentID, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := createFolder(folder.ID, "child")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 移动到自身或子文件夹下失败，本地文件夹保持原样
+	for _, newParentID := range []int64{folder.ID, child.ID} {
+		if _, err := moveFolderWithPolicy(folder.ID, newParentID, "x", conflictFail); err != dbwrapper.ErrMoveFolderIntoSelf {
+			t.Errorf("move into %d returned %v", newParentID, err)
+		}
+	}
+	assertFolderEntries(t, parentID, "x")
+	assertFolderEntries(t, folder.ID, "child")
+
+	if status := operationStatus(dbwrapper.ErrMoveFolderIntoSelf); status != http.StatusConflict {
+		t.Errorf("status %d, want 409", status)
+	}
+}
+
+func TestMoveSkip(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saveFile(parentID, "b.txt", strings.NewReader("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	skipped, err := moveFileWithPolicy(src.ID, parentID, "b.txt", conflictSkip)
+	if err != nil || !skipped {
+		t.Fatalf("moveFileWithPolicy = %v, %v, want skipped", skipped, err)
+	}
+	assertFileContent(t, src.Path, "a")
+	assertFolderEntries(t, parentID, "a.txt", "b.txt")
+
+	// v1和v2接口通过响应头返回跳过
+	body := fmt.Sprintf(`{"fileID": %d, "fileName": "b.txt", "onConflict": "skip"}`, src.ID)
+	w := httptest.NewRecorder()
+	RenameFile(w, httptest.NewRequest(http.MethodPost, "/api/renameFile", strings.NewReader(body)))
+	if w.Code != http.StatusOK || w.Header().Get(conflictSkippedHeader) != "true" {
+		t.Errorf("v1 rename: status %d, %s %q", w.Code, conflictSkippedHeader, w.Header().Get(conflictSkippedHeader))
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/api/v2/files/%d", src.ID), strings.NewReader(`{"name": "b.txt", "onConflict": "skip"}`))
+	r.SetPathValue("id", fmt.Sprint(src.ID))
+	v2PatchFile(w, r)
+	if w.Code != http.StatusOK || w.Header().Get(conflictSkippedHeader) != "true" {
+		t.Errorf("v2 patch: status %d, %s %q", w.Code, conflictSkippedHeader, w.Header().Get(conflictSkippedHeader))
+	}
+
+	w = httptest.NewRecorder()
+	RenameFile(w, httptest.NewRequest(http.MethodPost, "/api/renameFile", strings.NewReader(fmt.Sprintf(`{"fileID": %d, "fileName": "c.txt", "onConflict": "skip"}`, src.ID))))
+	if w.Code != http.StatusOK || w.Header().Get(conflictSkippedHeader) != "" {
+		t.Errorf("v1 rename without conflict: status %d, %s %q", w.Code, conflictSkippedHeader, w.Header().Get(conflictSkippedHeader))
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:05:31
+ * @LastEditTime: 2026-10-19 00:48:52
+ * @FilePath: \CloudDisk\business\path.go
+ * @Description: 按路径寻址，请求可以用path代替folderID/fileID
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+)
+
+/**
+ * @description: 规范化请求中的路径，始终以/开头
+ * @param {string} p 请求中的路径
+ * @return {string}
+ */
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+/**
+ * @description: 根据请求中的ID或路径确定文件夹ID，出错时写入响应
+ * @param {http.ResponseWriter} w
+ * @param {int64} folderID 请求中的文件夹ID
+ * @param {string} folderPath 请求中的文件夹路径
+ * @return {int64} 文件夹ID
+ * @return {bool} 是否成功
+ */
+func requestFolderID(w http.ResponseWriter, folderID int64, folderPath string) (int64, bool) {
+	if folderPath == "" {
+		return folderID, true
+	}
+	if folderID != 0 {
+		http.Error(w, "Specify either an ID or a path, not both", http.StatusBadRequest)
+		return 0, false
+	}
+
+	folder, err := dbwrapper.QueryFolderInfoByPath(cleanPath(folderPath))
+	if err == dbwrapper.ErrFolderNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return 0, false
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+	return folder.ID, true
+}
+
+/**
+ * @description: 根据请求中的ID或路径确定文件ID，出错时写入响应
+ * @param {http.ResponseWriter} w
+ * @param {int64} fileID 请求中的文件ID
+ * @param {string} filePath 请求中的文件路径
+ * @return {int64} 文件ID
+ * @return {bool} 是否成功
+ */
+func requestFileID(w http.ResponseWriter, fileID int64, filePath string) (int64, bool) {
+	if filePath == "" {
+		return fileID, true
+	}
+	if fileID != 0 {
+		http.Error(w, "Specify either an ID or a path, not both", http.StatusBadRequest)
+		return 0, false
+	}
+
+	file, err := dbwrapper.QueryFileInfoByPath(cleanPath(filePath))
+	if err == dbwrapper.ErrFileNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return 0, false
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+	return file.ID, true
+}
+
+/**
+ * @description: 根据查询参数fileId或path确定文件ID，用于GET接口，出错时写入响应
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {int64} 文件ID
+ * @return {bool} 是否成功
+ */
+func queryFileID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	var fileID int64
+	if fileIDStr := r.URL.Query().Get("fileId"); fileIDStr != "" {
+		var err error
+		if fileID, err = strconv.ParseInt(fileIDStr, 10, 64); err != nil {
+			http.Error(w, "Invalid fileId", http.StatusBadRequest)
+			return 0, false
+		}
+	}
+	return requestFileID(w, fileID, r.URL.Query().Get("path"))
+}
+
+/**
+ * @description: 根据新建条目的完整路径确定父文件夹ID和名称，出错时写入响应
+ * @param {http.ResponseWriter} w
+ * @param {string} itemPath 新建条目的路径
+ * @return {int64} 父文件夹ID
+ * @return {string} 名称
+ * @return {bool} 是否成功
+ */
+func requestParentByPath(w http.ResponseWriter, itemPath string) (int64, string, bool) {
+	itemPath = cleanPath(itemPath)
+	if itemPath == "/" {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return 0, "", false
+	}
+
+	parentFolderID, ok := requestFolderID(w, 0, path.Dir(itemPath))
+	return parentFolderID, path.Base(itemPath), ok
+}
+
+/**
+ * @description: 按路径查询文件夹或文件api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryPath(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type QueryPathRequest struct {
+		Path string `json:"path"`
+	}
+	var req QueryPathRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 查询路径，文件夹和文件只有一个不为空
+	folder, file, err := lookupPath(req.Path)
+	if err == os.ErrNotExist {
+		http.Error(w, "Path does not exist", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	type QueryPathResponse struct {
+		Type   string      `json:"type"` // folder/file
+		Folder *dto.Folder `json:"folder,omitempty"`
+		File   *dto.File   `json:"file,omitempty"`
+	}
+	resp := QueryPathResponse{Type: "folder", Folder: folder, File: file}
+	if file != nil {
+		resp.Type = "file"
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
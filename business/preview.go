@@ -0,0 +1,180 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 00:48:52
+ * @LastEditTime: 2026-10-19 02:14:09
+ * @FilePath: \CloudDisk\business\preview.go
+ * @Description: MIME类型识别及在线预览接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/logwrapper"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// 系统MIME数据库中可能缺失的常见类型
+var extMimeTypes = map[string]string{
+	".txt":  "text/plain; charset=utf-8",
+	".log":  "text/plain; charset=utf-8",
+	".md":   "text/markdown; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+	".json": "application/json",
+	".pdf":  "application/pdf",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+}
+
+// 浏览器会执行其中脚本的类型，预览时按纯文本返回
+var activeMimeTypes = map[string]bool{
+	"text/html":              true,
+	"application/xhtml+xml":  true,
+	"image/svg+xml":          true,
+	"text/xml":               true,
+	"application/xml":        true,
+	"text/javascript":        true,
+	"application/javascript": true,
+}
+
+/**
+ * @description: 识别文件的MIME类型，以扩展名为准，内容明确是另一种二进制格式时以内容为准
+ * @param {string} localPath 本地文件路径
+ * @param {string} name 文件名
+ * @return {string} MIME类型
+ */
+func detectMimeType(localPath string, name string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	sniffed := http.DetectContentType(head[:n])
+
+	ext := strings.ToLower(path.Ext(name))
+	extType := extMimeTypes[ext]
+	if extType == "" {
+		extType = mime.TypeByExtension(ext)
+	}
+	if extType == "" {
+		return sniffed, nil
+	}
+
+	// 文本和zip内容无法区分具体格式(如.md、.docx)，以扩展名为准
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/") || sniffed == "application/zip" {
+		return extType, nil
+	}
+	return sniffed, nil
+}
+
+/**
+ * @description: 识别并保存文件的MIME类型，失败只记录日志
+ * @param {int64} fileID 文件ID
+ * @param {string} name 文件名
+ * @param {string} localPath 本地文件路径
+ * @return {string} MIME类型，失败时为空
+ */
+func recordMimeType(fileID int64, name string, localPath string) string {
+	mimeType, err := detectMimeType(localPath, name)
+	if err == nil {
+		err = dbwrapper.SaveFileMimeType(fileID, mimeType)
+	}
+	if err != nil {
+		logwrapper.Logger.Warnf("Failed to detect MIME type of file %d: %v", fileID, err)
+		return ""
+	}
+	return mimeType
+}
+
+/**
+ * @description: 在线预览api，GET /api/preview?fileId=&path=
+ * 按文件的MIME类型内联返回，支持Range请求以便拖动音视频进度
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func Preview(w http.ResponseWriter, r *http.Request) {
+	// 只支持GET请求
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 检查参数
+	fileID, ok := queryFileID(w, r)
+	if !ok {
+		return
+	}
+
+	// 查询文件信息
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err == dbwrapper.ErrFileNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	localPath := path.Join(GetBaseFolderPath(), fileInfo.Path)
+	f, err := os.Open(localPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// 上传时未识别的文件在首次预览时补充识别
+	mimeType := fileInfo.MimeType
+	if mimeType == "" {
+		if mimeType = recordMimeType(fileInfo.ID, fileInfo.Name, localPath); mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	if activeMimeTypes[mediaType] {
+		mimeType = "text/plain; charset=utf-8"
+	}
+
+	// 记录最近访问
+	recordRecent(r, fileInfo.ID, "download")
+
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Range, Accept-Ranges, ETag")
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", contentDisposition("inline", fileInfo.Name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// 禁止脚本和外部资源，PDF阅读器在sandbox下无法工作，只对其他类型启用
+	csp := "default-src 'none'; img-src 'self' data:; media-src 'self'; style-src 'unsafe-inline'"
+	if mediaType != "application/pdf" {
+		csp += "; sandbox"
+	}
+	w.Header().Set("Content-Security-Policy", csp)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%d-%s"`, fileInfo.ID, contentVersion(fileInfo)))
+	http.ServeContent(w, r, fileInfo.Name, fileInfo.UpdatedAt, f)
+}
@@ -0,0 +1,1032 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 17:58:37
+ * @LastEditTime: 2026-10-19 18:21:47
+ * @FilePath: \CloudDisk\business\s3.go
+ * @Description: S3兼容接口，bucket对应根目录下的文件夹，key对应bucket下的路径
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"CloudDisk/logwrapper"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	s3XMLNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3TimeFormat   = "2006-01-02T15:04:05.000Z"
+	// 单次列举返回的最大条数
+	s3MaxKeys = 1000
+	// 未完成的分片上传保留时间
+	s3MultipartExpiry = 7 * 24 * time.Hour
+	// 清理超时分片上传的间隔
+	s3CleanInterval = time.Hour
+)
+
+// S3错误
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3Error) Error() string { return e.code + ": " + e.message }
+
+var (
+	errS3NoSuchBucket     = &s3Error{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist."}
+	errS3NoSuchKey        = &s3Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+	errS3NoSuchUpload     = &s3Error{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist."}
+	errS3InvalidKey       = &s3Error{http.StatusBadRequest, "InvalidArgument", "The specified key is not a valid path."}
+	errS3KeyConflict      = &s3Error{http.StatusConflict, "InvalidRequest", "The key conflicts with an existing folder or file."}
+	errS3InvalidPart      = &s3Error{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found."}
+	errS3InvalidPartOrder = &s3Error{http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order."}
+	errS3MalformedXML     = &s3Error{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed."}
+	errS3BucketNotEmpty   = &s3Error{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty."}
+	errS3BucketExists     = &s3Error{http.StatusConflict, "BucketAlreadyOwnedByYou", "The bucket you tried to create already exists."}
+	errS3NotImplemented   = &s3Error{http.StatusNotImplemented, "NotImplemented", "The requested functionality is not implemented."}
+)
+
+// S3服务
+type s3Server struct {
+	region        string
+	stagingFolder string // 分片上传的暂存目录
+}
+
+/**
+ * @description: 启动S3兼容服务
+ * @param {string} address 监听地址
+ * @param {string} region 区域，为空时使用us-east-1
+ * @return {*}
+ */
+func ServeS3(address string, region string) error {
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// 暂存在缓存目录下，完成上传时与存储目录通常位于同一文件系统
+	server := &s3Server{region: region, stagingFolder: path.Join(GetCacheFolderPath(), "s3-uploads")}
+	if err := os.MkdirAll(server.stagingFolder, os.ModePerm); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(s3CleanInterval)
+		defer ticker.Stop()
+		for {
+			server.cleanStaleUploads()
+			<-ticker.C
+		}
+	}()
+
+	logwrapper.Logger.Infof("S3 server is running on %s", address)
+	return http.ListenAndServe(address, server)
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 校验签名
+	user, err := s.authenticate(r)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+
+	// 路径格式: /bucket/key
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case bucket == "":
+		if r.Method == http.MethodGet {
+			err = s.listBuckets(w, user)
+		} else {
+			err = errS3NotImplemented
+		}
+	case key == "":
+		err = s.serveBucket(w, r, bucket, query)
+	default:
+		err = s.serveObject(w, r, bucket, key, query)
+	}
+
+	if err != nil {
+		writeS3Error(w, r, err)
+	}
+}
+
+/**
+ * @description: 校验签名并返回访问密钥所属用户
+ * @param {*http.Request} r
+ * @return {string} 用户名
+ */
+func (s *s3Server) authenticate(r *http.Request) (string, error) {
+	var user string
+	_, err := verifySigV4(r, func(accessKeyID string) (string, error) {
+		accessKey, err := dbwrapper.QueryAccessKey(accessKeyID)
+		if err == dbwrapper.ErrAccessKeyNotExist {
+			return "", &s3Error{http.StatusForbidden, "InvalidAccessKeyId", "The access key ID you provided does not exist in our records."}
+		} else if err != nil {
+			return "", err
+		}
+		user = accessKey.UserName
+		return accessKey.SecretAccessKey, nil
+	})
+
+	switch err {
+	case nil:
+		return user, nil
+	case errSigV4Missing, errSigV4Malformed:
+		return "", &s3Error{http.StatusForbidden, "AccessDenied", err.Error()}
+	case errSigV4Mismatch:
+		return "", &s3Error{http.StatusForbidden, "SignatureDoesNotMatch", err.Error()}
+	case errSigV4Expired:
+		return "", &s3Error{http.StatusForbidden, "RequestTimeTooSkewed", err.Error()}
+	case errSigV4Unsupported:
+		return "", errS3NotImplemented
+	}
+	return "", err
+}
+
+/**
+ * @description: 处理bucket级别的请求
+ * @return {*}
+ */
+func (s *s3Server) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, query url.Values) error {
+	switch {
+	case r.Method == http.MethodPut:
+		return s.createBucket(w, bucket)
+	case r.Method == http.MethodDelete:
+		return s.deleteBucket(w, bucket)
+	case r.Method == http.MethodHead:
+		_, err := s.bucketFolder(bucket)
+		return err
+	case r.Method == http.MethodPost && query.Has("delete"):
+		return s.deleteObjects(w, r, bucket)
+	case r.Method == http.MethodGet && query.Has("location"):
+		if _, err := s.bucketFolder(bucket); err != nil {
+			return err
+		}
+		type LocationConstraint struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Xmlns   string   `xml:"xmlns,attr"`
+			Region  string   `xml:",chardata"`
+		}
+		return writeS3XML(w, http.StatusOK, LocationConstraint{Xmlns: s3XMLNamespace, Region: s.region})
+	case r.Method == http.MethodGet:
+		return s.listObjects(w, bucket, query)
+	}
+	return errS3NotImplemented
+}
+
+/**
+ * @description: 处理object级别的请求
+ * @return {*}
+ */
+func (s *s3Server) serveObject(w http.ResponseWriter, r *http.Request, bucket string, key string, query url.Values) error {
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		return s.createMultipartUpload(w, bucket, key)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		return s.completeMultipartUpload(w, r, bucket, key, query.Get("uploadId"))
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		return s.uploadPart(w, r, bucket, key, query.Get("uploadId"), query.Get("partNumber"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		return s.abortMultipartUpload(w, bucket, key, query.Get("uploadId"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		return s.copyObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		return s.putObject(w, r, bucket, key)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		return s.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		if err := s.deleteObject(bucket, key); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return errS3NotImplemented
+}
+
+/**
+ * @description: 查询bucket对应的文件夹
+ * @param {string} bucket bucket名称
+ * @return {*dto.Folder}
+ */
+func (s *s3Server) bucketFolder(bucket string) (*dto.Folder, error) {
+	if bucket == "." || bucket == ".." {
+		return nil, errS3NoSuchBucket
+	}
+
+	folder, err := dbwrapper.QueryFolderInfoByPath("/" + bucket)
+	if err == dbwrapper.ErrFolderNotExist {
+		return nil, errS3NoSuchBucket
+	}
+	return folder, err
+}
+
+/**
+ * @description: 将key转换为CloudDisk中的路径，以/结尾的key表示文件夹
+ * @param {string} bucket bucket名称
+ * @param {string} key 对象key
+ * @return {string} 路径
+ * @return {bool} 是否是文件夹
+ */
+func s3ObjectPath(bucket string, key string) (string, bool, error) {
+	isFolder := strings.HasSuffix(key, "/")
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", false, errS3InvalidKey
+		}
+	}
+	return "/" + bucket + "/" + strings.TrimSuffix(key, "/"), isFolder, nil
+}
+
+/**
+ * @description: 确保路径上的所有文件夹都存在，不存在则依次创建
+ * @param {string} folderPath 文件夹路径
+ * @return {*dto.Folder} 最后一级文件夹
+ */
+func ensureFolderPath(folderPath string) (*dto.Folder, error) {
+	folder, err := dbwrapper.QueryFolderInfoByPath(folderPath)
+	if err != dbwrapper.ErrFolderNotExist {
+		return folder, err
+	}
+
+	// 路径上存在同名文件时无法创建文件夹
+	if exists, err := dbwrapper.FileExistByPath(folderPath); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errS3KeyConflict
+	}
+
+	parent, err := ensureFolderPath(path.Dir(folderPath))
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err = createFolder(parent.ID, path.Base(folderPath))
+	if err == dbwrapper.ErrFolderExist {
+		// 并发创建时可能已被其他请求创建
+		return dbwrapper.QueryFolderInfoByPath(folderPath)
+	}
+	return folder, err
+}
+
+/**
+ * @description: 写入对象内容，父文件夹不存在时自动创建，已存在时按overwrite策略覆盖
+ * @param {string} objectPath 对象路径
+ * @param {io.Reader} src 对象内容
+ * @return {*dto.File} 文件信息
+ */
+func writeS3Object(objectPath string, src io.Reader) (*dto.File, error) {
+	if exists, err := dbwrapper.FolderExistByPath(objectPath); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errS3KeyConflict
+	}
+
+	parent, err := ensureFolderPath(path.Dir(objectPath))
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查和覆盖在同一把名称锁内完成，并发写入同一对象时不会因文件已存在而失败
+	file, _, err := saveFileWithPolicy(parent.ID, path.Base(objectPath), src, conflictOverwrite)
+	if err == dbwrapper.ErrFolderExist {
+		return nil, errS3KeyConflict
+	}
+	return file, err
+}
+
+/**
+ * @description: 生成文件的ETag，非上传时计算的ETag不是内容的MD5，带-1后缀避免客户端误用
+ * @param {*dto.File} file 文件信息
+ * @return {string} ETag
+ */
+func s3FileETag(file *dto.File) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%d-%d-%d", file.ID, file.UpdatedAt.UnixNano(), file.Size)))
+	return `"` + hex.EncodeToString(sum[:]) + `-1"`
+}
+
+func (s *s3Server) listBuckets(w http.ResponseWriter, user string) error {
+	queryResult, err := dbwrapper.QueryFolderInfoFull(1, dbwrapper.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	type Bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	type ListAllMyBucketsResult struct {
+		XMLName     xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns       string   `xml:"xmlns,attr"`
+		OwnerID     string   `xml:"Owner>ID"`
+		DisplayName string   `xml:"Owner>DisplayName"`
+		Buckets     []Bucket `xml:"Buckets>Bucket"`
+	}
+	result := ListAllMyBucketsResult{Xmlns: s3XMLNamespace, OwnerID: user, DisplayName: user}
+	for _, folder := range queryResult.Folders {
+		result.Buckets = append(result.Buckets, Bucket{Name: folder.Name, CreationDate: folder.CreatedAt.UTC().Format(s3TimeFormat)})
+	}
+	return writeS3XML(w, http.StatusOK, result)
+}
+
+func (s *s3Server) createBucket(w http.ResponseWriter, bucket string) error {
+	if bucket == "." || bucket == ".." {
+		return &s3Error{http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid."}
+	}
+
+	if _, err := createFolder(1, bucket); err == dbwrapper.ErrFolderExist {
+		return errS3BucketExists
+	} else if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *s3Server) deleteBucket(w http.ResponseWriter, bucket string) error {
+	folder, err := s.bucketFolder(bucket)
+	if err != nil {
+		return err
+	}
+
+	// 只允许删除空bucket
+	queryResult, err := dbwrapper.QueryFolderInfoFull(folder.ID, dbwrapper.ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if queryResult.Total > 0 {
+		return errS3BucketNotEmpty
+	}
+
+	if err := deleteFolder(folder.ID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *s3Server) listObjects(w http.ResponseWriter, bucket string, query url.Values) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+
+	var (
+		prefix    = norm.NFC.String(query.Get("prefix"))
+		delimiter = query.Get("delimiter")
+		isV2      = query.Get("list-type") == "2"
+		urlEncode = query.Get("encoding-type") == "url"
+		maxKeys   = s3MaxKeys
+		marker    string
+	)
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return &s3Error{http.StatusBadRequest, "InvalidArgument", "Invalid max-keys."}
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	// 起始位置：v2使用continuation-token或start-after，v1使用marker
+	if isV2 {
+		marker = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return &s3Error{http.StatusBadRequest, "InvalidArgument", "Invalid continuation token."}
+			}
+			marker = string(decoded)
+		}
+	} else {
+		marker = query.Get("marker")
+	}
+
+	// 查询bucket下路径匹配前缀的全部文件夹和文件，文件夹以/结尾的key表示
+	bucketPrefix := "/" + bucket + "/"
+	folders, files, err := dbwrapper.QueryByPathPrefix(bucketPrefix + prefix)
+	if err != nil {
+		return err
+	}
+	objects := map[string]*dto.File{}
+	var keys []string
+	for i := range folders {
+		key := strings.TrimPrefix(folders[i].Path, bucketPrefix) + "/"
+		objects[key] = &dto.File{ID: folders[i].ID, Type: dto.FileTypeFolder, UpdatedAt: folders[i].UpdatedAt}
+		keys = append(keys, key)
+	}
+	for i := range files {
+		key := strings.TrimPrefix(files[i].Path, bucketPrefix)
+		objects[key] = &files[i]
+		keys = append(keys, key)
+	}
+	// 数据库的LIKE可能不区分大小写，按前缀精确过滤后以字节序排序
+	sort.Strings(keys)
+
+	type Contents struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	type CommonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	var (
+		contents      []Contents
+		commonPrefix  []CommonPrefix
+		lastEmitted   string
+		isTruncated   bool
+		encode        = func(s string) string { return s }
+		lastCommonPfx string
+	)
+	if urlEncode {
+		encode = url.QueryEscape
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// 按分隔符合并为公共前缀
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				cp := key[:len(prefix)+idx+len(delimiter)]
+				if cp == lastCommonPfx || cp <= marker {
+					continue
+				}
+				if len(contents)+len(commonPrefix) >= maxKeys {
+					isTruncated = true
+					break
+				}
+				commonPrefix = append(commonPrefix, CommonPrefix{Prefix: encode(cp)})
+				lastCommonPfx, lastEmitted = cp, cp
+				continue
+			}
+		}
+
+		if key <= marker {
+			continue
+		}
+		if len(contents)+len(commonPrefix) >= maxKeys {
+			isTruncated = true
+			break
+		}
+
+		object := objects[key]
+		etag := `"d41d8cd98f00b204e9800998ecf8427e"` // 空内容的MD5
+		if object.Type == dto.FileTypeFile {
+			etag = s3FileETag(object)
+		}
+		contents = append(contents, Contents{
+			Key:          encode(key),
+			LastModified: object.UpdatedAt.UTC().Format(s3TimeFormat),
+			ETag:         etag,
+			Size:         object.Size,
+			StorageClass: "STANDARD",
+		})
+		lastEmitted = key
+	}
+
+	type ListBucketResult struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Xmlns                 string         `xml:"xmlns,attr"`
+		Name                  string         `xml:"Name"`
+		Prefix                string         `xml:"Prefix"`
+		Delimiter             string         `xml:"Delimiter,omitempty"`
+		MaxKeys               int            `xml:"MaxKeys"`
+		EncodingType          string         `xml:"EncodingType,omitempty"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		Marker                *string        `xml:"Marker"`
+		NextMarker            string         `xml:"NextMarker,omitempty"`
+		KeyCount              *int           `xml:"KeyCount"`
+		ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+		StartAfter            string         `xml:"StartAfter,omitempty"`
+		Contents              []Contents     `xml:"Contents"`
+		CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes"`
+	}
+	result := ListBucketResult{
+		Xmlns:          s3XMLNamespace,
+		Name:           bucket,
+		Prefix:         encode(prefix),
+		Delimiter:      encode(delimiter),
+		MaxKeys:        maxKeys,
+		IsTruncated:    isTruncated,
+		Contents:       contents,
+		CommonPrefixes: commonPrefix,
+	}
+	if urlEncode {
+		result.EncodingType = "url"
+	}
+	if isV2 {
+		keyCount := len(contents) + len(commonPrefix)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = encode(query.Get("start-after"))
+		if isTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(lastEmitted))
+		}
+	} else {
+		result.Marker = &marker
+		if isTruncated {
+			result.NextMarker = encode(lastEmitted)
+		}
+	}
+	return writeS3XML(w, http.StatusOK, result)
+}
+
+func (s *s3Server) putObject(w http.ResponseWriter, r *http.Request, bucket string, key string) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+	objectPath, isFolder, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	// 以/结尾的key创建文件夹
+	hasher := md5.New()
+	if isFolder {
+		io.Copy(hasher, r.Body)
+		if _, err := ensureFolderPath(objectPath); err != nil {
+			return err
+		}
+	} else if _, err := writeS3Object(objectPath, io.TeeReader(r.Body, hasher)); err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hasher.Sum(nil))+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *s3Server) copyObject(w http.ResponseWriter, r *http.Request, bucket string, key string) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+	objectPath, isFolder, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if isFolder {
+		return errS3InvalidKey
+	}
+
+	// 复制源格式: /bucket/key 或 bucket/key，可能经过URL编码
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return errS3InvalidKey
+	}
+	source, _, _ = strings.Cut(source, "?")
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	sourcePath, _, err := s3ObjectPath(sourceBucket, sourceKey)
+	if err != nil {
+		return err
+	}
+	sourceFile, err := dbwrapper.QueryFileInfoByPath(sourcePath)
+	if err == dbwrapper.ErrFileNotExist {
+		return errS3NoSuchKey
+	} else if err != nil {
+		return err
+	}
+
+	// 复制内容
+	localFile, err := os.Open(path.Join(GetBaseFolderPath(), sourceFile.Path))
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+	hasher := md5.New()
+	file, err := writeS3Object(objectPath, io.TeeReader(localFile, hasher))
+	if err != nil {
+		return err
+	}
+
+	type CopyObjectResult struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		Xmlns        string   `xml:"xmlns,attr"`
+		LastModified string   `xml:"LastModified"`
+		ETag         string   `xml:"ETag"`
+	}
+	return writeS3XML(w, http.StatusOK, CopyObjectResult{
+		Xmlns:        s3XMLNamespace,
+		LastModified: file.UpdatedAt.UTC().Format(s3TimeFormat),
+		ETag:         `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`,
+	})
+}
+
+func (s *s3Server) getObject(w http.ResponseWriter, r *http.Request, bucket string, key string) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+	objectPath, isFolder, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	// 文件夹作为空对象返回
+	if isFolder {
+		folder, err := dbwrapper.QueryFolderInfoByPath(objectPath)
+		if err == dbwrapper.ErrFolderNotExist {
+			return errS3NoSuchKey
+		} else if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.Header().Set("Last-Modified", folder.UpdatedAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	file, err := dbwrapper.QueryFileInfoByPath(objectPath)
+	if err == dbwrapper.ErrFileNotExist {
+		return errS3NoSuchKey
+	} else if err != nil {
+		return err
+	}
+
+	localFile, err := os.Open(path.Join(GetBaseFolderPath(), file.Path))
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+
+	// ServeContent负责Range、HEAD和条件请求
+	contentType := mime.TypeByExtension(path.Ext(file.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", s3FileETag(file))
+	http.ServeContent(w, r, file.Name, file.UpdatedAt, localFile)
+	return nil
+}
+
+/**
+ * @description: 删除对象，对象不存在时也视为成功；文件夹只在为空时删除，避免误删其中的对象
+ * @param {string} bucket bucket名称
+ * @param {string} key 对象key
+ * @return {*}
+ */
+func (s *s3Server) deleteObject(bucket string, key string) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+	objectPath, isFolder, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	if isFolder {
+		folder, err := dbwrapper.QueryFolderInfoByPath(objectPath)
+		if err == dbwrapper.ErrFolderNotExist {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		queryResult, err := dbwrapper.QueryFolderInfoFull(folder.ID, dbwrapper.ListOptions{Limit: 1})
+		if err != nil || queryResult.Total > 0 {
+			return err
+		}
+		return deleteFolder(folder.ID)
+	}
+
+	file, err := dbwrapper.QueryFileInfoByPath(objectPath)
+	if err == dbwrapper.ErrFileNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return deleteFile(file.ID)
+}
+
+func (s *s3Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+
+	type Delete struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	var req Delete
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 2<<20)).Decode(&req); err != nil {
+		return errS3MalformedXML
+	}
+
+	type Deleted struct {
+		Key string `xml:"Key"`
+	}
+	type DeleteError struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	type DeleteResult struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Xmlns   string        `xml:"xmlns,attr"`
+		Deleted []Deleted     `xml:"Deleted"`
+		Errors  []DeleteError `xml:"Error"`
+	}
+	result := DeleteResult{Xmlns: s3XMLNamespace}
+	for _, object := range req.Objects {
+		if err := s.deleteObject(bucket, object.Key); err != nil {
+			code := "InternalError"
+			if s3Err, ok := err.(*s3Error); ok {
+				code = s3Err.code
+			}
+			result.Errors = append(result.Errors, DeleteError{Key: object.Key, Code: code, Message: err.Error()})
+		} else if !req.Quiet {
+			result.Deleted = append(result.Deleted, Deleted{Key: object.Key})
+		}
+	}
+	return writeS3XML(w, http.StatusOK, result)
+}
+
+// 分片上传的信息，保存在暂存目录中
+type s3MultipartUpload struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	Initiated time.Time `json:"initiated"`
+}
+
+/**
+ * @description: 读取分片上传信息并检查与请求的bucket和key是否一致
+ * @return {string} 分片上传的暂存目录
+ */
+func (s *s3Server) loadMultipartUpload(bucket string, key string, uploadID string) (string, error) {
+	// uploadID由服务端生成，只包含十六进制字符
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", errS3NoSuchUpload
+	}
+
+	uploadFolder := path.Join(s.stagingFolder, uploadID)
+	data, err := os.ReadFile(path.Join(uploadFolder, "upload.json"))
+	if err != nil {
+		return "", errS3NoSuchUpload
+	}
+
+	var upload s3MultipartUpload
+	if err := json.Unmarshal(data, &upload); err != nil || upload.Bucket != bucket || upload.Key != key {
+		return "", errS3NoSuchUpload
+	}
+	return uploadFolder, nil
+}
+
+func (s *s3Server) createMultipartUpload(w http.ResponseWriter, bucket string, key string) error {
+	if _, err := s.bucketFolder(bucket); err != nil {
+		return err
+	}
+	if _, isFolder, err := s3ObjectPath(bucket, key); err != nil {
+		return err
+	} else if isFolder {
+		return errS3InvalidKey
+	}
+
+	// 创建暂存目录
+	uploadID, err := randomString(32, "0123456789abcdef")
+	if err != nil {
+		return err
+	}
+	uploadFolder := path.Join(s.stagingFolder, uploadID)
+	if err := os.MkdirAll(uploadFolder, os.ModePerm); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(s3MultipartUpload{Bucket: bucket, Key: key, Initiated: time.Now()})
+	if err := os.WriteFile(path.Join(uploadFolder, "upload.json"), data, 0644); err != nil {
+		return err
+	}
+
+	type InitiateMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}
+	return writeS3XML(w, http.StatusOK, InitiateMultipartUploadResult{Xmlns: s3XMLNamespace, Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (s *s3Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket string, key string, uploadID string, partNumberStr string) error {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		return errS3NotImplemented
+	}
+
+	uploadFolder, err := s.loadMultipartUpload(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	partNumber, err := strconv.Atoi(partNumberStr)
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		return &s3Error{http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000."}
+	}
+
+	// 先写入临时文件，完整写入后再重命名，保证分片不会是半成品
+	tempFile, err := os.CreateTemp(uploadFolder, "part-*")
+	if err != nil {
+		return err
+	}
+	defer RemoveFileIgnoreNotExist(tempFile.Name())
+	hasher := md5.New()
+	_, err = io.Copy(io.MultiWriter(tempFile, hasher), r.Body)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	etag := hex.EncodeToString(hasher.Sum(nil))
+	partPath := path.Join(uploadFolder, strconv.Itoa(partNumber))
+	if err := os.Rename(tempFile.Name(), partPath+".part"); err != nil {
+		return err
+	}
+	if err := os.WriteFile(partPath+".md5", []byte(etag), 0644); err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *s3Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, uploadID string) error {
+	uploadFolder, err := s.loadMultipartUpload(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	objectPath, _, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	type CompleteMultipartUpload struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	var req CompleteMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 2<<20)).Decode(&req); err != nil || len(req.Parts) == 0 {
+		return errS3MalformedXML
+	}
+
+	// 检查分片顺序和ETag，按顺序拼接
+	var (
+		readers []io.Reader
+		md5s    []byte
+	)
+	for i, part := range req.Parts {
+		if i > 0 && part.PartNumber <= req.Parts[i-1].PartNumber {
+			return errS3InvalidPartOrder
+		}
+
+		partPath := path.Join(uploadFolder, strconv.Itoa(part.PartNumber))
+		etag, err := os.ReadFile(partPath + ".md5")
+		if err != nil || string(etag) != strings.Trim(part.ETag, `"`) {
+			return errS3InvalidPart
+		}
+		sum, _ := hex.DecodeString(string(etag))
+		md5s = append(md5s, sum...)
+
+		partFile, err := os.Open(partPath + ".part")
+		if err != nil {
+			return errS3InvalidPart
+		}
+		defer partFile.Close()
+		readers = append(readers, partFile)
+	}
+
+	if _, err := writeS3Object(objectPath, io.MultiReader(readers...)); err != nil {
+		return err
+	}
+
+	// 上传完成，删除暂存目录
+	if err := os.RemoveAll(uploadFolder); err != nil {
+		logwrapper.Logger.Warnf("Failed to clean up multipart upload %s: %v", uploadID, err)
+	}
+
+	sum := md5.Sum(md5s)
+	type CompleteMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}
+	return writeS3XML(w, http.StatusOK, CompleteMultipartUploadResult{
+		Xmlns:    s3XMLNamespace,
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(req.Parts)),
+	})
+}
+
+func (s *s3Server) abortMultipartUpload(w http.ResponseWriter, bucket string, key string, uploadID string) error {
+	uploadFolder, err := s.loadMultipartUpload(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(uploadFolder); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/**
+ * @description: 删除超时未完成的分片上传
+ * @return {*}
+ */
+func (s *s3Server) cleanStaleUploads() {
+	entries, err := os.ReadDir(s.stagingFolder)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > s3MultipartExpiry {
+			os.RemoveAll(path.Join(s.stagingFolder, entry.Name()))
+		}
+	}
+}
+
+/**
+ * @description: 写入XML响应
+ * @return {*}
+ */
+func writeS3XML(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	return xml.NewEncoder(w).Encode(v)
+}
+
+/**
+ * @description: 写入S3格式的错误响应，非S3错误按业务错误转换
+ * @return {*}
+ */
+func writeS3Error(w http.ResponseWriter, r *http.Request, err error) {
+	var s3Err *s3Error
+	switch {
+	case errors.As(err, &s3Err):
+	case errors.Is(err, errSigV4BadDigest):
+		s3Err = &s3Error{http.StatusBadRequest, "XAmzContentSHA256Mismatch", err.Error()}
+	case errors.Is(err, errSigV4BadChecksum):
+		s3Err = &s3Error{http.StatusBadRequest, "BadDigest", err.Error()}
+	case errors.Is(err, errSigV4Mismatch):
+		// 读取负载时块签名不符
+		s3Err = &s3Error{http.StatusForbidden, "SignatureDoesNotMatch", err.Error()}
+	case err == dbwrapper.ErrFileExist || err == dbwrapper.ErrFolderExist:
+		s3Err = errS3KeyConflict
+	default:
+		logwrapper.Logger.Errorf("S3 %s %s failed: %v", r.Method, r.URL.Path, err)
+		s3Err = &s3Error{http.StatusInternalServerError, "InternalError", err.Error()}
+	}
+
+	type Error struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string   `xml:"Code"`
+		Message  string   `xml:"Message"`
+		Resource string   `xml:"Resource"`
+	}
+	// HEAD请求不能有响应体
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.status)
+		return
+	}
+	writeS3XML(w, s3Err.status, Error{Code: s3Err.code, Message: s3Err.message, Resource: r.URL.Path})
+}
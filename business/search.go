@@ -0,0 +1,104 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 10:25:47
+ * @LastEditTime: 2026-10-19 09:12:37
+ * @FilePath: \CloudDisk\business\search.go
+ * @Description: 搜索接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+/**
+ * @description: 搜索文件/文件夹api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func Search(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 解析请求体
+	type SearchRequest struct {
+		Keyword        string            `json:"keyword"`
+		Mode           string            `json:"mode"`    // substring(默认)/glob
+		MatchIn        string            `json:"matchIn"` // name(默认)/path
+		Type           string            `json:"type"`    // file/folder，为空时都搜索
+		MinSize        *int64            `json:"minSize"`
+		MaxSize        *int64            `json:"maxSize"`
+		ModifiedAfter  *time.Time        `json:"modifiedAfter"`
+		ModifiedBefore *time.Time        `json:"modifiedBefore"`
+		RootFolderID   int64             `json:"rootFolderID"`
+		Tags           []string          `json:"tags"`
+		Metadata       map[string]string `json:"metadata"`
+		SortBy         string            `json:"sortBy"`
+		Order          string            `json:"order"` // asc(默认)/desc
+		Page           int               `json:"page"`
+		PageSize       int               `json:"pageSize"`
+	}
+	var req SearchRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 检查参数
+	if req.Mode != "" && req.Mode != "substring" && req.Mode != "glob" {
+		http.Error(w, "Invalid mode", http.StatusBadRequest)
+		return
+	}
+	if req.MatchIn != "" && req.MatchIn != "name" && req.MatchIn != "path" {
+		http.Error(w, "Invalid matchIn", http.StatusBadRequest)
+		return
+	}
+	if req.Type != "" && req.Type != "file" && req.Type != "folder" {
+		http.Error(w, "Invalid type", http.StatusBadRequest)
+		return
+	}
+	if req.Order != "" && req.Order != "asc" && req.Order != "desc" {
+		http.Error(w, "Invalid order", http.StatusBadRequest)
+		return
+	}
+
+	// 未指定根目录时搜索整棵树
+	if req.RootFolderID == 0 {
+		req.RootFolderID = 1
+	}
+
+	// 搜索
+	searchResult, err := dbwrapper.Search(dbwrapper.SearchOptions{
+		Keyword:        req.Keyword,
+		Glob:           req.Mode == "glob",
+		MatchPath:      req.MatchIn == "path",
+		Type:           req.Type,
+		MinSize:        req.MinSize,
+		MaxSize:        req.MaxSize,
+		ModifiedAfter:  req.ModifiedAfter,
+		ModifiedBefore: req.ModifiedBefore,
+		RootFolderID:   req.RootFolderID,
+		Tags:           req.Tags,
+		Metadata:       req.Metadata,
+		SortBy:         req.SortBy,
+		Desc:           req.Order == "desc",
+		Page:           req.Page,
+		PageSize:       req.PageSize,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), operationStatus(err))
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(searchResult)
+}
@@ -0,0 +1,412 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 18:31:44
+ * @LastEditTime: 2026-10-19 11:58:20
+ * @FilePath: \CloudDisk\business\sftp.go
+ * @Description: SFTP服务，文件操作与JSON接口共用业务操作，支持密码和SSH公钥认证
+ */
+package business
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/dbwrapper"
+	"CloudDisk/logwrapper"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var errFolderNotEmpty = errors.New("folder is not empty")
+
+/**
+ * @description: 启动SFTP服务
+ * @param {string} address 监听地址
+ * @param {string} hostKeyFile 主机私钥文件，不存在时自动生成
+ * @return {*}
+ */
+func ServeSFTP(address string, hostKeyFile string) error {
+	if hostKeyFile == "" {
+		hostKeyFile = "./sftp_host_key"
+	}
+	hostKey, err := loadOrCreateHostKey(hostKeyFile)
+	if err != nil {
+		return err
+	}
+
+	// 未配置用户时不需要认证，与HTTP接口保持一致
+	serverConfig := &ssh.ServerConfig{
+		NoClientAuth: len(configwrapper.Cfg.Users) == 0,
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if checkUserPassword(conn.User(), string(password)) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if checkUserPublicKey(conn.User(), key) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("public key rejected for %s", conn.User())
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	logwrapper.Logger.Infof("SFTP server is running on %s", address)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleSSHConn(conn, serverConfig)
+	}
+}
+
+/**
+ * @description: 读取主机私钥，文件不存在时生成ed25519私钥并保存
+ * @param {string} hostKeyFile 主机私钥文件
+ * @return {ssh.Signer}
+ */
+func loadOrCreateHostKey(hostKeyFile string) (ssh.Signer, error) {
+	keyData, err := os.ReadFile(hostKeyFile)
+	if err == nil {
+		return ssh.ParsePrivateKey(keyData)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "CloudDisk SFTP host key")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(hostKeyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	logwrapper.Logger.Infof("Generated SFTP host key %s", hostKeyFile)
+
+	return ssh.NewSignerFromKey(privateKey)
+}
+
+/**
+ * @description: 处理SSH连接，只接受session通道上的sftp子系统请求
+ * @param {net.Conn} conn 网络连接
+ * @param {*ssh.ServerConfig} serverConfig SSH服务配置
+ * @return {*}
+ */
+func handleSSHConn(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		logwrapper.Logger.Warnf("SSH handshake from %s failed: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	defer sshConn.Close()
+	logwrapper.Logger.Infof("SFTP user %q logged in from %s", sshConn.User(), sshConn.RemoteAddr())
+
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			logwrapper.Logger.Warnf("Failed to accept SSH channel: %v", err)
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				// 只支持sftp子系统，旧版scp协议需要执行远程命令，不支持
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+
+				server := sftp.NewRequestServer(channel, sftp.Handlers{
+					FileGet:  sftpHandler{},
+					FilePut:  sftpHandler{},
+					FileCmd:  sftpHandler{},
+					FileList: sftpHandler{},
+				})
+				if err := server.Serve(); err != nil && err != io.EOF {
+					logwrapper.Logger.Warnf("SFTP session of %q ended: %v", sshConn.User(), err)
+				}
+				server.Close()
+				return
+			}
+		}()
+	}
+}
+
+/**
+ * @description: 将业务错误转换为SFTP状态码能识别的错误
+ * @param {error} err 业务错误
+ * @return {error}
+ */
+func sftpError(err error) error {
+	err = osError(err)
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return sftp.ErrSSHFxPermissionDenied
+	case errors.Is(err, os.ErrExist), err == errFolderNotEmpty:
+		return sftp.ErrSSHFxFailure
+	}
+	return err
+}
+
+// 实现sftp.Handlers，所有修改操作都走与JSON接口相同的业务操作
+type sftpHandler struct{}
+
+func (sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	_, file, err := lookupPath(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, sftp.ErrSSHFxFailure
+	}
+
+	return os.Open(path.Join(GetBaseFolderPath(), file.Path))
+}
+
+func (sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	folder, file, err := lookupPath(r.Filepath)
+	if err != nil && err != os.ErrNotExist {
+		return nil, err
+	}
+
+	// 文件夹不能写入
+	flags := r.Pflags()
+	if folder != nil {
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+	if file != nil && flags.Excl {
+		return nil, sftp.ErrSSHFxFailure
+	}
+	if file == nil && !flags.Creat {
+		return nil, os.ErrNotExist
+	}
+
+	parent, err := lookupParent(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 客户端可能乱序写入，先写入临时文件，关闭时再交给业务操作
+	tempFile, err := os.CreateTemp("", "clouddisk-sftp-*")
+	if err != nil {
+		return nil, err
+	}
+	writeFile := &sftpWriteFile{File: tempFile, name: path.Base(r.Filepath), parentFolderID: parent.ID}
+
+	// 不截断时保留原有内容，支持断点续传
+	if file != nil {
+		writeFile.fileID = file.ID
+		if !flags.Trunc {
+			if err := copyLocalFile(tempFile, path.Join(GetBaseFolderPath(), file.Path)); err != nil {
+				tempFile.Close()
+				RemoveFileIgnoreNotExist(tempFile.Name())
+				return nil, err
+			}
+		}
+	}
+	return writeFile, nil
+}
+
+func (sftpHandler) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		// 不支持修改权限和时间，忽略以兼容客户端的put -p
+		return nil
+	case "Mkdir":
+		if folder, file, err := lookupPath(r.Filepath); err == nil && (folder != nil || file != nil) {
+			return sftp.ErrSSHFxFailure
+		}
+		parent, err := lookupParent(r.Filepath)
+		if err != nil {
+			return err
+		}
+		_, err = createFolder(parent.ID, path.Base(r.Filepath))
+		return sftpError(err)
+	case "Rmdir":
+		folder, _, err := lookupPath(r.Filepath)
+		if err != nil {
+			return err
+		}
+		if folder == nil {
+			return sftp.ErrSSHFxFailure
+		}
+		// rmdir只能删除空文件夹
+		queryResult, err := dbwrapper.QueryFolderInfoFull(folder.ID, dbwrapper.ListOptions{Limit: 1})
+		if err != nil {
+			return err
+		}
+		if queryResult.Total > 0 {
+			return sftpError(errFolderNotEmpty)
+		}
+		return sftpError(deleteFolder(folder.ID))
+	case "Remove":
+		_, file, err := lookupPath(r.Filepath)
+		if err != nil {
+			return err
+		}
+		if file == nil {
+			return sftp.ErrSSHFxFailure
+		}
+		return sftpError(deleteFile(file.ID))
+	case "Rename":
+		return sftpRename(r.Filepath, r.Target, false)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (sftpHandler) PosixRename(r *sftp.Request) error {
+	return sftpRename(r.Filepath, r.Target, true)
+}
+
+/**
+ * @description: 移动文件或文件夹
+ * @param {string} oldPath 原路径
+ * @param {string} newPath 新路径
+ * @param {bool} replace 目标文件已存在时是否替换
+ * @return {*}
+ */
+func sftpRename(oldPath string, newPath string, replace bool) error {
+	folder, file, err := lookupPath(oldPath)
+	if err != nil {
+		return err
+	}
+	parent, err := lookupParent(newPath)
+	if err != nil {
+		return err
+	}
+
+	if folder != nil {
+		return sftpError(moveFolder(folder.ID, parent.ID, path.Base(newPath)))
+	}
+
+	// posix-rename语义下按overwrite策略替换已存在的目标文件，移动失败时目标文件保持原样
+	policy := conflictFail
+	if replace {
+		policy = conflictOverwrite
+	}
+	_, err = moveFileWithPolicy(file.ID, parent.ID, path.Base(newPath), policy)
+	return sftpError(err)
+}
+
+func (sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	folder, file, err := lookupPath(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		if folder == nil {
+			return nil, sftp.ErrSSHFxFailure
+		}
+		queryResult, err := dbwrapper.QueryFolderInfoFull(folder.ID, dbwrapper.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var list sftpLister
+		for i := range queryResult.Folders {
+			list = append(list, folderFileInfo(&queryResult.Folders[i]))
+		}
+		for i := range queryResult.Files {
+			list = append(list, fileFileInfo(&queryResult.Files[i]))
+		}
+		return list, nil
+	case "Stat":
+		if folder != nil {
+			return sftpLister{folderFileInfo(folder)}, nil
+		}
+		return sftpLister{fileFileInfo(file)}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// 实现sftp.ListerAt
+type sftpLister []os.FileInfo
+
+func (l sftpLister) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// 写入打开的文件，内容暂存在临时文件中
+type sftpWriteFile struct {
+	*os.File
+	name           string
+	parentFolderID int64
+	fileID         int64 // 覆盖已有文件时不为0
+}
+
+/**
+ * @description: 写入完成，将临时文件内容保存为新文件或覆盖已有文件
+ * @return {*}
+ */
+func (f *sftpWriteFile) Close() error {
+	defer RemoveFileIgnoreNotExist(f.File.Name())
+
+	if _, err := f.File.Seek(0, io.SeekStart); err != nil {
+		f.File.Close()
+		return err
+	}
+	defer f.File.Close()
+
+	var err error
+	if f.fileID != 0 {
+		_, err = overwriteFile(f.fileID, f.File)
+	} else {
+		_, err = saveFile(f.parentFolderID, f.name, f.File)
+	}
+	if err != nil {
+		logwrapper.Logger.Errorf("SFTP write %s failed: %v", f.name, err)
+	}
+	return sftpError(err)
+}
+
+/**
+ * @description: 将本地文件内容复制到dst
+ * @param {io.Writer} dst 目标
+ * @param {string} localPath 本地文件路径
+ * @return {*}
+ */
+func copyLocalFile(dst io.Writer, localPath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
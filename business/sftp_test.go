@@ -0,0 +1,71 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 11:58:20
+ * @LastEditTime: 2026-10-19 11:58:20
+ * @FilePath: \CloudDisk\business\sftp_test.go
+ * @Description: SFTP重命名的测试
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSFTPPosixRenameReplaces(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := saveFile(parentID, "b.txt", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 普通rename不替换已存在的目标
+	if err := sftpRename(src.Path, target.Path, false); err == nil {
+		t.Fatal("rename over an existing file succeeded")
+	}
+	assertFileContent(t, target.Path, "old")
+
+	if err := sftpRename(src.Path, target.Path, true); err != nil {
+		t.Fatalf("posix-rename: %v", err)
+	}
+	if _, err := dbwrapper.QueryFileInfo(target.ID); err != dbwrapper.ErrFileNotExist {
+		t.Errorf("replaced file still in database: %v", err)
+	}
+	assertFileContent(t, target.Path, "new")
+	assertFolderEntries(t, parentID, "b.txt")
+}
+
+func TestSFTPPosixRenameFailureKeepsTarget(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := saveFile(parentID, "b.txt", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 源文件的本地文件丢失时移动失败，目标文件不能先被删除
+	if err := os.Remove(path.Join(GetBaseFolderPath(), src.Path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sftpRename(src.Path, target.Path, true); err == nil {
+		t.Fatal("posix-rename of a missing local file succeeded")
+	}
+	if _, err := dbwrapper.QueryFileInfo(target.ID); err != nil {
+		t.Errorf("target file removed from database: %v", err)
+	}
+	assertFileContent(t, target.Path, "old")
+}
@@ -0,0 +1,473 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 17:20:14
+ * @LastEditTime: 2026-10-19 18:21:47
+ * @FilePath: \CloudDisk\business\sigv4.go
+ * @Description: AWS Signature Version 4 签名校验
+ */
+package business
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// aws-chunked编码的负载，每个块带有以种子签名为起点的链式签名
+	sigV4StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// aws-chunked编码的负载，块不签名，最后是校验和trailer
+	sigV4StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	// 块签名的算法
+	sigV4ChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	// 请求时间与服务器时间允许的最大偏差
+	sigV4MaxSkew = 15 * time.Minute
+)
+
+var (
+	errSigV4Missing   = errors.New("missing authentication")
+	errSigV4Malformed = errors.New("malformed authorization")
+	errSigV4Mismatch  = errors.New("signature does not match")
+	errSigV4Expired   = errors.New("request has expired")
+	errSigV4BadDigest = errors.New("payload digest does not match")
+	// trailer中的校验和与解码后的负载不符
+	errSigV4BadChecksum = errors.New("payload checksum does not match")
+	// 不支持的STREAMING负载类型，如带签名trailer或ECDSA签名的块，以及不支持的校验和算法
+	errSigV4Unsupported = errors.New("unsupported payload signing method")
+)
+
+// CRC64NVME的多项式，按位反转后的形式
+var crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// trailer中的校验和头及其算法
+var trailerChecksums = map[string]func() hash.Hash{
+	"x-amz-checksum-crc32":     func() hash.Hash { return crc32.NewIEEE() },
+	"x-amz-checksum-crc32c":    func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	"x-amz-checksum-crc64nvme": func() hash.Hash { return crc64.New(crc64NVMETable) },
+	"x-amz-checksum-sha1":      sha1.New,
+	"x-amz-checksum-sha256":    sha256.New,
+}
+
+// 签名中解析出的参数
+type sigV4Params struct {
+	accessKeyID   string
+	date          string // yyyymmdd
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	payloadHash   string
+	presigned     bool
+}
+
+/**
+ * @description: 校验请求的SigV4签名，支持Authorization头和预签名URL两种方式
+ * @param {*http.Request} r
+ * @param {func(string) (string, error)} lookupSecret 根据AccessKeyID查询SecretKey
+ * @return {string} AccessKeyID
+ */
+func verifySigV4(r *http.Request, lookupSecret func(string) (string, error)) (string, error) {
+	params, err := parseSigV4(r)
+	if err != nil {
+		return "", err
+	}
+
+	// 检查请求时间
+	requestTime, err := time.Parse(sigV4TimeFormat, params.amzDate)
+	if err != nil || !strings.HasPrefix(params.amzDate, params.date) {
+		return "", errSigV4Malformed
+	}
+	if params.presigned {
+		expires, err := strconv.Atoi(r.URL.Query().Get("X-Amz-Expires"))
+		if err != nil || expires <= 0 || expires > 7*24*3600 {
+			return "", errSigV4Malformed
+		}
+		if time.Now().After(requestTime.Add(time.Duration(expires) * time.Second)) {
+			return "", errSigV4Expired
+		}
+	} else if d := time.Since(requestTime); d > sigV4MaxSkew || d < -sigV4MaxSkew {
+		return "", errSigV4Expired
+	}
+
+	secret, err := lookupSecret(params.accessKeyID)
+	if err != nil {
+		return "", err
+	}
+
+	// 计算签名并比较
+	scope := strings.Join([]string{params.date, params.region, params.service, "aws4_request"}, "/")
+	canonicalRequest := buildCanonicalRequest(r, params)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, params.amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	signingKey := deriveSigningKey(secret, params.date, params.region, params.service)
+	expected := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(params.signature)) {
+		return "", errSigV4Mismatch
+	}
+
+	// 解除aws-chunked编码，块签名和负载哈希在读取时校验
+	switch {
+	case params.payloadHash == sigV4StreamingPayload:
+		r.Body = io.NopCloser(newAWSChunkedReader(r.Body, &chunkSigner{key: signingKey, amzDate: params.amzDate, scope: scope, previous: expected}))
+	case params.payloadHash == sigV4StreamingUnsignedTrailer:
+		reader := newAWSChunkedReader(r.Body, nil)
+		if reader.checksumName = strings.ToLower(strings.TrimSpace(r.Header.Get("X-Amz-Trailer"))); reader.checksumName != "" {
+			newChecksum, ok := trailerChecksums[reader.checksumName]
+			if !ok {
+				return "", errSigV4Unsupported
+			}
+			reader.checksum = newChecksum()
+		}
+		r.Body = io.NopCloser(reader)
+	case strings.HasPrefix(params.payloadHash, "STREAMING-"):
+		return "", errSigV4Unsupported
+	case params.payloadHash != sigV4UnsignedPayload && r.Body != nil:
+		r.Body = &digestVerifyReader{ReadCloser: r.Body, hash: sha256.New(), expected: params.payloadHash}
+	}
+	if strings.HasPrefix(params.payloadHash, "STREAMING-") {
+		if size := r.Header.Get("X-Amz-Decoded-Content-Length"); size != "" {
+			r.ContentLength, _ = strconv.ParseInt(size, 10, 64)
+		} else {
+			r.ContentLength = -1
+		}
+	}
+
+	return params.accessKeyID, nil
+}
+
+/**
+ * @description: 从Authorization头或查询参数中解析签名参数
+ * @param {*http.Request} r
+ * @return {*sigV4Params}
+ */
+func parseSigV4(r *http.Request) (*sigV4Params, error) {
+	var (
+		params     sigV4Params
+		credential string
+		signedHdrs string
+	)
+
+	query := r.URL.Query()
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		// 格式: AWS4-HMAC-SHA256 Credential=.../..., SignedHeaders=a;b, Signature=...
+		if !strings.HasPrefix(auth, sigV4Algorithm+" ") {
+			return nil, errSigV4Malformed
+		}
+		for _, part := range strings.Split(strings.TrimPrefix(auth, sigV4Algorithm+" "), ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				return nil, errSigV4Malformed
+			}
+			switch key {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHdrs = value
+			case "Signature":
+				params.signature = value
+			}
+		}
+		params.amzDate = r.Header.Get("X-Amz-Date")
+		params.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if params.payloadHash == "" {
+			return nil, errSigV4Malformed
+		}
+	} else if query.Get("X-Amz-Algorithm") == sigV4Algorithm {
+		credential = query.Get("X-Amz-Credential")
+		signedHdrs = query.Get("X-Amz-SignedHeaders")
+		params.signature = query.Get("X-Amz-Signature")
+		params.amzDate = query.Get("X-Amz-Date")
+		params.payloadHash = sigV4UnsignedPayload
+		params.presigned = true
+	} else {
+		return nil, errSigV4Missing
+	}
+
+	// Credential格式: AccessKeyID/yyyymmdd/region/service/aws4_request
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || signedHdrs == "" || params.signature == "" {
+		return nil, errSigV4Malformed
+	}
+	params.accessKeyID, params.date, params.region, params.service = scope[0], scope[1], scope[2], scope[3]
+	params.signedHeaders = strings.Split(signedHdrs, ";")
+	return &params, nil
+}
+
+/**
+ * @description: 构造规范请求
+ * @param {*http.Request} r
+ * @param {*sigV4Params} params 签名参数
+ * @return {string} 规范请求
+ */
+func buildCanonicalRequest(r *http.Request, params *sigV4Params) string {
+	// 规范查询字符串，预签名时不包含签名本身
+	var queryParts []string
+	for key, values := range r.URL.Query() {
+		if params.presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			queryParts = append(queryParts, awsURIEncode(key, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(queryParts)
+
+	// 规范请求头
+	var headers strings.Builder
+	for _, name := range params.signedHeaders {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			value = strings.Join(r.Header.Values(name), ",")
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		awsURIEncode(r.URL.Path, false),
+		strings.Join(queryParts, "&"),
+		headers.String(),
+		strings.Join(params.signedHeaders, ";"),
+		params.payloadHash,
+	}, "\n")
+}
+
+/**
+ * @description: 按AWS规则进行URI编码，只保留A-Za-z0-9-_.~不编码
+ * @param {string} s 原始字符串
+ * @param {bool} encodeSlash 是否编码斜杠
+ * @return {string} 编码后的字符串
+ */
+func awsURIEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+/**
+ * @description: 计算签名密钥
+ * @return {[]byte} 签名密钥
+ */
+func deriveSigningKey(secret string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+// 读取完成时校验负载哈希的Reader
+type digestVerifyReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (d *digestVerifyReader) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	d.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(d.hash.Sum(nil)) != d.expected {
+		return n, errSigV4BadDigest
+	}
+	return n, err
+}
+
+// aws-chunked块签名的计算参数，每个块的签名以上一个块的签名为起点，第一个块以请求的种子签名为起点
+type chunkSigner struct {
+	key      []byte
+	amzDate  string
+	scope    string
+	previous string
+}
+
+/**
+ * @description: 校验一个块的签名，通过后作为下一个块的起点
+ * @param {[]byte} chunkHash 块数据的SHA256
+ * @param {string} signature 块头中的chunk-signature
+ * @return {error}
+ */
+func (s *chunkSigner) verify(chunkHash []byte, signature string) error {
+	emptyHash := sha256.Sum256(nil)
+	stringToSign := strings.Join([]string{
+		sigV4ChunkAlgorithm,
+		s.amzDate,
+		s.scope,
+		s.previous,
+		hex.EncodeToString(emptyHash[:]),
+		hex.EncodeToString(chunkHash),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(s.key, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errSigV4Mismatch
+	}
+	s.previous = expected
+	return nil
+}
+
+var errAWSChunkedMalformed = errors.New("malformed aws-chunked encoding")
+
+// 解码aws-chunked编码的Reader，格式为"十六进制长度[;chunk-signature=签名]\r\n数据\r\n"，长度为0的块之后是trailer
+// signer不为空时校验每个块的签名，签名不符时返回errSigV4Mismatch，调用方应丢弃已读取的数据
+// signer为空时是STREAMING-UNSIGNED-PAYLOAD-TRAILER，checksum不为空时校验trailer中的校验和，不符时返回errSigV4BadChecksum
+type awsChunkedReader struct {
+	r            *bufio.Reader
+	signer       *chunkSigner
+	remaining    int64
+	signature    string    // 当前块的签名
+	hash         hash.Hash // 当前块数据的哈希
+	checksumName string    // X-Amz-Trailer声明的校验和头，小写
+	checksum     hash.Hash // 全部数据的校验和
+	eof          bool
+}
+
+func newAWSChunkedReader(r io.Reader, signer *chunkSigner) *awsChunkedReader {
+	return &awsChunkedReader{r: bufio.NewReader(r), signer: signer, hash: sha256.New()}
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	if c.eof {
+		return 0, io.EOF
+	}
+
+	// 读取下一个块头
+	if c.remaining == 0 {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		sizeStr, ext, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeStr, 16, 64)
+		if err != nil || size < 0 {
+			return 0, errAWSChunkedMalformed
+		}
+		if c.signer != nil {
+			signature, ok := strings.CutPrefix(ext, "chunk-signature=")
+			if !ok {
+				return 0, errAWSChunkedMalformed
+			}
+			c.signature = signature
+		}
+		if size == 0 {
+			// 最后一个块同样签名，校验后丢弃trailer
+			if err := c.endChunk(); err != nil {
+				return 0, err
+			}
+			if c.signer == nil {
+				if err := c.verifyTrailer(); err != nil {
+					return 0, err
+				}
+			}
+			c.eof = true
+			io.Copy(io.Discard, c.r)
+			return 0, io.EOF
+		}
+		c.remaining = size
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if c.checksum != nil {
+		c.checksum.Write(p[:n])
+	}
+	c.remaining -= int64(n)
+	if c.remaining == 0 {
+		// 块数据后的\r\n
+		crlf := make([]byte, 2)
+		if _, err := io.ReadFull(c.r, crlf); err != nil || !bytes.Equal(crlf, []byte("\r\n")) {
+			return n, errAWSChunkedMalformed
+		}
+		if err := c.endChunk(); err != nil {
+			return n, err
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+/**
+ * @description: 一个块读取完成，校验签名并重置哈希
+ * @return {error}
+ */
+func (c *awsChunkedReader) endChunk() error {
+	defer c.hash.Reset()
+	if c.signer == nil {
+		return nil
+	}
+	return c.signer.verify(c.hash.Sum(nil), c.signature)
+}
+
+/**
+ * @description: 读取trailer，校验X-Amz-Trailer声明的校验和，trailer为"名称:base64值\r\n"，以空行结束
+ * 未声明的校验和头无法校验，与缺少声明的校验和一样视为格式错误
+ * @return {error}
+ */
+func (c *awsChunkedReader) verifyTrailer() error {
+	var value string
+	found := false
+	for {
+		line, err := c.r.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			name, v, ok := strings.Cut(line, ":")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !ok || (strings.HasPrefix(name, "x-amz-checksum-") && name != c.checksumName) {
+				return errAWSChunkedMalformed
+			}
+			if name == c.checksumName {
+				value, found = strings.TrimSpace(v), true
+			}
+		}
+		if line == "" || err != nil {
+			break
+		}
+	}
+
+	if c.checksum == nil {
+		return nil
+	}
+	if !found {
+		return errAWSChunkedMalformed
+	}
+	if base64.StdEncoding.EncodeToString(c.checksum.Sum(nil)) != value {
+		return errSigV4BadChecksum
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 12:10:26
+ * @LastEditTime: 2026-10-19 18:21:47
+ * @FilePath: \CloudDisk\business\sigv4_test.go
+ * @Description: SigV4签名校验测试
+ */
+package business
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AWS文档中分块上传签名示例的参数
+const (
+	sigV4TestSecret  = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	sigV4TestAmzDate = "20130524T000000Z"
+	sigV4TestScope   = "20130524/us-east-1/s3/aws4_request"
+	sigV4TestSeed    = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+)
+
+/**
+ * @description: 按AWS文档示例构造aws-chunked编码的负载，65536字节和1024字节两个块
+ * @param {byte} fill 填充的字节，示例为'a'
+ * @return {[]byte}
+ */
+func exampleChunkedBody(fill byte) []byte {
+	var body bytes.Buffer
+	chunks := []struct {
+		size      int
+		signature string
+	}{
+		{65536, "ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648"},
+		{1024, "0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497"},
+		{0, "b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9"},
+	}
+	for _, chunk := range chunks {
+		fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n", chunk.size, chunk.signature)
+		body.Write(bytes.Repeat([]byte{fill}, chunk.size))
+		body.WriteString("\r\n")
+	}
+	return body.Bytes()
+}
+
+func exampleChunkSigner() *chunkSigner {
+	key := deriveSigningKey(sigV4TestSecret, "20130524", "us-east-1", "s3")
+	return &chunkSigner{key: key, amzDate: sigV4TestAmzDate, scope: sigV4TestScope, previous: sigV4TestSeed}
+}
+
+func TestAWSChunkedReaderVerifiesSignatures(t *testing.T) {
+	data, err := io.ReadAll(newAWSChunkedReader(bytes.NewReader(exampleChunkedBody('a')), exampleChunkSigner()))
+	if err != nil {
+		t.Fatalf("valid chunked body rejected: %v", err)
+	}
+	if !bytes.Equal(data, bytes.Repeat([]byte("a"), 65536+1024)) {
+		t.Errorf("decoded %d bytes, want %d", len(data), 65536+1024)
+	}
+}
+
+func TestAWSChunkedReaderRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want error
+	}{
+		// 数据被修改
+		{"data", exampleChunkedBody('b'), errSigV4Mismatch},
+		// 缺少块签名
+		{"unsigned", []byte("3\r\nabc\r\n0\r\n\r\n"), errAWSChunkedMalformed},
+		// 截断在最后一个块之前
+		{"truncated", exampleChunkedBody('a')[:65536+100], io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(newAWSChunkedReader(bytes.NewReader(tt.body), exampleChunkSigner()))
+			if err != tt.want {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAWSChunkedReaderUnsigned(t *testing.T) {
+	// STREAMING-UNSIGNED-PAYLOAD-TRAILER的块没有签名，trailer中的校验和按X-Amz-Trailer声明的算法校验
+	crc := crc32.NewIEEE()
+	crc.Write([]byte("abc"))
+	crc32abc := base64.StdEncoding.EncodeToString(crc.Sum(nil))
+	sha := sha256.Sum256([]byte("abc"))
+	sha256abc := base64.StdEncoding.EncodeToString(sha[:])
+
+	tests := []struct {
+		name    string
+		trailer string // X-Amz-Trailer
+		body    string
+		want    error
+	}{
+		{"crc32", "x-amz-checksum-crc32", "3\r\nabc\r\n0\r\nx-amz-checksum-crc32:" + crc32abc + "\r\n\r\n", nil},
+		{"sha256 without final blank line", "x-amz-checksum-sha256", "3\r\nabc\r\n0\r\nx-amz-checksum-sha256:" + sha256abc + "\r\n", nil},
+		{"no trailer", "", "3\r\nabc\r\n0\r\n\r\n", nil},
+		{"crc32 mismatch", "x-amz-checksum-crc32", "3\r\nabc\r\n0\r\nx-amz-checksum-crc32:AAAAAA==\r\n\r\n", errSigV4BadChecksum},
+		{"declared checksum missing", "x-amz-checksum-crc32", "3\r\nabc\r\n0\r\n\r\n", errAWSChunkedMalformed},
+		{"undeclared checksum", "", "3\r\nabc\r\n0\r\nx-amz-checksum-crc32:" + crc32abc + "\r\n\r\n", errAWSChunkedMalformed},
+	}
+
+	lookup := func(string) (string, error) { return "secret", nil }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedPutRequest(sigV4StreamingUnsignedTrailer, tt.body)
+			r.Header.Set("X-Amz-Trailer", tt.trailer)
+			if _, err := verifySigV4(r, lookup); err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r.Body)
+			if err != tt.want {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+			if err == nil && string(data) != "abc" {
+				t.Errorf("decoded %q, want %q", data, "abc")
+			}
+		})
+	}
+}
+
+func TestTrailerChecksums(t *testing.T) {
+	// 各算法对"123456789"的标准校验值
+	tests := map[string]string{
+		"x-amz-checksum-crc32":     "cbf43926",
+		"x-amz-checksum-crc32c":    "e3069283",
+		"x-amz-checksum-crc64nvme": "ae8b14860a799888",
+		"x-amz-checksum-sha1":      "f7c3bc1d808e04732adf679965ccc34ca7ae3441",
+		"x-amz-checksum-sha256":    "15e2b0d3c33891ebb0f1ef609ec419420c20e320ce94c65fbc8c3312448eb225",
+	}
+	for name, want := range tests {
+		h := trailerChecksums[name]()
+		h.Write([]byte("123456789"))
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+}
+
+/**
+ * @description: 构造用Authorization头签名的PUT请求
+ * @param {string} payloadHash X-Amz-Content-Sha256
+ * @param {string} body 请求体
+ * @return {*http.Request}
+ */
+func signedPutRequest(payloadHash string, body string) *http.Request {
+	now := time.Now().UTC()
+	r := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader(body))
+	r.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	params := &sigV4Params{
+		date:          now.Format("20060102"),
+		amzDate:       now.Format(sigV4TimeFormat),
+		payloadHash:   payloadHash,
+		signedHeaders: []string{"host", "x-amz-content-sha256", "x-amz-date"},
+	}
+	scope := params.date + "/us-east-1/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(buildCanonicalRequest(r, params)))
+	stringToSign := strings.Join([]string{sigV4Algorithm, params.amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(deriveSigningKey("secret", params.date, "us-east-1", "s3"), []byte(stringToSign)))
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=AKID/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, scope, strings.Join(params.signedHeaders, ";"), signature))
+	return r
+}
+
+func TestVerifySigV4PayloadTypes(t *testing.T) {
+	lookup := func(string) (string, error) { return "secret", nil }
+	tests := []struct {
+		payloadHash string
+		want        error
+	}{
+		{sigV4UnsignedPayload, nil},
+		{sigV4StreamingPayload, nil},
+		{sigV4StreamingUnsignedTrailer, nil},
+		// 不支持的STREAMING负载类型不能在不校验的情况下接受
+		{"STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER", errSigV4Unsupported},
+		{"STREAMING-AWS4-ECDSA-P256-SHA256-PAYLOAD", errSigV4Unsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.payloadHash, func(t *testing.T) {
+			if _, err := verifySigV4(signedPutRequest(tt.payloadHash, ""), lookup); err != tt.want {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySigV4UnsupportedTrailerChecksum(t *testing.T) {
+	// 无法校验的校验和算法返回NotImplemented，不能在不校验的情况下接受
+	lookup := func(string) (string, error) { return "secret", nil }
+	r := signedPutRequest(sigV4StreamingUnsignedTrailer, "")
+	r.Header.Set("X-Amz-Trailer", "x-amz-checksum-md5")
+	if _, err := verifySigV4(r, lookup); err != errSigV4Unsupported {
+		t.Errorf("got error %v, want %v", err, errSigV4Unsupported)
+	}
+}
+
+func TestVerifySigV4StreamingChainsFromSeed(t *testing.T) {
+	// 用其他种子签名计算的块签名不能通过校验
+	lookup := func(string) (string, error) { return "secret", nil }
+	signed := signedPutRequest(sigV4StreamingPayload, string(exampleChunkedBody('a')))
+	if _, err := verifySigV4(signed, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(signed.Body); err != errSigV4Mismatch {
+		t.Errorf("chunks signed with another seed: got error %v, want %v", err, errSigV4Mismatch)
+	}
+}
@@ -0,0 +1,281 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 00:05:37
+ * @LastEditTime: 2026-10-19 00:48:52
+ * @FilePath: \CloudDisk\business\thumbnail.go
+ * @Description: 图片缩略图的生成、缓存和接口
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"CloudDisk/logwrapper"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 缩略图尺寸，值为最长边的像素数，原图更小时不放大
+var thumbnailSizes = map[string]int{
+	"small":  128,
+	"medium": 256,
+	"large":  1024,
+}
+
+// 默认缩略图尺寸
+const defaultThumbnailSize = "medium"
+
+// 原图像素数上限，防止解码超大图片耗尽内存
+const maxThumbnailPixels = 50_000_000
+
+// 生成缩略图的图片扩展名
+var thumbnailExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+var errNotImage = errors.New("thumbnails are only available for JPEG, PNG, GIF and WebP images")
+
+// 待生成缩略图的文件ID队列
+var thumbnailQueue = make(chan int64, 4096)
+
+/**
+ * @description: 启动缩略图生成协程
+ * @return {*}
+ */
+func StartThumbnailer() {
+	go func() {
+		for fileID := range thumbnailQueue {
+			fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+			if err != nil {
+				// 文件在排队期间被删除
+				continue
+			}
+			if _, err := generateThumbnails(fileInfo); err != nil {
+				logwrapper.Logger.Warnf("Failed to generate thumbnails of file %d: %v", fileID, err)
+			}
+		}
+	}()
+}
+
+/**
+ * @description: 图片文件加入缩略图队列，队列满时等待而不阻塞调用方
+ * @param {*dto.File} fileInfo 文件信息
+ * @return {*}
+ */
+func enqueueThumbnail(fileInfo *dto.File) {
+	if !thumbnailExts[strings.ToLower(path.Ext(fileInfo.Name))] {
+		return
+	}
+	select {
+	case thumbnailQueue <- fileInfo.ID:
+	default:
+		go func() { thumbnailQueue <- fileInfo.ID }()
+	}
+}
+
+/**
+ * @description: 获取文件缩略图的缓存目录
+ * @param {int64} fileID 文件ID
+ * @return {string}
+ */
+func thumbnailFolder(fileID int64) string {
+	return path.Join(GetCacheFolderPath(), "thumbnails", strconv.FormatInt(fileID, 10))
+}
+
+/**
+ * @description: 文件内容版本，内容更新后版本变化，用于ETag和缩略图文件名，旧版本的缩略图不会再被使用
+ * @param {*dto.File} fileInfo 文件信息
+ * @return {string}
+ */
+func contentVersion(fileInfo *dto.File) string {
+	return fmt.Sprintf("%d-%d", fileInfo.UpdatedAt.Unix(), fileInfo.Size)
+}
+
+/**
+ * @description: 删除文件的全部缩略图，文件内容更新或删除时调用
+ * @param {int64} fileID 文件ID
+ * @return {*}
+ */
+func removeThumbnails(fileID int64) {
+	if err := os.RemoveAll(thumbnailFolder(fileID)); err != nil {
+		logwrapper.Logger.Warnf("Failed to remove thumbnails of file %d: %v", fileID, err)
+	}
+}
+
+/**
+ * @description: 查找已缓存的缩略图
+ * @param {*dto.File} fileInfo 文件信息
+ * @param {string} size 尺寸名称
+ * @return {string} 缩略图路径，不存在时为空
+ */
+func findThumbnail(fileInfo *dto.File, size string) string {
+	matches, _ := filepath.Glob(path.Join(thumbnailFolder(fileInfo.ID), contentVersion(fileInfo)+"-"+size+".*"))
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[0]
+}
+
+/**
+ * @description: 生成文件当前内容的全部尺寸缩略图，不透明的图片保存为JPEG，否则保存为PNG
+ * @param {*dto.File} fileInfo 文件信息
+ * @return {map[string]string} 尺寸名称 -> 缩略图路径
+ */
+func generateThumbnails(fileInfo *dto.File) (map[string]string, error) {
+	f, err := os.Open(path.Join(GetBaseFolderPath(), fileInfo.Path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// 先读取尺寸，拒绝超大图片
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, errNotImage
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := ".png"
+	if opaque, ok := src.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		ext = ".jpg"
+	}
+
+	folder := thumbnailFolder(fileInfo.ID)
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	for size, maxEdge := range thumbnailSizes {
+		thumbPath := path.Join(folder, contentVersion(fileInfo)+"-"+size+ext)
+		if err := writeThumbnail(thumbPath, src, maxEdge); err != nil {
+			return nil, err
+		}
+		paths[size] = thumbPath
+	}
+	return paths, nil
+}
+
+/**
+ * @description: 缩放图片并写入文件，先写入临时文件再重命名，读取方不会读到不完整的文件
+ * @param {string} thumbPath 缩略图路径，扩展名决定格式
+ * @param {image.Image} src 原图
+ * @param {int} maxEdge 最长边的像素数
+ * @return {*}
+ */
+func writeThumbnail(thumbPath string, src image.Image, maxEdge int) error {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if width > maxEdge || height > maxEdge {
+		if width >= height {
+			width, height = maxEdge, max(1, height*maxEdge/width)
+		} else {
+			width, height = max(1, width*maxEdge/height), maxEdge
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	tempFile, err := os.CreateTemp(path.Dir(thumbPath), ".thumbnail-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	defer RemoveFileIgnoreNotExist(tempPath)
+
+	if path.Ext(thumbPath) == ".jpg" {
+		err = jpeg.Encode(tempFile, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(tempFile, dst)
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, thumbPath)
+}
+
+/**
+ * @description: 缩略图api，GET /api/thumbnail?fileId=&path=&size=
+ * 缩略图尚未生成时同步生成，响应带ETag，内容更新后ETag随之变化
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func Thumbnail(w http.ResponseWriter, r *http.Request) {
+	// 只支持GET请求
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 检查参数
+	fileID, ok := queryFileID(w, r)
+	if !ok {
+		return
+	}
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = defaultThumbnailSize
+	}
+	if _, ok := thumbnailSizes[size]; !ok {
+		http.Error(w, "Invalid size", http.StatusBadRequest)
+		return
+	}
+
+	// 查询文件信息
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err == dbwrapper.ErrFileNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 查找缓存，没有时生成
+	thumbPath := findThumbnail(fileInfo, size)
+	if thumbPath == "" {
+		paths, err := generateThumbnails(fileInfo)
+		if err == errNotImage {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		thumbPath = paths[size]
+	}
+
+	thumbFile, err := os.Open(thumbPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer thumbFile.Close()
+
+	// 每次使用前向服务端确认，内容未变化时返回304
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%d-%s-%s"`, fileInfo.ID, contentVersion(fileInfo), size))
+	http.ServeContent(w, r, path.Base(thumbPath), fileInfo.UpdatedAt, thumbFile)
+}
@@ -0,0 +1,366 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 01:37:20
+ * @LastEditTime: 2026-10-19 12:36:12
+ * @FilePath: \CloudDisk\business\transcode.go
+ * @Description: 视频转码为HLS及播放接口，未安装ffmpeg时跳过
+ */
+package business
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"CloudDisk/logwrapper"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HLS清晰度
+type Rendition struct {
+	Name         string // 子目录名，如720p
+	Height       int    // 最大高度，原视频更小时不放大
+	VideoBitrate int    // 视频码率，单位bps
+	AudioBitrate int    // 音频码率，单位bps
+}
+
+// 生成的清晰度，按码率从低到高
+var renditions = []Rendition{
+	{Name: "360p", Height: 360, VideoBitrate: 800_000, AudioBitrate: 96_000},
+	{Name: "720p", Height: 720, VideoBitrate: 2_800_000, AudioBitrate: 128_000},
+}
+
+// 转码器，可替换为其他实现
+type Transcoder interface {
+	// 将视频转码为一个清晰度的HLS，在outDir下生成index.m3u8和分片
+	TranscodeHLS(ctx context.Context, srcPath string, outDir string, rendition Rendition) error
+	// 截取一帧作为封面，保存为JPEG
+	Poster(ctx context.Context, srcPath string, outPath string) error
+}
+
+// 转码结果文件名
+const (
+	masterPlaylistName = "master.m3u8"
+	posterName         = "poster.jpg"
+)
+
+// 单个视频转码的时间上限，超时后结束ffmpeg并记为失败，避免损坏的文件卡住队列
+const transcodeTimeout = 2 * time.Hour
+
+var (
+	transcoder     Transcoder // 为nil时不转码
+	transcodeQueue = make(chan int64, 4096)
+
+	// 排队或转码中的文件，值为内容版本；转码失败的内容版本，不再重试
+	transcodeMu      sync.Mutex
+	transcodePending = make(map[int64]string)
+	transcodeFailed  = make(map[int64]string)
+)
+
+// 调用ffmpeg命令行的转码器
+type ffmpegTranscoder struct {
+	path string
+}
+
+/**
+ * @description: 按配置创建ffmpeg转码器
+ * @return {Transcoder} 关闭转码或找不到ffmpeg时返回nil
+ */
+func NewFFmpegTranscoder() Transcoder {
+	cfg := configwrapper.Cfg.Transcode
+	if cfg.Disabled {
+		return nil
+	}
+	ffmpegPath := cfg.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	ffmpegPath, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		logwrapper.Logger.Infof("ffmpeg not found, video transcoding is disabled: %v", err)
+		return nil
+	}
+	return &ffmpegTranscoder{path: ffmpegPath}
+}
+
+/**
+ * @description: 执行ffmpeg，失败时返回其错误输出
+ * @param {context.Context} ctx
+ * @param {...string} args 参数
+ * @return {*}
+ */
+func (t *ffmpegTranscoder) run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, t.path, append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (t *ffmpegTranscoder) TranscodeHLS(ctx context.Context, srcPath string, outDir string, rendition Rendition) error {
+	return t.run(ctx,
+		"-i", srcPath,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:'trunc(min(%d,ih)/2)*2'", rendition.Height),
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main", "-pix_fmt", "yuv420p",
+		"-b:v", strconv.Itoa(rendition.VideoBitrate),
+		"-maxrate", strconv.Itoa(rendition.VideoBitrate),
+		"-bufsize", strconv.Itoa(rendition.VideoBitrate*2),
+		"-g", "48", "-keyint_min", "48", "-sc_threshold", "0",
+		"-c:a", "aac", "-ac", "2", "-b:a", strconv.Itoa(rendition.AudioBitrate),
+		"-f", "hls", "-hls_time", "6", "-hls_playlist_type", "vod",
+		"-hls_segment_filename", path.Join(outDir, "segment_%04d.ts"),
+		path.Join(outDir, "index.m3u8"),
+	)
+}
+
+func (t *ffmpegTranscoder) Poster(ctx context.Context, srcPath string, outPath string) error {
+	// thumbnail滤镜从开头的若干帧中挑选有代表性的一帧，避免黑屏
+	return t.run(ctx, "-i", srcPath, "-vf", "thumbnail,scale='min(1280,iw)':-2", "-frames:v", "1", "-q:v", "3", outPath)
+}
+
+/**
+ * @description: 启动视频转码协程
+ * @param {Transcoder} t 转码器，为nil时不转码
+ * @return {*}
+ */
+func StartTranscoder(t Transcoder) {
+	if t == nil {
+		return
+	}
+	transcoder = t
+	go func() {
+		// 同时只转码一个视频，避免占满CPU
+		for fileID := range transcodeQueue {
+			transcodeFile(fileID)
+		}
+	}()
+}
+
+/**
+ * @description: 视频文件加入转码队列，已在队列中或已转码失败时跳过
+ * @param {*dto.File} fileInfo 文件信息
+ * @return {*}
+ */
+func enqueueTranscode(fileInfo *dto.File) {
+	if transcoder == nil || !strings.HasPrefix(fileInfo.MimeType, "video/") {
+		return
+	}
+
+	version := contentVersion(fileInfo)
+	transcodeMu.Lock()
+	if transcodePending[fileInfo.ID] == version || transcodeFailed[fileInfo.ID] == version {
+		transcodeMu.Unlock()
+		return
+	}
+	transcodePending[fileInfo.ID] = version
+	transcodeMu.Unlock()
+
+	select {
+	case transcodeQueue <- fileInfo.ID:
+	default:
+		go func() { transcodeQueue <- fileInfo.ID }()
+	}
+}
+
+/**
+ * @description: 获取文件转码结果的缓存目录
+ * @param {int64} fileID 文件ID
+ * @return {string}
+ */
+func videoFolder(fileID int64) string {
+	return path.Join(GetCacheFolderPath(), "videos", strconv.FormatInt(fileID, 10))
+}
+
+/**
+ * @description: 删除文件的全部转码结果，文件内容更新或删除时调用
+ * @param {int64} fileID 文件ID
+ * @return {*}
+ */
+func removeTranscodes(fileID int64) {
+	transcodeMu.Lock()
+	delete(transcodeFailed, fileID)
+	transcodeMu.Unlock()
+
+	if err := os.RemoveAll(videoFolder(fileID)); err != nil {
+		logwrapper.Logger.Warnf("Failed to remove transcodes of file %d: %v", fileID, err)
+	}
+}
+
+/**
+ * @description: 转码文件的当前内容，全部完成后才将临时目录重命名为结果目录
+ * @param {int64} fileID 文件ID
+ * @return {*}
+ */
+func transcodeFile(fileID int64) {
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		// 文件在排队期间被删除
+		transcodeMu.Lock()
+		delete(transcodePending, fileID)
+		transcodeMu.Unlock()
+		return
+	}
+
+	version := contentVersion(fileInfo)
+	err = transcodeVersion(fileInfo, version)
+
+	transcodeMu.Lock()
+	delete(transcodePending, fileID)
+	if err != nil {
+		transcodeFailed[fileID] = version
+	}
+	transcodeMu.Unlock()
+	if err != nil {
+		logwrapper.Logger.Warnf("Failed to transcode file %d: %v", fileID, err)
+	}
+}
+
+/**
+ * @description: 生成封面、各清晰度的HLS和主播放列表
+ * @param {*dto.File} fileInfo 文件信息
+ * @param {string} version 内容版本
+ * @return {*}
+ */
+func transcodeVersion(fileInfo *dto.File, version string) error {
+	outDir := path.Join(videoFolder(fileInfo.ID), version)
+	if _, err := os.Stat(outDir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(videoFolder(fileInfo.ID), os.ModePerm); err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp(videoFolder(fileInfo.ID), ".transcode-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	srcPath := path.Join(GetBaseFolderPath(), fileInfo.Path)
+	if err := transcoder.Poster(ctx, srcPath, path.Join(tempDir, posterName)); err != nil {
+		return err
+	}
+
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, rendition := range renditions {
+		renditionDir := path.Join(tempDir, rendition.Name)
+		if err := os.Mkdir(renditionDir, os.ModePerm); err != nil {
+			return err
+		}
+		if err := transcoder.TranscodeHLS(ctx, srcPath, renditionDir, rendition); err != nil {
+			return err
+		}
+		fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d,NAME=\"%s\"\n%s/index.m3u8\n",
+			rendition.VideoBitrate+rendition.AudioBitrate, rendition.Name, rendition.Name)
+	}
+	if err := os.WriteFile(path.Join(tempDir, masterPlaylistName), []byte(master.String()), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempDir, outDir)
+}
+
+/**
+ * @description: 视频播放api，GET /api/video/{fileId}/master.m3u8，
+ * 播放列表中的分片和各清晰度播放列表使用相对路径，封面为/api/video/{fileId}/poster.jpg
+ * 尚未转码时加入队列并返回202
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func VideoFile(w http.ResponseWriter, r *http.Request) {
+	// 只支持GET请求
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 检查参数
+	fileID, err := strconv.ParseInt(r.PathValue("fileId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid fileId", http.StatusBadRequest)
+		return
+	}
+	name := path.Clean("/" + r.PathValue("name"))
+	if path.Base(name) == "." || strings.HasPrefix(path.Base(name), ".") {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	// 查询文件信息
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err == dbwrapper.ErrFileNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if fileInfo.MimeType == "" {
+		fileInfo.MimeType = recordMimeType(fileInfo.ID, fileInfo.Name, path.Join(GetBaseFolderPath(), fileInfo.Path))
+	}
+	if !strings.HasPrefix(fileInfo.MimeType, "video/") {
+		http.Error(w, "File is not a video", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// 查找转码结果
+	outDir := path.Join(videoFolder(fileID), contentVersion(fileInfo))
+	if _, err := os.Stat(outDir); err != nil {
+		if transcoder == nil {
+			http.Error(w, "Video transcoding is not available", http.StatusNotImplemented)
+			return
+		}
+		transcodeMu.Lock()
+		failed := transcodeFailed[fileID] == contentVersion(fileInfo)
+		transcodeMu.Unlock()
+		if failed {
+			http.Error(w, "Video transcoding failed", http.StatusUnprocessableEntity)
+			return
+		}
+
+		enqueueTranscode(fileInfo)
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "Video is being transcoded", http.StatusAccepted)
+		return
+	}
+
+	f, err := os.Open(path.Join(outDir, name))
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	switch path.Ext(name) {
+	case ".m3u8":
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	case ".ts":
+		w.Header().Set("Content-Type", "video/mp2t")
+	case ".jpg":
+		w.Header().Set("Content-Type", "image/jpeg")
+	}
+	// 地址不含内容版本，内容更新后需要重新获取
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
@@ -0,0 +1,202 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 12:36:12
+ * @LastEditTime: 2026-10-19 12:36:12
+ * @FilePath: \CloudDisk\business\transcode_test.go
+ * @Description: 视频转码测试，使用不调用ffmpeg的转码器
+ */
+package business
+
+import (
+	"CloudDisk/dto"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 写入占位文件的转码器，release关闭前阻塞，用于观察转码中的状态
+type fakeTranscoder struct {
+	release chan struct{}
+	fail    bool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeTranscoder) record(ctx context.Context, call string) error {
+	<-f.release
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := ctx.Deadline(); !ok {
+		call += " without deadline"
+	}
+	f.calls = append(f.calls, call)
+	if f.fail {
+		return errors.New("fake transcode failure")
+	}
+	return nil
+}
+
+func (f *fakeTranscoder) TranscodeHLS(ctx context.Context, srcPath string, outDir string, rendition Rendition) error {
+	if err := f.record(ctx, fmt.Sprintf("hls %s %s %s", srcPath, path.Base(outDir), rendition.Name)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path.Join(outDir, "index.m3u8"), []byte("#EXTM3U\nsegment_0000.ts\n"), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(outDir, "segment_0000.ts"), []byte(rendition.Name), 0644)
+}
+
+func (f *fakeTranscoder) Poster(ctx context.Context, srcPath string, outPath string) error {
+	if err := f.record(ctx, fmt.Sprintf("poster %s %s", srcPath, path.Base(outPath))); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, []byte("jpeg"), 0644)
+}
+
+func (f *fakeTranscoder) recordedCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+/**
+ * @description: 启动使用fake的转码协程，测试结束后关闭转码
+ * @param {*testing.T} t
+ * @param {*fakeTranscoder} fake
+ * @return {*}
+ */
+func startFakeTranscoder(t *testing.T, fake *fakeTranscoder) {
+	t.Helper()
+	StartTranscoder(fake)
+	t.Cleanup(func() {
+		transcodeMu.Lock()
+		defer transcodeMu.Unlock()
+		transcoder = nil
+	})
+}
+
+/**
+ * @description: 请求视频接口
+ * @return {*httptest.ResponseRecorder}
+ */
+func getVideo(fileID int64, name string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/video/%d/%s", fileID, name), nil)
+	r.SetPathValue("fileId", fmt.Sprint(fileID))
+	r.SetPathValue("name", name)
+	w := httptest.NewRecorder()
+	VideoFile(w, r)
+	return w
+}
+
+/**
+ * @description: 轮询视频接口直到不再返回202
+ * @return {*httptest.ResponseRecorder}
+ */
+func waitVideo(t *testing.T, fileID int64, name string) *httptest.ResponseRecorder {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		w := getVideo(fileID, name)
+		if w.Code != http.StatusAccepted || time.Now().After(deadline) {
+			return w
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+/**
+ * @description: 保存一个视频文件
+ * @return {*dto.File}
+ */
+func saveTestVideo(t *testing.T, parentID int64) *dto.File {
+	t.Helper()
+	file, err := saveFile(parentID, "clip.mp4", strings.NewReader("not really a video"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestVideoTranscodeFlow(t *testing.T) {
+	requireTestDB(t)
+	fake := &fakeTranscoder{release: make(chan struct{})}
+	startFakeTranscoder(t, fake)
+	parentID := createTestFolder(t)
+	file := saveTestVideo(t, parentID)
+
+	// 转码完成前返回202
+	w := getVideo(file.ID, masterPlaylistName)
+	if w.Code != http.StatusAccepted || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("before transcoding: got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(fake.release)
+	w = waitVideo(t, file.ID, masterPlaylistName)
+	if w.Code != http.StatusOK {
+		t.Fatalf("after transcoding: got %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/vnd.apple.mpegurl" {
+		t.Errorf("master playlist Content-Type %q", ct)
+	}
+
+	// 主播放列表按码率从低到高列出各清晰度
+	var want strings.Builder
+	want.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, rendition := range renditions {
+		fmt.Fprintf(&want, "#EXT-X-STREAM-INF:BANDWIDTH=%d,NAME=\"%s\"\n%s/index.m3u8\n",
+			rendition.VideoBitrate+rendition.AudioBitrate, rendition.Name, rendition.Name)
+	}
+	if w.Body.String() != want.String() {
+		t.Errorf("master playlist:\n%s\nwant:\n%s", w.Body, want.String())
+	}
+
+	// 各清晰度的播放列表、分片和封面
+	for name, contentType := range map[string]string{
+		renditions[0].Name + "/index.m3u8":      "application/vnd.apple.mpegurl",
+		renditions[1].Name + "/segment_0000.ts": "video/mp2t",
+		posterName:                              "image/jpeg",
+	} {
+		if w := getVideo(file.ID, name); w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType {
+			t.Errorf("%s: got %d, Content-Type %q", name, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+	if w := getVideo(file.ID, "missing.ts"); w.Code != http.StatusNotFound {
+		t.Errorf("missing file: got %d", w.Code)
+	}
+
+	// 转码器收到原文件路径，每次调用都有超时
+	srcPath := path.Join(GetBaseFolderPath(), file.Path)
+	wantCalls := []string{"poster " + srcPath + " " + posterName}
+	for _, rendition := range renditions {
+		wantCalls = append(wantCalls, fmt.Sprintf("hls %s %s %s", srcPath, rendition.Name, rendition.Name))
+	}
+	if calls := fake.recordedCalls(); strings.Join(calls, "\n") != strings.Join(wantCalls, "\n") {
+		t.Errorf("transcoder calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(wantCalls, "\n"))
+	}
+}
+
+func TestVideoTranscodeFailure(t *testing.T) {
+	requireTestDB(t)
+	fake := &fakeTranscoder{release: make(chan struct{}), fail: true}
+	close(fake.release)
+	startFakeTranscoder(t, fake)
+	parentID := createTestFolder(t)
+	file := saveTestVideo(t, parentID)
+
+	// 转码失败后返回422，同一内容不再重试
+	if w := waitVideo(t, file.ID, masterPlaylistName); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("after failed transcoding: got %d: %s", w.Code, w.Body)
+	}
+	if calls := fake.recordedCalls(); len(calls) != 1 {
+		t.Errorf("transcoder called %d times, want 1", len(calls))
+	}
+}
@@ -0,0 +1,542 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 19:40:26
+ * @LastEditTime: 2026-10-19 15:41:07
+ * @FilePath: \CloudDisk\business\v2.go
+ * @Description: 资源风格的v2接口，使用HTTP方法区分操作，错误统一以JSON对象返回
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// v2接口路径前缀
+const v2Prefix = "/api/v2"
+
+// v2错误响应
+type v2Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *v2Error) Error() string { return e.Message }
+
+/**
+ * @description: 注册v2接口
+ * @param {*http.ServeMux} mux
+ * @return {*}
+ */
+func RegisterV2(mux *http.ServeMux) {
+	v2Route(mux, "/folders/{id}", map[string]http.HandlerFunc{
+		http.MethodGet:    v2GetFolder,
+		http.MethodPatch:  v2PatchFolder,
+		http.MethodDelete: v2DeleteFolder,
+	})
+	v2Route(mux, "/folders/{id}/children", map[string]http.HandlerFunc{
+		http.MethodGet:  v2ListChildren,
+		http.MethodPost: v2CreateChild,
+	})
+	v2Route(mux, "/files/{id}", map[string]http.HandlerFunc{
+		http.MethodGet:    v2GetFile,
+		http.MethodPatch:  v2PatchFile,
+		http.MethodDelete: v2DeleteFile,
+	})
+	v2Route(mux, "/files/{id}/content", map[string]http.HandlerFunc{
+		http.MethodGet: v2DownloadFile,
+		http.MethodPut: v2UploadFileContent,
+	})
+
+	// 未匹配的v2路径返回JSON格式的404
+	mux.HandleFunc(v2Prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeV2Error(w, &v2Error{http.StatusNotFound, "not_found", "Resource not found"})
+	})
+}
+
+/**
+ * @description: 注册一个资源路径，按方法分发，不支持的方法返回405和Allow头，所有处理函数都需要认证
+ * @param {*http.ServeMux} mux
+ * @param {string} pattern 资源路径
+ * @param {map[string]http.HandlerFunc} handlers 方法到处理函数的映射
+ * @return {*}
+ */
+func v2Route(mux *http.ServeMux, pattern string, handlers map[string]http.HandlerFunc) {
+	var methods []string
+	for method, handler := range handlers {
+		methods = append(methods, method)
+		handler := handler
+		mux.HandleFunc(method+" "+v2Prefix+pattern, func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := currentUser(r); !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="CloudDisk", charset="UTF-8"`)
+				writeV2Error(w, &v2Error{http.StatusUnauthorized, "unauthorized", "Unauthorized"})
+				return
+			}
+			handler(w, r)
+		})
+	}
+	sort.Strings(methods)
+
+	// 注册不带方法的同一路径，捕获其余方法
+	allow := strings.Join(methods, ", ")
+	mux.HandleFunc(v2Prefix+pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeV2Error(w, &v2Error{http.StatusMethodNotAllowed, "method_not_allowed", "Method " + r.Method + " is not allowed"})
+	})
+}
+
+/**
+ * @description: 写入JSON响应
+ * @return {*}
+ */
+func writeV2JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+/**
+ * @description: 写入JSON错误响应，业务错误转换为对应的状态码和错误码
+ * @param {http.ResponseWriter} w
+ * @param {error} err 错误
+ * @return {*}
+ */
+func writeV2Error(w http.ResponseWriter, err error) {
+	var e *v2Error
+	switch {
+	case errors.As(err, &e):
+	case errors.Is(err, dbwrapper.ErrFolderNotExist):
+		e = &v2Error{http.StatusNotFound, "folder_not_found", err.Error()}
+	case errors.Is(err, dbwrapper.ErrFileNotExist):
+		e = &v2Error{http.StatusNotFound, "file_not_found", err.Error()}
+	case errors.Is(err, dbwrapper.ErrParentFolderNotExist):
+		e = &v2Error{http.StatusNotFound, "parent_folder_not_found", err.Error()}
+	case errors.Is(err, dbwrapper.ErrFolderExist):
+		e = &v2Error{http.StatusConflict, "folder_exists", err.Error()}
+	case errors.Is(err, dbwrapper.ErrFileExist):
+		e = &v2Error{http.StatusConflict, "file_exists", err.Error()}
+	case errors.Is(err, dbwrapper.ErrMoveFolderIntoSelf):
+		e = &v2Error{http.StatusConflict, "move_into_self", err.Error()}
+	case errors.Is(err, errRootFolder):
+		e = &v2Error{http.StatusForbidden, "root_folder", err.Error()}
+	case errors.Is(err, errInvalidConflictPolicy):
+		e = &v2Error{http.StatusBadRequest, "invalid_argument", err.Error()}
+	case errors.Is(err, errInvalidName):
+		e = &v2Error{http.StatusBadRequest, "invalid_name", err.Error()}
+	case errors.Is(err, errPreconditionFailed):
+		e = &v2Error{http.StatusPreconditionFailed, "precondition_failed", err.Error()}
+	case errors.Is(err, dbwrapper.ErrInvalidSortField), errors.Is(err, dbwrapper.ErrInvalidCursor):
+		e = &v2Error{http.StatusBadRequest, "invalid_argument", err.Error()}
+	default:
+		e = &v2Error{http.StatusInternalServerError, "internal", err.Error()}
+	}
+
+	type ErrorResponse struct {
+		Error *v2Error `json:"error"`
+	}
+	writeV2JSON(w, e.Status, ErrorResponse{Error: e})
+}
+
+func v2BadRequest(format string, args ...interface{}) *v2Error {
+	return &v2Error{http.StatusBadRequest, "invalid_argument", fmt.Sprintf(format, args...)}
+}
+
+/**
+ * @description: 解析路径参数中的ID
+ * @param {*http.Request} r
+ * @return {int64} ID
+ */
+func v2PathID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, v2BadRequest("Invalid id %q", r.PathValue("id"))
+	}
+	return id, nil
+}
+
+func v2GetFolder(w http.ResponseWriter, r *http.Request) {
+	folderID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	folderInfo, err := dbwrapper.QueryFolderInfo(folderID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	writeV2JSON(w, http.StatusOK, folderInfo)
+}
+
+/**
+ * @description: 查询文件夹内容，查询参数：sortBy, order, cursor, limit, tag(可重复), metadata(可重复，格式key=value)
+ * @return {*}
+ */
+func v2ListChildren(w http.ResponseWriter, r *http.Request) {
+	folderID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	// 解析查询参数
+	query := r.URL.Query()
+	opts := dbwrapper.ListOptions{
+		SortBy: query.Get("sortBy"),
+		Cursor: query.Get("cursor"),
+		Tags:   query["tag"],
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		writeV2Error(w, v2BadRequest("Invalid order"))
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit < 0 || opts.Limit > 1000 {
+			writeV2Error(w, v2BadRequest("Invalid limit"))
+			return
+		}
+	}
+	for _, pair := range query["metadata"] {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			writeV2Error(w, v2BadRequest("Invalid metadata filter %q", pair))
+			return
+		}
+		if opts.Metadata == nil {
+			opts.Metadata = map[string]string{}
+		}
+		opts.Metadata[key] = value
+	}
+
+	// 查询文件夹内容
+	queryResult, err := dbwrapper.QueryFolderInfoFull(folderID, opts)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	writeV2JSON(w, http.StatusOK, queryResult)
+}
+
+/**
+ * @description: 在文件夹下新建子项，multipart/form-data请求上传file字段中的文件，JSON请求{"name": "..."}新建文件夹
+ * 名称冲突时按onConflict字段处理，新建时返回201，使用或覆盖已有的同名项时返回200，skip时另设X-Conflict-Skipped响应头
+ * @return {*}
+ */
+func v2CreateChild(w http.ResponseWriter, r *http.Request) {
+	parentFolderID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	// 上传文件
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, handler, err := r.FormFile("file")
+		if err != nil {
+			writeV2Error(w, v2BadRequest("Error retrieving the file"))
+			return
+		}
+		defer file.Close()
+
+		policy, err := parseConflictPolicy(r.FormValue("onConflict"))
+		if err != nil {
+			writeV2Error(w, err)
+			return
+		}
+
+		fileInfo, created, err := saveFileWithPolicy(parentFolderID, handler.Filename, file, policy)
+		if err != nil {
+			writeV2Error(w, err)
+			return
+		}
+		if policy == conflictSkip && !created {
+			setConflictSkipped(w, true)
+		} else {
+			recordRecent(r, fileInfo.ID, "upload")
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("%s/files/%d", v2Prefix, fileInfo.ID))
+		writeV2JSON(w, createdStatus(created), fileInfo)
+		return
+	}
+
+	// 新建文件夹
+	type CreateFolderRequest struct {
+		Name       string `json:"name"`
+		OnConflict string `json:"onConflict"`
+	}
+	var req CreateFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeV2Error(w, v2BadRequest("Invalid request body: %v", err))
+		return
+	}
+	if req.Name == "" {
+		writeV2Error(w, v2BadRequest("name is required"))
+		return
+	}
+
+	policy, err := parseConflictPolicy(req.OnConflict)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	folderInfo, created, err := createFolderWithPolicy(parentFolderID, req.Name, policy)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	setConflictSkipped(w, policy == conflictSkip && !created)
+	w.Header().Set("Location", fmt.Sprintf("%s/folders/%d", v2Prefix, folderInfo.ID))
+	writeV2JSON(w, createdStatus(created), folderInfo)
+}
+
+/**
+ * @description: 新建子项的响应状态码
+ * @param {bool} created 是否新建
+ * @return {int}
+ */
+func createdStatus(created bool) int {
+	if created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// PATCH请求体，未提供的字段保持不变
+type v2PatchRequest struct {
+	Name           *string `json:"name"`
+	ParentFolderID *int64  `json:"parentFolderId"`
+	OnConflict     string  `json:"onConflict"` // 名称冲突时的处理方式：fail(默认)/rename/overwrite/skip
+}
+
+/**
+ * @description: 修改文件夹名称或移动到其他文件夹，onConflict为skip且名称冲突时返回未修改的文件夹并设置X-Conflict-Skipped响应头
+ * @return {*}
+ */
+func v2PatchFolder(w http.ResponseWriter, r *http.Request) {
+	folderID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	var req v2PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeV2Error(w, v2BadRequest("Invalid request body: %v", err))
+		return
+	}
+
+	folderInfo, err := dbwrapper.QueryFolderInfo(folderID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	// 合并修改
+	newName, newParentFolderID := folderInfo.Name, folderInfo.ParentFolderID
+	if req.Name != nil {
+		newName = *req.Name
+	}
+	if req.ParentFolderID != nil {
+		newParentFolderID = *req.ParentFolderID
+	}
+	if newName == "" {
+		writeV2Error(w, v2BadRequest("name must not be empty"))
+		return
+	}
+
+	policy, err := parseConflictPolicy(req.OnConflict)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	skipped, err := moveFolderWithPolicy(folderID, newParentFolderID, newName, policy)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	setConflictSkipped(w, skipped)
+
+	folderInfo, err = dbwrapper.QueryFolderInfo(folderID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	writeV2JSON(w, http.StatusOK, folderInfo)
+}
+
+func v2DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	folderID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	if err := deleteFolder(folderID); err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func v2GetFile(w http.ResponseWriter, r *http.Request) {
+	fileID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	w.Header().Set("ETag", fileInfo.ETag())
+	writeV2JSON(w, http.StatusOK, fileInfo)
+}
+
+/**
+ * @description: 修改文件名称或移动到其他文件夹，onConflict为skip且名称冲突时返回未修改的文件并设置X-Conflict-Skipped响应头
+ * @return {*}
+ */
+func v2PatchFile(w http.ResponseWriter, r *http.Request) {
+	fileID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	var req v2PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeV2Error(w, v2BadRequest("Invalid request body: %v", err))
+		return
+	}
+
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	// 合并修改
+	newName, newParentFolderID := fileInfo.Name, fileInfo.ParentFolderID
+	if req.Name != nil {
+		newName = *req.Name
+	}
+	if req.ParentFolderID != nil {
+		newParentFolderID = *req.ParentFolderID
+	}
+	if newName == "" {
+		writeV2Error(w, v2BadRequest("name must not be empty"))
+		return
+	}
+
+	policy, err := parseConflictPolicy(req.OnConflict)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	skipped, err := moveFileWithPolicy(fileID, newParentFolderID, newName, policy)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	setConflictSkipped(w, skipped)
+
+	fileInfo, err = dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	writeV2JSON(w, http.StatusOK, fileInfo)
+}
+
+func v2DeleteFile(w http.ResponseWriter, r *http.Request) {
+	fileID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	if err := deleteFile(fileID); err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+ * @description: 下载文件内容，支持Range和条件请求
+ * @return {*}
+ */
+func v2DownloadFile(w http.ResponseWriter, r *http.Request) {
+	fileID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	fileInfo, err := dbwrapper.QueryFileInfo(fileID)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	localFile, err := os.Open(path.Join(GetBaseFolderPath(), fileInfo.Path))
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	defer localFile.Close()
+
+	recordRecent(r, fileInfo.ID, "download")
+
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", fileInfo.Name))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fileInfo.ETag())
+	http.ServeContent(w, r, fileInfo.Name, fileInfo.UpdatedAt, localFile)
+}
+
+/**
+ * @description: 以请求体覆盖文件内容，带If-Match请求头时只在文件的ETag匹配时覆盖，否则返回412
+ * @return {*}
+ */
+func v2UploadFileContent(w http.ResponseWriter, r *http.Request) {
+	fileID, err := v2PathID(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+
+	fileInfo, err := overwriteFileIfMatch(fileID, r.Body, r.Header.Get("If-Match"))
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	recordRecent(r, fileInfo.ID, "upload")
+
+	w.Header().Set("ETag", fileInfo.ETag())
+	writeV2JSON(w, http.StatusOK, fileInfo)
+}
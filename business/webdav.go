@@ -0,0 +1,430 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 16:40:52
+ * @LastEditTime: 2026-10-19 14:48:30
+ * @FilePath: \CloudDisk\business\webdav.go
+ * @Description: WebDAV服务，文件系统基于数据库中的文件夹/文件表和本地存储目录
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"CloudDisk/dto"
+	"CloudDisk/logwrapper"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+/**
+ * @description: 创建WebDAV处理器，需挂载在prefix路径下
+ * 锁保存在数据库中，重启后保留并在多个实例之间共享，但不约束JSON、S3、SFTP和gRPC接口，这些接口与WebDAV写入之间只由名称锁串行化
+ * @param {string} prefix 挂载路径
+ * @return {http.Handler}
+ */
+func NewWebDAVHandler(prefix string) http.Handler {
+	davHandler := &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: davFS{},
+		LockSystem: davLockSystem{},
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				logwrapper.Logger.Warnf("WebDAV %s %s failed: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireUser(w, r); !ok {
+			return
+		}
+
+		// 允许覆盖的MOVE和COPY请求，记录源路径供RemoveAll判断能否以替换代替删除，
+		// 与webdav.Handler一致，MOVE只在Overwrite为T时覆盖，COPY在Overwrite不为F时覆盖
+		if (r.Method == "MOVE" && r.Header.Get("Overwrite") == "T") || (r.Method == "COPY" && r.Header.Get("Overwrite") != "F") {
+			replace := &davReplace{method: r.Method, src: strings.TrimPrefix(r.URL.Path, prefix)}
+			r = r.WithContext(context.WithValue(r.Context(), davReplaceKey{}, replace))
+		}
+
+		// webdav.Handler在读取请求体失败后仍会关闭文件，记录读取错误，关闭时据此放弃写入
+		if r.Method == http.MethodPut && r.Body != nil {
+			body := &davPutBody{ReadCloser: r.Body, remaining: r.ContentLength}
+			r = r.WithContext(context.WithValue(r.Context(), davPutBodyKey{}, body))
+			r.Body = body
+		}
+		davHandler.ServeHTTP(w, r)
+	})
+}
+
+// webdav.Handler处理允许覆盖的MOVE和COPY时先RemoveAll已存在的目标再移动或复制，移动或复制失败时目标已丢失，
+// 同类型的替换改为不删除目标：MOVE在Rename中按overwrite策略替换，COPY文件时在写入时覆盖内容，
+// 文件和文件夹之间的MOVE先将目标改为临时名称，移动成功后删除，失败时改回原名称，COPY到已存在的文件夹仍先删除目标
+type davReplace struct {
+	method        string // MOVE/COPY
+	src           string // 源路径
+	target        string // 推迟替换的目标路径
+	asideFolderID int64  // 改为临时名称的目标文件夹ID
+	asideFileID   int64  // 改为临时名称的目标文件ID
+	asideName     string // 目标的原名称
+}
+
+type davReplaceKey struct{}
+
+// PUT的请求体，记录读取错误和长度不足，客户端中断上传时不保存已收到的部分
+type davPutBody struct {
+	io.ReadCloser
+	remaining int64 // Content-Length中剩余未读的字节数，未知时为负数
+	err       error
+}
+
+type davPutBodyKey struct{}
+
+func (b *davPutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.remaining >= 0 {
+		b.remaining -= int64(n)
+	}
+	switch {
+	case err == io.EOF && b.remaining > 0:
+		err = io.ErrUnexpectedEOF
+		b.err = err
+	case err != nil && err != io.EOF:
+		b.err = err
+	}
+	return n, err
+}
+
+// 实现webdav.FileSystem，所有修改操作都走与JSON接口相同的业务操作
+type davFS struct{}
+
+func (davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if folder, file, err := lookupPath(name); err == nil && (folder != nil || file != nil) {
+		return os.ErrExist
+	} else if err != nil && err != os.ErrNotExist {
+		return err
+	}
+
+	parent, err := lookupParent(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = createFolder(parent.ID, path.Base(name))
+	return osError(err)
+}
+
+func (davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	folder, file, err := lookupPath(name)
+	if err != nil && err != os.ErrNotExist {
+		return nil, err
+	}
+
+	// 只读打开
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) == 0 {
+		if folder != nil {
+			return &davDir{folder: folder}, nil
+		}
+		if file == nil {
+			return nil, os.ErrNotExist
+		}
+
+		localFile, err := os.Open(path.Join(GetBaseFolderPath(), file.Path))
+		if err != nil {
+			return nil, err
+		}
+		return &davReadFile{File: localFile, info: file}, nil
+	}
+
+	// 写入打开，文件夹不能写入
+	if folder != nil {
+		return nil, os.ErrPermission
+	}
+	if file != nil && flag&os.O_EXCL != 0 {
+		return nil, os.ErrExist
+	}
+	if file == nil && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	parent, err := lookupParent(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// 写入的内容通过管道交给业务操作，关闭时等待写入完成
+	pr, pw := io.Pipe()
+	writeFile := &davWriteFile{name: path.Base(name), pw: pw, done: make(chan error, 1)}
+	writeFile.body, _ = ctx.Value(davPutBodyKey{}).(*davPutBody)
+	go func() {
+		var err error
+		if file != nil {
+			_, err = overwriteFile(file.ID, pr)
+		} else {
+			_, err = saveFile(parent.ID, path.Base(name), pr)
+		}
+		pr.CloseWithError(err)
+		writeFile.done <- osError(err)
+	}()
+	return writeFile, nil
+}
+
+func (davFS) RemoveAll(ctx context.Context, name string) error {
+	folder, file, err := lookupPath(name)
+	if err == os.ErrNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// 允许覆盖的MOVE和COPY删除已存在的目标时，同类型的目标推迟到移动或写入时替换
+	if replace, ok := ctx.Value(davReplaceKey{}).(*davReplace); ok && replace.target == "" {
+		srcFolder, srcFile, err := lookupPath(replace.src)
+		if err != nil {
+			return err
+		}
+		switch {
+		case file != nil && srcFile != nil, folder != nil && srcFolder != nil && replace.method == "MOVE":
+			replace.target = path.Clean("/" + name)
+			return nil
+		case replace.method == "MOVE":
+			// 目标文件夹是源文件的上级时，删除目标会连同源文件一起删除
+			if folder != nil && srcFile != nil && strings.HasPrefix(srcFile.Path, folder.Path+"/") {
+				return os.ErrPermission
+			}
+			return replace.setAside(name, folder, file)
+		}
+	}
+
+	if folder != nil {
+		return osError(deleteFolder(folder.ID))
+	}
+	return osError(deleteFile(file.ID))
+}
+
+func (davFS) Rename(ctx context.Context, oldName, newName string) error {
+	folder, file, err := lookupPath(oldName)
+	if err != nil {
+		return err
+	}
+
+	parent, err := lookupParent(newName)
+	if err != nil {
+		return err
+	}
+
+	// 目标在RemoveAll中被推迟替换时，同类型的目标按overwrite策略移动，改为临时名称的目标在移动后删除或改回
+	policy := conflictFail
+	replace, ok := ctx.Value(davReplaceKey{}).(*davReplace)
+	if !ok || replace.target != path.Clean("/"+newName) {
+		replace = nil
+	} else if replace.asideFolderID == 0 && replace.asideFileID == 0 {
+		policy = conflictOverwrite
+	}
+
+	if folder != nil {
+		_, err = moveFolderWithPolicy(folder.ID, parent.ID, path.Base(newName), policy)
+	} else {
+		_, err = moveFileWithPolicy(file.ID, parent.ID, path.Base(newName), policy)
+	}
+	if replace != nil {
+		replace.finishAside(err == nil)
+	}
+	return osError(err)
+}
+
+/**
+ * @description: 将类型不同的目标改为临时名称，移动完成后由finishAside删除或改回原名称
+ * @param {string} name 目标路径
+ * @param {*dto.Folder} folder 目标文件夹，目标为文件时为nil
+ * @param {*dto.File} file 目标文件，目标为文件夹时为nil
+ * @return {error}
+ */
+func (replace *davReplace) setAside(name string, folder *dto.Folder, file *dto.File) error {
+	suffix, err := randomString(16, "abcdefghijklmnopqrstuvwxyz0123456789")
+	if err != nil {
+		return err
+	}
+
+	if folder != nil {
+		_, err = renameFolder(folder.ID, ".replaced-"+suffix, conflictFail)
+		replace.asideFolderID, replace.asideName = folder.ID, path.Base(folder.Path)
+	} else {
+		_, err = renameFile(file.ID, ".replaced-"+suffix, conflictFail)
+		replace.asideFileID, replace.asideName = file.ID, file.Name
+	}
+	if err != nil {
+		replace.asideFolderID, replace.asideFileID = 0, 0
+		return osError(err)
+	}
+	replace.target = path.Clean("/" + name)
+	return nil
+}
+
+/**
+ * @description: 移动成功后删除改为临时名称的目标，失败时改回原名称
+ * @param {bool} moved 是否移动成功
+ * @return {*}
+ */
+func (replace *davReplace) finishAside(moved bool) {
+	var err error
+	switch {
+	case replace.asideFolderID != 0 && moved:
+		err = deleteFolder(replace.asideFolderID)
+	case replace.asideFolderID != 0:
+		_, err = renameFolder(replace.asideFolderID, replace.asideName, conflictFail)
+	case replace.asideFileID != 0 && moved:
+		err = deleteFile(replace.asideFileID)
+	case replace.asideFileID != 0:
+		_, err = renameFile(replace.asideFileID, replace.asideName, conflictFail)
+	}
+	if err != nil {
+		logwrapper.Logger.Errorf("WebDAV failed to finish replacing %s: %v", replace.target, err)
+	}
+}
+
+func (davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	folder, file, err := lookupPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if folder != nil {
+		return folderFileInfo(folder), nil
+	}
+	return fileFileInfo(file), nil
+}
+
+// 实现os.FileInfo
+type davFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func folderFileInfo(folder *dto.Folder) davFileInfo {
+	return davFileInfo{name: path.Base(folder.Path), modTime: folder.UpdatedAt, isDir: true}
+}
+
+func fileFileInfo(file *dto.File) davFileInfo {
+	return davFileInfo{name: file.Name, size: file.Size, modTime: file.UpdatedAt}
+}
+
+func (fi davFileInfo) Name() string       { return fi.name }
+func (fi davFileInfo) Size() int64        { return fi.size }
+func (fi davFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi davFileInfo) IsDir() bool        { return fi.isDir }
+func (fi davFileInfo) Sys() interface{}   { return nil }
+func (fi davFileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// 只读打开的文件夹，子项从数据库中读取
+type davDir struct {
+	folder   *dto.Folder
+	children []fs.FileInfo
+	loaded   bool
+	offset   int
+}
+
+func (d *davDir) Close() error                                 { return nil }
+func (d *davDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *davDir) Write(p []byte) (int, error)                  { return 0, os.ErrInvalid }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *davDir) Stat() (fs.FileInfo, error)                   { return folderFileInfo(d.folder), nil }
+
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.loaded {
+		queryResult, err := dbwrapper.QueryFolderInfoFull(d.folder.ID, dbwrapper.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range queryResult.Folders {
+			d.children = append(d.children, folderFileInfo(&queryResult.Folders[i]))
+		}
+		for i := range queryResult.Files {
+			d.children = append(d.children, fileFileInfo(&queryResult.Files[i]))
+		}
+		d.loaded = true
+	}
+
+	// count<=0时返回剩余全部，否则最多返回count项，没有剩余时返回io.EOF
+	remaining := d.children[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.children)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
+
+// 只读打开的文件，内容直接读取本地文件
+type davReadFile struct {
+	*os.File
+	info *dto.File
+}
+
+func (f *davReadFile) Readdir(count int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *davReadFile) Write(p []byte) (int, error)              { return 0, os.ErrInvalid }
+func (f *davReadFile) Stat() (fs.FileInfo, error)               { return fileFileInfo(f.info), nil }
+
+// 写入打开的文件
+type davWriteFile struct {
+	name    string
+	pw      *io.PipeWriter
+	written int64
+	body    *davPutBody // PUT的请求体，读取失败时关闭不保存
+	done    chan error
+	closed  bool
+	err     error
+}
+
+func (f *davWriteFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (f *davWriteFile) Readdir(count int) ([]fs.FileInfo, error)     { return nil, os.ErrInvalid }
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	n, err := f.pw.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *davWriteFile) Stat() (fs.FileInfo, error) {
+	return davFileInfo{name: f.name, size: f.written, modTime: time.Now()}, nil
+}
+
+func (f *davWriteFile) Close() error {
+	if f.closed {
+		return f.err
+	}
+	f.closed = true
+
+	// 请求体读取失败时以错误关闭管道，业务操作丢弃临时文件，已有文件保持原内容
+	if f.body != nil && f.body.err != nil {
+		f.pw.CloseWithError(f.body.err)
+	} else {
+		f.pw.Close()
+	}
+	f.err = <-f.done
+	if f.err != nil && !errors.Is(f.err, os.ErrExist) {
+		logwrapper.Logger.Errorf("WebDAV write %s failed: %v", f.name, f.err)
+	}
+	return f.err
+}
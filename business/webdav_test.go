@@ -0,0 +1,248 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 11:37:05
+ * @LastEditTime: 2026-10-19 14:05:12
+ * @FilePath: \CloudDisk\business\webdav_test.go
+ * @Description: WebDAV覆盖移动、复制、中断上传和锁的测试
+ */
+package business
+
+import (
+	"CloudDisk/dbwrapper"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+/**
+ * @description: 发送WebDAV请求
+ * @param {http.Handler} handler WebDAV处理器
+ * @param {string} method 请求方法
+ * @param {string} src 源路径
+ * @param {string} dst 目标路径
+ * @param {string} overwrite Overwrite请求头，为空时不设置
+ * @return {int} 状态码
+ */
+func davRequest(handler http.Handler, method string, src string, dst string, overwrite string) int {
+	r := httptest.NewRequest(method, "/dav"+src, nil)
+	r.Header.Set("Destination", "http://example.com/dav"+dst)
+	if overwrite != "" {
+		r.Header.Set("Overwrite", overwrite)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestWebDAVMoveOverwrite(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+	parentPath, err := dbwrapper.QueryFolderPath(parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewWebDAVHandler("/dav")
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := saveFile(parentID, "b.txt", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createFolder(parentID, "dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite为F时不替换
+	if code := davRequest(handler, "MOVE", src.Path, target.Path, "F"); code != http.StatusPreconditionFailed {
+		t.Errorf("MOVE with Overwrite: F returned %d", code)
+	}
+
+	// 同类型的目标按overwrite策略替换
+	if code := davRequest(handler, "MOVE", src.Path, target.Path, "T"); code != http.StatusNoContent {
+		t.Fatalf("MOVE with overwrite returned %d", code)
+	}
+	if _, err := dbwrapper.QueryFileInfo(target.ID); err != dbwrapper.ErrFileNotExist {
+		t.Errorf("replaced file still in database: %v", err)
+	}
+	assertFileContent(t, target.Path, "new")
+	assertFolderEntries(t, parentID, "b.txt", "dir")
+
+	// 文件替换文件夹，文件夹被删除
+	if code := davRequest(handler, "MOVE", target.Path, parentPath+"/dir", "T"); code != http.StatusNoContent {
+		t.Fatalf("MOVE of a file over a folder returned %d", code)
+	}
+	if exists, err := dbwrapper.FolderExistByPath(parentPath + "/dir"); err != nil || exists {
+		t.Errorf("replaced folder still in database: %v", err)
+	}
+	assertFileContent(t, parentPath+"/dir", "new")
+	assertFolderEntries(t, parentID, "dir")
+
+	// 文件夹替换文件
+	folder, err := createFolder(parentID, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := davRequest(handler, "MOVE", folder.Path, parentPath+"/dir", "T"); code != http.StatusNoContent {
+		t.Fatalf("MOVE of a folder over a file returned %d", code)
+	}
+	if exists, err := dbwrapper.FileExistByPath(parentPath + "/dir"); err != nil || exists {
+		t.Errorf("replaced file still in database: %v", err)
+	}
+	if moved, err := dbwrapper.QueryFolderInfo(folder.ID); err != nil || moved.Path != parentPath+"/dir" {
+		t.Errorf("moved folder: %+v, %v", moved, err)
+	}
+	assertFolderEntries(t, parentID, "dir")
+}
+
+func TestWebDAVCopyOverwrite(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+	handler := NewWebDAVHandler("/dav")
+
+	src, err := saveFile(parentID, "a.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := saveFile(parentID, "b.txt", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 复制到已存在的文件时覆盖内容，目标文件的ID不变
+	if code := davRequest(handler, "COPY", src.Path, target.Path, ""); code != http.StatusNoContent {
+		t.Fatalf("COPY with overwrite returned %d", code)
+	}
+	copied, err := dbwrapper.QueryFileInfo(target.ID)
+	if err != nil {
+		t.Fatalf("target file replaced instead of overwritten: %v", err)
+	}
+	assertFileContent(t, copied.Path, "new")
+	assertFileContent(t, src.Path, "new")
+	assertFolderEntries(t, parentID, "a.txt", "b.txt")
+}
+
+func TestWebDAVInterruptedPut(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+	parentPath, err := dbwrapper.QueryFolderPath(parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewWebDAVHandler("/dav")
+	existing, err := saveFile(parentID, "a.txt", strings.NewReader("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		body          io.Reader
+		contentLength int64
+	}{
+		// 读取到一半连接断开
+		{"read error on existing file", existing.Path, io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset"))), -1},
+		// 请求体短于Content-Length
+		{"short body on existing file", existing.Path, strings.NewReader("partial"), 100},
+		{"read error on new file", parentPath + "/new.txt", io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset"))), -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/dav"+tt.path, io.NopCloser(tt.body))
+			r.ContentLength = tt.contentLength
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code < 400 {
+				t.Errorf("interrupted PUT returned %d", w.Code)
+			}
+
+			// 已有文件保持原内容，不新建文件
+			assertFileContent(t, existing.Path, "original")
+			assertFolderEntries(t, parentID, "a.txt")
+		})
+	}
+
+	// 完整的请求体正常写入
+	r := httptest.NewRequest(http.MethodPut, "/dav"+existing.Path, strings.NewReader("complete"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code >= 400 {
+		t.Fatalf("PUT returned %d", w.Code)
+	}
+	assertFileContent(t, existing.Path, "complete")
+}
+
+func TestWebDAVLockSystem(t *testing.T) {
+	requireTestDB(t)
+	parentID := createTestFolder(t)
+	parentPath, err := dbwrapper.QueryFolderPath(parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	// 文件夹上的深度锁与子路径上的新锁冲突
+	ls := davLockSystem{}
+	token, err := ls.Create(now, webdav.LockDetails{Root: parentPath, Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ls.Create(now, webdav.LockDetails{Root: parentPath + "/a.txt", Duration: time.Minute, ZeroDepth: true}); err != webdav.ErrLocked {
+		t.Errorf("Create under a depth-infinity lock returned %v", err)
+	}
+
+	// 锁保存在数据库中，新的锁系统实例同样能确认和刷新
+	release, err := davLockSystem{}.Confirm(now, parentPath+"/a.txt", "", webdav.Condition{Token: token})
+	if err != nil {
+		t.Fatalf("Confirm with the lock token: %v", err)
+	}
+	release()
+	if _, err := ls.Confirm(now, parentPath+"/a.txt", "", webdav.Condition{Token: "opaquelocktoken:unknown"}); err != webdav.ErrConfirmationFailed {
+		t.Errorf("Confirm with an unknown token returned %v", err)
+	}
+	if details, err := ls.Refresh(now, token, 2*time.Minute); err != nil || details.Duration != 2*time.Minute || details.Root != parentPath {
+		t.Errorf("Refresh returned %+v, %v", details, err)
+	}
+
+	// 没有令牌的写请求被拒绝
+	handler := NewWebDAVHandler("/dav")
+	r := httptest.NewRequest(http.MethodPut, "/dav"+parentPath+"/a.txt", strings.NewReader("data"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusLocked {
+		t.Errorf("PUT into a locked folder returned %d", w.Code)
+	}
+
+	// 解锁后可以重新加锁，过期的锁不再冲突
+	if err := ls.Unlock(now, token); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.Unlock(now, token); err != webdav.ErrNoSuchLock {
+		t.Errorf("second Unlock returned %v", err)
+	}
+	token, err = ls.Create(now, webdav.LockDetails{Root: parentPath, Duration: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := ls.Create(now.Add(2*time.Second), webdav.LockDetails{Root: parentPath, Duration: time.Minute})
+	if err != nil {
+		t.Fatalf("Create over an expired lock: %v", err)
+	}
+	if _, err := ls.Refresh(now.Add(2*time.Second), token, time.Minute); err != webdav.ErrNoSuchLock {
+		t.Errorf("Refresh of an expired lock returned %v", err)
+	}
+	if err := ls.Unlock(now.Add(2*time.Second), expired); err != nil {
+		t.Fatal(err)
+	}
+}
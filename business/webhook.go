@@ -0,0 +1,605 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 23:26:04
+ * @LastEditTime: 2026-10-19 18:03:26
+ * @FilePath: \CloudDisk\business\webhook.go
+ * @Description: Webhook订阅接口及投递协程，变更提交后向订阅地址POST签名的JSON
+ */
+package business
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/dbwrapper"
+	"CloudDisk/logwrapper"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second  // 没有新变更时检查到期重试的间隔
+	webhookTimeout      = 10 * time.Second // 单次投递的超时时间
+	webhookMaxAttempts  = 8                // 最多投递次数
+	webhookBaseBackoff  = 10 * time.Second // 第一次重试的等待时间，之后每次翻倍
+	webhookMaxBackoff   = time.Hour        // 重试等待时间上限
+	webhookConcurrency  = 8                // 同时进行的投递数
+	webhookBatchSize    = 100              // 每次取出的到期任务数
+	webhookMaxErrorLen  = 1024             // 投递记录中错误信息的最大字节数
+)
+
+// 签名请求头，值为sha256=请求体的HMAC-SHA256
+const WebhookSignatureHeader = "X-CloudDisk-Signature"
+
+var errWebhookForbiddenTarget = errors.New("webhook target resolves to a loopback, link-local or private address")
+
+// 不在公网上的地址段，netip未提供判断方法的部分
+var webhookReservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF协议分配
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),   // 保留及广播
+}
+
+// 投递使用的客户端，连接时按实际解析出的地址检查，防止DNS重绑定绕过创建时的检查，不跟随重定向，也不使用代理
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConnsPerHost: webhookConcurrency,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		// 3xx按非2xx响应记为失败
+		return http.ErrUseLastResponse
+	},
+}
+
+/**
+ * @description: 地址是否允许作为投递目标，未开启allowPrivateTargets时拒绝本机、链路本地(含云平台元数据地址)、内网和保留地址
+ * @param {netip.Addr} addr
+ * @return {bool}
+ */
+func webhookAddrAllowed(addr netip.Addr) bool {
+	if configwrapper.Cfg.Webhook.AllowPrivateTargets {
+		return true
+	}
+
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range webhookReservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * @description: 建立连接前检查解析出的地址
+ * @param {string} network
+ * @param {string} address ip:port
+ * @param {syscall.RawConn} c
+ * @return {error}
+ */
+func webhookDialControl(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !webhookAddrAllowed(addr) {
+		return errWebhookForbiddenTarget
+	}
+	return nil
+}
+
+/**
+ * @description: 检查Webhook地址，必须是http或https的绝对地址，主机名解析出的所有地址都必须允许投递
+ * @param {context.Context} ctx
+ * @param {string} rawURL 接收地址
+ * @return {error}
+ */
+func validateWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		addrs = []netip.Addr{addr}
+	} else if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname()); err != nil {
+		return fmt.Errorf("cannot resolve url host: %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !webhookAddrAllowed(addr) {
+			return errWebhookForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// 投递的请求体，每条变更投递一次
+// 文件夹的移动和删除只投递文件夹本身的变更，其下的子孙条目随之移动或删除，不再单独投递
+type WebhookPayload struct {
+	DeliveryID int64            `json:"deliveryId"`
+	WebhookID  int64            `json:"webhookId"`
+	Event      string           `json:"event"`
+	Change     dbwrapper.Change `json:"change"`
+}
+
+/**
+ * @description: 计算请求体的签名
+ * @param {string} secret 签名密钥
+ * @param {[]byte} body 请求体
+ * @return {string} sha256=十六进制HMAC
+ */
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/**
+ * @description: 向接收方POST一次变更
+ * @param {context.Context} ctx
+ * @param {*http.Client} client
+ * @param {string} target 接收地址
+ * @param {string} secret 签名密钥
+ * @param {WebhookPayload} payload 请求体
+ * @return {int} HTTP状态码，未收到响应时为0
+ * @return {error} 网络错误或非2xx响应
+ */
+func sendWebhook(ctx context.Context, client *http.Client, target string, secret string, payload WebhookPayload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CloudDisk-Webhook")
+	req.Header.Set("X-CloudDisk-Event", payload.Event)
+	req.Header.Set("X-CloudDisk-Delivery", fmt.Sprint(payload.DeliveryID))
+	req.Header.Set(WebhookSignatureHeader, signWebhook(secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+/**
+ * @description: 第attempts次投递失败后的重试等待时间
+ * @param {int} attempts 已投递次数
+ * @return {time.Duration}
+ */
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+/**
+ * @description: 将错误信息截断到webhookMaxErrorLen字节以内，不截断在多字节字符中间
+ * @param {string} msg 错误信息
+ * @return {string}
+ */
+func truncateWebhookError(msg string) string {
+	if len(msg) <= webhookMaxErrorLen {
+		return msg
+	}
+	n := webhookMaxErrorLen
+	for n > 0 && !utf8.RuneStart(msg[n]) {
+		n--
+	}
+	return msg[:n]
+}
+
+/**
+ * @description: 变更是否符合Webhook的订阅条件
+ * @param {*dbwrapper.Webhook} hook
+ * @param {dbwrapper.Change} change
+ * @return {bool}
+ */
+func webhookMatches(hook *dbwrapper.Webhook, change dbwrapper.Change) bool {
+	if !changeInFolder(hook.FolderPath, change) {
+		return false
+	}
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, event := range hook.Events {
+		if event == change.Action {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @description: 启动Webhook投递协程，变更提交后立即生成投递记录并投递，失败的按退避时间重试
+ * @return {*}
+ */
+func StartWebhookWorker() {
+	go func() {
+		for {
+			// 先取通知通道，处理期间提交的变更会立即触发下一轮
+			signal := dbwrapper.ChangeSignal()
+			if err := dispatchWebhooks(); err != nil {
+				logwrapper.Logger.Errorf("Failed to dispatch webhooks: %v", err)
+			}
+			if err := deliverDueWebhooks(); err != nil {
+				logwrapper.Logger.Errorf("Failed to deliver webhooks: %v", err)
+			}
+
+			select {
+			case <-signal:
+			case <-time.After(webhookPollInterval):
+			}
+		}
+	}()
+}
+
+/**
+ * @description: 为各Webhook游标之后的匹配变更生成投递记录
+ * @return {*}
+ */
+func dispatchWebhooks() error {
+	hooks, err := dbwrapper.QueryWebhooks("")
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	cursor := hooks[0].LastChangeID
+	for _, hook := range hooks {
+		cursor = min(cursor, hook.LastChangeID)
+	}
+
+	for {
+		changes, err := dbwrapper.QueryChanges(cursor, maxChangesLimit)
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		lastChangeID := changes[len(changes)-1].ID
+
+		for i := range hooks {
+			hook := &hooks[i]
+			if hook.LastChangeID >= lastChangeID {
+				continue
+			}
+			changeIDs := []int64{}
+			for _, change := range changes {
+				if change.ID > hook.LastChangeID && webhookMatches(hook, change) {
+					changeIDs = append(changeIDs, change.ID)
+				}
+			}
+			// Webhook在此期间被删除时插入失败，跳过即可
+			if err := dbwrapper.EnqueueWebhookDeliveries(hook.ID, changeIDs, lastChangeID); err != nil {
+				logwrapper.Logger.Warnf("Failed to enqueue deliveries of webhook %d: %v", hook.ID, err)
+				continue
+			}
+			hook.LastChangeID = lastChangeID
+		}
+
+		cursor = lastChangeID
+		if len(changes) < maxChangesLimit {
+			return nil
+		}
+	}
+}
+
+/**
+ * @description: 投递所有到期的任务
+ * @return {*}
+ */
+func deliverDueWebhooks() error {
+	for {
+		tasks, err := dbwrapper.QueryDueWebhookTasks(time.Now(), webhookBatchSize)
+		if err != nil || len(tasks) == 0 {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, webhookConcurrency)
+		for _, task := range tasks {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(task dbwrapper.WebhookTask) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				deliverWebhook(task)
+			}(task)
+		}
+		wg.Wait()
+
+		if len(tasks) < webhookBatchSize {
+			return nil
+		}
+	}
+}
+
+/**
+ * @description: 投递一次并记录结果
+ * @param {dbwrapper.WebhookTask} task
+ * @return {*}
+ */
+func deliverWebhook(task dbwrapper.WebhookTask) {
+	payload := WebhookPayload{
+		DeliveryID: task.DeliveryID,
+		WebhookID:  task.WebhookID,
+		Event:      task.Change.Action,
+		Change:     task.Change,
+	}
+	responseStatus, err := sendWebhook(context.Background(), webhookClient, task.URL, task.Secret, payload)
+
+	attempts := task.Attempts + 1
+	status, errMsg := dbwrapper.DeliverySucceeded, ""
+	var nextAttemptAt *time.Time
+	if err != nil {
+		errMsg = truncateWebhookError(err.Error())
+		if attempts < webhookMaxAttempts {
+			next := time.Now().Add(webhookBackoff(attempts))
+			status, nextAttemptAt = dbwrapper.DeliveryPending, &next
+		} else {
+			status = dbwrapper.DeliveryFailed
+		}
+	}
+
+	if err := dbwrapper.UpdateWebhookDelivery(task.DeliveryID, status, attempts, responseStatus, errMsg, nextAttemptAt); err != nil {
+		logwrapper.Logger.Errorf("Failed to update webhook delivery %d: %v", task.DeliveryID, err)
+	}
+}
+
+/**
+ * @description: 新建Webhook api，未指定密钥时生成，密钥只在创建时返回
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	type CreateWebhookRequest struct {
+		URL        string   `json:"url"`
+		Secret     string   `json:"secret"`
+		Events     []string `json:"events"`
+		FolderPath string   `json:"folderPath"`
+	}
+	var req CreateWebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 检查参数
+	if err := validateWebhookURL(r.Context(), req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, event := range req.Events {
+		switch event {
+		case dbwrapper.ChangeCreate, dbwrapper.ChangeRename, dbwrapper.ChangeMove, dbwrapper.ChangeDelete, dbwrapper.ChangeUpdate:
+		default:
+			http.Error(w, "Invalid event: "+event, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Secret == "" {
+		if req.Secret, err = randomString(40, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(req.Secret) > 128 {
+		http.Error(w, "secret is too long", http.StatusBadRequest)
+		return
+	}
+
+	// 保存Webhook
+	hook := dbwrapper.Webhook{
+		UserName:   user,
+		URL:        req.URL,
+		Secret:     req.Secret,
+		Events:     req.Events,
+		FolderPath: cleanPath(req.FolderPath),
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	if err := dbwrapper.CreateWebhook(&hook); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+/**
+ * @description: 删除Webhook api
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	type DeleteWebhookRequest struct {
+		WebhookID int64 `json:"webhookId"`
+	}
+	var req DeleteWebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 删除Webhook
+	if err := dbwrapper.DeleteWebhook(req.WebhookID, user); err == dbwrapper.ErrWebhookNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 返回成功信息
+	w.Write([]byte("Webhook deleted successfully"))
+}
+
+/**
+ * @description: 查询Webhook列表api，不返回密钥
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryWebhooks(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 查询Webhook
+	hooks, err := dbwrapper.QueryWebhooks(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+/**
+ * @description: 查询Webhook投递记录api，最新的在前
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func QueryWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// 只支持POST请求
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusNotFound)
+		return
+	}
+
+	// 认证用户
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 解析请求体
+	type QueryWebhookDeliveriesRequest struct {
+		WebhookID int64  `json:"webhookId"`
+		Status    string `json:"status"` // pending/succeeded/failed，为空时不过滤
+		Limit     int    `json:"limit"`  // 默认100，最大1000
+	}
+	var req QueryWebhookDeliveriesRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 检查参数
+	switch req.Status {
+	case "", dbwrapper.DeliveryPending, dbwrapper.DeliverySucceeded, dbwrapper.DeliveryFailed:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = webhookBatchSize
+	}
+	req.Limit = min(req.Limit, maxChangesLimit)
+
+	// 只能查询自己的Webhook
+	if _, err := dbwrapper.QueryWebhook(req.WebhookID, user); err == dbwrapper.ErrWebhookNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deliveries, err := dbwrapper.QueryWebhookDeliveries(req.WebhookID, req.Status, req.Limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 结果写入响应体
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,70 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 22:10:45
+ * @LastEditTime: 2026-10-18 22:10:45
+ * @FilePath: \CloudDisk\client\change.go
+ * @Description: 变更日志接口
+ */
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 变更类型
+const (
+	ChangeCreate = "create"
+	ChangeRename = "rename"
+	ChangeMove   = "move"
+	ChangeDelete = "delete"
+	ChangeUpdate = "update"
+)
+
+// 单条变更，文件夹的移动和删除只记录文件夹本身
+type Change struct {
+	ID             int64     `json:"id"`
+	ItemType       string    `json:"itemType"` // file/folder
+	ItemID         int64     `json:"itemId"`
+	Action         string    `json:"action"`
+	Path           string    `json:"path"`              // 变更后的路径，删除时为删除前的路径
+	OldPath        string    `json:"oldPath,omitempty"` // 改名和移动前的路径
+	ParentFolderID int64     `json:"parentFolderId"`
+	Size           int64     `json:"size"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type ChangesResult struct {
+	Changes []Change `json:"changes"`
+	Cursor  string   `json:"cursor"`
+	HasMore bool     `json:"hasMore"`
+}
+
+/**
+ * @description: 查询游标之后的变更
+ * @param {string} cursor 上次返回的游标，为空时只返回当前游标
+ * @param {int} limit 最多返回条数，为0时使用服务端默认值
+ * @return {*ChangesResult}
+ */
+func (c *Client) QueryChanges(ctx context.Context, cursor string, limit int) (*ChangesResult, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/api/changes"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var result ChangesResult
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,513 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:12:48
+ * @LastEditTime: 2026-10-19 15:41:07
+ * @FilePath: \CloudDisk\client\client.go
+ * @Description: CloudDisk接口的Go客户端，接口定义见openapi/openapi.json
+ */
+package client
+
+import (
+	"CloudDisk/dto"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 客户端
+type Client struct {
+	BaseURL    string // 服务地址，如http://localhost:8080
+	Username   string // 为空时不发送认证信息
+	Password   string
+	HTTPClient *http.Client
+
+	V2 *V2Client // 资源风格的v2接口
+}
+
+/**
+ * @description: 创建客户端
+ * @param {string} baseURL 服务地址
+ * @param {string} username 用户名，为空时不发送认证信息
+ * @param {string} password 密码
+ * @return {*Client}
+ */
+func New(baseURL string, username string, password string) *Client {
+	c := &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Username:   username,
+		Password:   password,
+		HTTPClient: http.DefaultClient,
+	}
+	c.V2 = &V2Client{c: c}
+	return c
+}
+
+// 接口返回的错误
+type Error struct {
+	StatusCode int
+	Code       string // v2接口的错误码，v1接口为空
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("clouddisk: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("clouddisk: %d: %s", e.StatusCode, e.Message)
+}
+
+/**
+ * @description: 发送请求，状态码不是2xx时返回*Error
+ * @param {context.Context} ctx
+ * @param {string} method 请求方法
+ * @param {string} path 请求路径
+ * @param {string} contentType 请求体类型
+ * @param {io.Reader} body 请求体
+ * @return {*http.Response} 调用者负责关闭响应体
+ */
+func (c *Client) do(ctx context.Context, method string, path string, contentType string, body io.Reader) (*http.Response, error) {
+	return c.doWithHeader(ctx, method, path, http.Header{"Content-Type": {contentType}}, body)
+}
+
+/**
+ * @description: 发送带额外请求头的请求，状态码不是2xx时返回*Error
+ * @param {http.Header} header 请求头，值为空的请求头不发送
+ * @return {*http.Response} 调用者负责关闭响应体
+ */
+func (c *Client) doWithHeader(ctx context.Context, method string, path string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		if len(values) > 0 && values[0] != "" {
+			req.Header[key] = values
+		}
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	// v1接口返回纯文本错误，v2接口返回JSON错误对象
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	var errResp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(data, &errResp) == nil {
+		apiErr.Code, apiErr.Message = errResp.Error.Code, errResp.Error.Message
+	}
+	return nil, apiErr
+}
+
+/**
+ * @description: 发送JSON请求并解析JSON响应
+ * @param {interface{}} in 请求体，为nil时不发送请求体
+ * @param {interface{}} out 响应体，为nil时丢弃响应体
+ * @return {*}
+ */
+func (c *Client) doJSON(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	_, err := c.doJSONHeader(ctx, method, path, in, out)
+	return err
+}
+
+/**
+ * @description: 发送JSON请求并解析JSON响应，同时返回响应头
+ * @param {interface{}} in 请求体，为nil时不发送请求体
+ * @param {interface{}} out 响应体，为nil时丢弃响应体
+ * @return {http.Header} 响应头
+ */
+func (c *Client) doJSONHeader(ctx context.Context, method string, path string, in interface{}, out interface{}) (http.Header, error) {
+	var body io.Reader
+	var contentType string
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	resp, err := c.do(ctx, method, path, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return resp.Header, err
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
+
+/**
+ * @description: 以multipart/form-data流式上传文件，不会将文件内容读入内存
+ * @param {string} fields 其他表单字段
+ * @param {string} fileName 文件名
+ * @param {io.Reader} content 文件内容
+ * @param {interface{}} out 响应体
+ * @return {*}
+ */
+func (c *Client) doUpload(ctx context.Context, method string, path string, fields map[string]string, fileName string, content io.Reader, out interface{}) error {
+	_, err := c.doUploadHeader(ctx, method, path, fields, fileName, content, out)
+	return err
+}
+
+/**
+ * @description: 以multipart/form-data流式上传文件，同时返回响应头
+ * @param {string} fields 其他表单字段
+ * @param {string} fileName 文件名
+ * @param {io.Reader} content 文件内容
+ * @param {interface{}} out 响应体
+ * @return {http.Header} 响应头
+ */
+func (c *Client) doUploadHeader(ctx context.Context, method string, path string, fields map[string]string, fileName string, content io.Reader, out interface{}) (http.Header, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			for key, value := range fields {
+				if err := mw.WriteField(key, value); err != nil {
+					return err
+				}
+			}
+			part, err := mw.CreateFormFile("file", fileName)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, content); err != nil {
+				return err
+			}
+			return mw.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+
+	resp, err := c.do(ctx, method, path, mw.FormDataContentType(), pr)
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// 文件夹查询结果
+type QueryFolderResult struct {
+	Self       *dto.Folder  `json:"self"`
+	Folders    []dto.Folder `json:"folders"`
+	Files      []dto.File   `json:"files"`
+	Total      int64        `json:"total"`
+	NextCursor string       `json:"nextCursor"`
+}
+
+// 文件夹列表的排序、分页和过滤条件
+type ListOptions struct {
+	SortBy   string            `json:"sortBy,omitempty"` // name/size/createdAt/updatedAt/type
+	Order    string            `json:"order,omitempty"`  // asc/desc
+	Cursor   string            `json:"cursor,omitempty"`
+	Limit    int               `json:"limit,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+/**
+ * @description: 查询文件夹内容
+ * @param {int64} folderID 文件夹ID
+ * @param {ListOptions} opts 排序、分页和过滤条件
+ * @return {*QueryFolderResult}
+ */
+func (c *Client) QueryFolder(ctx context.Context, folderID int64, opts ListOptions) (*QueryFolderResult, error) {
+	req := struct {
+		FolderID int64 `json:"folderID"`
+		ListOptions
+	}{folderID, opts}
+	var result QueryFolderResult
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryFolder", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateFolder(ctx context.Context, parentFolderID int64, folderName string) (*dto.Folder, error) {
+	folder, _, err := c.CreateFolderOnConflict(ctx, parentFolderID, folderName, "")
+	return folder, err
+}
+
+/**
+ * @description: 上传文件
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} fileName 文件名
+ * @param {io.Reader} content 文件内容
+ * @return {*dto.File}
+ */
+func (c *Client) UploadFile(ctx context.Context, parentFolderID int64, fileName string, content io.Reader) (*dto.File, error) {
+	file, _, err := c.UploadFileOnConflict(ctx, parentFolderID, fileName, content, "")
+	return file, err
+}
+
+func (c *Client) RenameFolder(ctx context.Context, folderID int64, folderName string) error {
+	_, err := c.RenameFolderOnConflict(ctx, folderID, folderName, "")
+	return err
+}
+
+func (c *Client) RenameFile(ctx context.Context, fileID int64, fileName string) error {
+	_, err := c.RenameFileOnConflict(ctx, fileID, fileName, "")
+	return err
+}
+
+func (c *Client) DeleteFolder(ctx context.Context, folderID int64) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteFolder", map[string]int64{"folderID": folderID}, nil)
+}
+
+func (c *Client) DeleteFile(ctx context.Context, fileID int64) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteFile", map[string]int64{"fileID": fileID}, nil)
+}
+
+/**
+ * @description: 下载文件
+ * @param {int64} fileID 文件ID
+ * @return {io.ReadCloser} 文件内容，调用者负责关闭
+ */
+func (c *Client) DownloadFile(ctx context.Context, fileID int64) (io.ReadCloser, error) {
+	data, _ := json.Marshal(map[string]int64{"fileID": fileID})
+	resp, err := c.do(ctx, http.MethodPost, "/api/downloadFile", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+/**
+ * @description: 获取图片缩略图
+ * @param {int64} fileID 文件ID
+ * @param {string} size 尺寸：small/medium/large，为空时为medium
+ * @return {io.ReadCloser} JPEG或PNG图片，调用者负责关闭
+ */
+func (c *Client) Thumbnail(ctx context.Context, fileID int64, size string) (io.ReadCloser, error) {
+	query := url.Values{"fileId": {strconv.FormatInt(fileID, 10)}}
+	if size != "" {
+		query.Set("size", size)
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/api/thumbnail?"+query.Encode(), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+/**
+ * @description: 以文件的实际类型获取文件内容，用于在线预览
+ * @param {int64} fileID 文件ID
+ * @return {io.ReadCloser} 文件内容，调用者负责关闭
+ * @return {string} Content-Type
+ */
+func (c *Client) Preview(ctx context.Context, fileID int64) (io.ReadCloser, string, error) {
+	query := url.Values{"fileId": {strconv.FormatInt(fileID, 10)}}
+	resp, err := c.do(ctx, http.MethodGet, "/api/preview?"+query.Encode(), "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// 视频尚在转码，稍后重试
+var ErrVideoNotReady = errors.New("video is being transcoded")
+
+/**
+ * @description: 获取视频转码结果中的文件，如master.m3u8、360p/index.m3u8、poster.jpg
+ * @param {int64} fileID 文件ID
+ * @param {string} name 转码结果中的相对路径
+ * @return {io.ReadCloser} 文件内容，调用者负责关闭；转码未完成时返回ErrVideoNotReady
+ */
+func (c *Client) VideoFile(ctx context.Context, fileID int64, name string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/video/"+strconv.FormatInt(fileID, 10)+"/"+name, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		resp.Body.Close()
+		return nil, ErrVideoNotReady
+	}
+	return resp.Body, nil
+}
+
+// 搜索条件
+type SearchRequest struct {
+	Keyword        string            `json:"keyword,omitempty"`
+	Mode           string            `json:"mode,omitempty"`    // substring/glob
+	MatchIn        string            `json:"matchIn,omitempty"` // name/path
+	Type           string            `json:"type,omitempty"`    // file/folder
+	MinSize        *int64            `json:"minSize,omitempty"`
+	MaxSize        *int64            `json:"maxSize,omitempty"`
+	ModifiedAfter  *time.Time        `json:"modifiedAfter,omitempty"`
+	ModifiedBefore *time.Time        `json:"modifiedBefore,omitempty"`
+	RootFolderID   int64             `json:"rootFolderID,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	SortBy         string            `json:"sortBy,omitempty"`
+	Order          string            `json:"order,omitempty"`
+	Page           int               `json:"page,omitempty"`
+	PageSize       int               `json:"pageSize,omitempty"`
+}
+
+// 搜索结果，文件夹的FileType为dto.FileTypeFolder
+type SearchResult struct {
+	Total int64      `json:"total"`
+	Items []dto.File `json:"items"`
+}
+
+func (c *Client) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	var result SearchResult
+	if err := c.doJSON(ctx, http.MethodPost, "/api/search", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// 全文搜索条件
+type SearchContentRequest struct {
+	Keyword      string `json:"keyword"`
+	RootFolderID int64  `json:"rootFolderID,omitempty"`
+	Page         int    `json:"page,omitempty"`
+	PageSize     int    `json:"pageSize,omitempty"`
+}
+
+// 全文搜索结果
+type ContentSearchResult struct {
+	Total int64 `json:"total"`
+	Items []struct {
+		File    dto.File `json:"file"`
+		Score   float64  `json:"score"`
+		Snippet string   `json:"snippet"`
+	} `json:"items"`
+}
+
+func (c *Client) SearchContent(ctx context.Context, req SearchContentRequest) (*ContentSearchResult, error) {
+	var result ContentSearchResult
+	if err := c.doJSON(ctx, http.MethodPost, "/api/searchContent", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// 条目类型
+const (
+	ItemTypeFile   = "file"
+	ItemTypeFolder = "folder"
+)
+
+func (c *Client) AddTags(ctx context.Context, itemType string, itemID int64, tags []string) error {
+	req := map[string]interface{}{"itemType": itemType, "itemID": itemID, "tags": tags}
+	return c.doJSON(ctx, http.MethodPost, "/api/addTags", req, nil)
+}
+
+func (c *Client) RemoveTags(ctx context.Context, itemType string, itemID int64, tags []string) error {
+	req := map[string]interface{}{"itemType": itemType, "itemID": itemID, "tags": tags}
+	return c.doJSON(ctx, http.MethodPost, "/api/removeTags", req, nil)
+}
+
+// 标签及其使用次数
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+func (c *Client) QueryTags(ctx context.Context) ([]TagCount, error) {
+	var tags []TagCount
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryTags", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (c *Client) SetMetadata(ctx context.Context, itemType string, itemID int64, metadata map[string]string) error {
+	req := map[string]interface{}{"itemType": itemType, "itemID": itemID, "metadata": metadata}
+	return c.doJSON(ctx, http.MethodPost, "/api/setMetadata", req, nil)
+}
+
+func (c *Client) DeleteMetadata(ctx context.Context, itemType string, itemID int64, keys []string) error {
+	req := map[string]interface{}{"itemType": itemType, "itemID": itemID, "keys": keys}
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteMetadata", req, nil)
+}
+
+func (c *Client) AddFavorite(ctx context.Context, itemType string, itemID int64) error {
+	req := map[string]interface{}{"itemType": itemType, "itemID": itemID}
+	return c.doJSON(ctx, http.MethodPost, "/api/addFavorite", req, nil)
+}
+
+func (c *Client) RemoveFavorite(ctx context.Context, itemType string, itemID int64) error {
+	req := map[string]interface{}{"itemType": itemType, "itemID": itemID}
+	return c.doJSON(ctx, http.MethodPost, "/api/removeFavorite", req, nil)
+}
+
+func (c *Client) QueryFavorites(ctx context.Context) (*QueryFolderResult, error) {
+	var result QueryFolderResult
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryFavorites", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+/**
+ * @description: 查询最近访问的文件
+ * @param {int} limit 返回条数，为0时使用服务端默认值
+ * @return {*QueryFolderResult}
+ */
+func (c *Client) QueryRecent(ctx context.Context, limit int) (*QueryFolderResult, error) {
+	var result QueryFolderResult
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryRecent", map[string]int{"limit": limit}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// S3访问密钥
+type AccessKey struct {
+	AccessKeyID     string    `json:"accessKeyId"`
+	SecretAccessKey string    `json:"secretAccessKey,omitempty"` // 只在创建时返回
+	UserName        string    `json:"userName"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+func (c *Client) CreateAccessKey(ctx context.Context) (*AccessKey, error) {
+	var key AccessKey
+	if err := c.doJSON(ctx, http.MethodPost, "/api/createAccessKey", nil, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (c *Client) DeleteAccessKey(ctx context.Context, accessKeyID string) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteAccessKey", map[string]string{"accessKeyId": accessKeyID}, nil)
+}
+
+func (c *Client) QueryAccessKeys(ctx context.Context) ([]AccessKey, error) {
+	var keys []AccessKey
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryAccessKeys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,149 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 04:55:27
+ * @LastEditTime: 2026-10-19 11:37:05
+ * @FilePath: \CloudDisk\client\conflict.go
+ * @Description: 名称冲突时的处理方式
+ */
+package client
+
+import (
+	"CloudDisk/dto"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// 名称冲突时的处理方式
+const (
+	ConflictFail      = "fail"      // 返回409，默认
+	ConflictRename    = "rename"    // 自动改名为"name (1).ext"
+	ConflictOverwrite = "overwrite" // 替换同名的文件或文件夹，新建文件夹时使用已有的同名文件夹
+	ConflictSkip      = "skip"      // 不做修改，新建时返回已有的同名项
+)
+
+// ConflictSkip且因名称冲突没有做任何修改时服务端设置的响应头
+const conflictSkippedHeader = "X-Conflict-Skipped"
+
+/**
+ * @description: 响应是否表示因名称冲突跳过
+ * @param {http.Header} header 响应头
+ * @return {bool}
+ */
+func conflictSkipped(header http.Header) bool {
+	return header.Get(conflictSkippedHeader) == "true"
+}
+
+/**
+ * @description: 新建文件夹，指定名称冲突时的处理方式
+ * @param {context.Context} ctx
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} folderName 文件夹名
+ * @param {string} onConflict 冲突处理方式，为空时为ConflictFail
+ * @return {*dto.Folder} ConflictSkip和ConflictOverwrite时为已有的同名文件夹
+ * @return {bool} ConflictSkip时是否因名称冲突跳过
+ */
+func (c *Client) CreateFolderOnConflict(ctx context.Context, parentFolderID int64, folderName string, onConflict string) (*dto.Folder, bool, error) {
+	req := map[string]interface{}{"parentFolderID": parentFolderID, "folderName": folderName, "onConflict": onConflict}
+	var folder dto.Folder
+	header, err := c.doJSONHeader(ctx, http.MethodPost, "/api/createFolder", req, &folder)
+	if err != nil {
+		return nil, false, err
+	}
+	return &folder, conflictSkipped(header), nil
+}
+
+/**
+ * @description: 上传文件，指定名称冲突时的处理方式
+ * @param {context.Context} ctx
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} fileName 文件名
+ * @param {io.Reader} content 文件内容
+ * @param {string} onConflict 冲突处理方式，为空时为ConflictFail
+ * @return {*dto.File} ConflictSkip时为已有的同名文件
+ * @return {bool} ConflictSkip时是否因名称冲突跳过，跳过时没有写入内容
+ */
+func (c *Client) UploadFileOnConflict(ctx context.Context, parentFolderID int64, fileName string, content io.Reader, onConflict string) (*dto.File, bool, error) {
+	fields := map[string]string{"parentFolderID": fmt.Sprint(parentFolderID), "onConflict": onConflict}
+	var file dto.File
+	header, err := c.doUploadHeader(ctx, http.MethodPost, "/api/uploadFile", fields, fileName, content, &file)
+	if err != nil {
+		return nil, false, err
+	}
+	return &file, conflictSkipped(header), nil
+}
+
+/**
+ * @description: 重命名文件夹，指定名称冲突时的处理方式
+ * @return {bool} ConflictSkip时是否因名称冲突跳过，跳过时文件夹保持原名
+ */
+func (c *Client) RenameFolderOnConflict(ctx context.Context, folderID int64, folderName string, onConflict string) (bool, error) {
+	req := map[string]interface{}{"folderID": folderID, "folderName": folderName, "onConflict": onConflict}
+	header, err := c.doJSONHeader(ctx, http.MethodPost, "/api/renameFolder", req, nil)
+	if err != nil {
+		return false, err
+	}
+	return conflictSkipped(header), nil
+}
+
+/**
+ * @description: 重命名文件，指定名称冲突时的处理方式
+ * @return {bool} ConflictSkip时是否因名称冲突跳过，跳过时文件保持原名
+ */
+func (c *Client) RenameFileOnConflict(ctx context.Context, fileID int64, fileName string, onConflict string) (bool, error) {
+	req := map[string]interface{}{"fileID": fileID, "fileName": fileName, "onConflict": onConflict}
+	header, err := c.doJSONHeader(ctx, http.MethodPost, "/api/renameFile", req, nil)
+	if err != nil {
+		return false, err
+	}
+	return conflictSkipped(header), nil
+}
+
+func (v *V2Client) CreateFolderOnConflict(ctx context.Context, parentFolderID int64, name string, onConflict string) (*dto.Folder, bool, error) {
+	var folder dto.Folder
+	path := fmt.Sprintf("/api/v2/folders/%d/children", parentFolderID)
+	header, err := v.c.doJSONHeader(ctx, http.MethodPost, path, map[string]string{"name": name, "onConflict": onConflict}, &folder)
+	if err != nil {
+		return nil, false, err
+	}
+	return &folder, conflictSkipped(header), nil
+}
+
+func (v *V2Client) UploadFileOnConflict(ctx context.Context, parentFolderID int64, fileName string, content io.Reader, onConflict string) (*dto.File, bool, error) {
+	var file dto.File
+	path := fmt.Sprintf("/api/v2/folders/%d/children", parentFolderID)
+	header, err := v.c.doUploadHeader(ctx, http.MethodPost, path, map[string]string{"onConflict": onConflict}, fileName, content, &file)
+	if err != nil {
+		return nil, false, err
+	}
+	return &file, conflictSkipped(header), nil
+}
+
+/**
+ * @description: 修改文件夹，按req.OnConflict处理名称冲突
+ * @return {*dto.Folder} 修改后的文件夹，跳过时为未修改的文件夹
+ * @return {bool} ConflictSkip时是否因名称冲突跳过
+ */
+func (v *V2Client) PatchFolderOnConflict(ctx context.Context, folderID int64, req PatchRequest) (*dto.Folder, bool, error) {
+	var folder dto.Folder
+	header, err := v.c.doJSONHeader(ctx, http.MethodPatch, fmt.Sprintf("/api/v2/folders/%d", folderID), req, &folder)
+	if err != nil {
+		return nil, false, err
+	}
+	return &folder, conflictSkipped(header), nil
+}
+
+/**
+ * @description: 修改文件，按req.OnConflict处理名称冲突
+ * @return {*dto.File} 修改后的文件，跳过时为未修改的文件
+ * @return {bool} ConflictSkip时是否因名称冲突跳过
+ */
+func (v *V2Client) PatchFileOnConflict(ctx context.Context, fileID int64, req PatchRequest) (*dto.File, bool, error) {
+	var file dto.File
+	header, err := v.c.doJSONHeader(ctx, http.MethodPatch, fmt.Sprintf("/api/v2/files/%d", fileID), req, &file)
+	if err != nil {
+		return nil, false, err
+	}
+	return &file, conflictSkipped(header), nil
+}
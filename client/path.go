@@ -0,0 +1,109 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:05:31
+ * @LastEditTime: 2026-10-18 21:05:31
+ * @FilePath: \CloudDisk\client\path.go
+ * @Description: 按路径寻址的接口
+ */
+package client
+
+import (
+	"CloudDisk/dto"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+)
+
+// 路径查询结果，Folder和File只有一个不为nil
+type PathInfo struct {
+	Type   string      `json:"type"` // folder/file
+	Folder *dto.Folder `json:"folder,omitempty"`
+	File   *dto.File   `json:"file,omitempty"`
+}
+
+/**
+ * @description: 按路径查询文件夹或文件
+ * @param {string} itemPath 路径，如/projects/2025/report.pdf
+ * @return {*PathInfo} 路径不存在时返回StatusCode为404的*Error
+ */
+func (c *Client) QueryPath(ctx context.Context, itemPath string) (*PathInfo, error) {
+	var info PathInfo
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryPath", map[string]string{"path": itemPath}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *Client) QueryFolderByPath(ctx context.Context, folderPath string, opts ListOptions) (*QueryFolderResult, error) {
+	req := struct {
+		Path string `json:"path"`
+		ListOptions
+	}{folderPath, opts}
+	var result QueryFolderResult
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryFolder", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+/**
+ * @description: 按完整路径新建文件夹，父文件夹必须存在
+ * @param {string} folderPath 新文件夹的路径
+ * @return {*dto.Folder}
+ */
+func (c *Client) CreateFolderByPath(ctx context.Context, folderPath string) (*dto.Folder, error) {
+	var folder dto.Folder
+	if err := c.doJSON(ctx, http.MethodPost, "/api/createFolder", map[string]string{"path": folderPath}, &folder); err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+/**
+ * @description: 按完整路径上传文件，父文件夹必须存在
+ * @param {string} filePath 新文件的路径，文件名取路径的最后一级
+ * @param {io.Reader} content 文件内容
+ * @return {*dto.File}
+ */
+func (c *Client) UploadFileByPath(ctx context.Context, filePath string, content io.Reader) (*dto.File, error) {
+	var file dto.File
+	if err := c.doUpload(ctx, http.MethodPost, "/api/uploadFile", map[string]string{"path": filePath}, path.Base(filePath), content, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (c *Client) RenameFolderByPath(ctx context.Context, folderPath string, folderName string) error {
+	req := map[string]string{"path": folderPath, "folderName": folderName}
+	return c.doJSON(ctx, http.MethodPost, "/api/renameFolder", req, nil)
+}
+
+func (c *Client) RenameFileByPath(ctx context.Context, filePath string, fileName string) error {
+	req := map[string]string{"path": filePath, "fileName": fileName}
+	return c.doJSON(ctx, http.MethodPost, "/api/renameFile", req, nil)
+}
+
+func (c *Client) DeleteFolderByPath(ctx context.Context, folderPath string) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteFolder", map[string]string{"path": folderPath}, nil)
+}
+
+func (c *Client) DeleteFileByPath(ctx context.Context, filePath string) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteFile", map[string]string{"path": filePath}, nil)
+}
+
+/**
+ * @description: 按路径下载文件
+ * @param {string} filePath 文件路径
+ * @return {io.ReadCloser} 文件内容，调用者负责关闭
+ */
+func (c *Client) DownloadFileByPath(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	data, _ := json.Marshal(map[string]string{"path": filePath})
+	resp, err := c.do(ctx, http.MethodPost, "/api/downloadFile", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
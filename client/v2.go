@@ -0,0 +1,158 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:12:48
+ * @LastEditTime: 2026-10-19 15:41:07
+ * @FilePath: \CloudDisk\client\v2.go
+ * @Description: v2接口客户端
+ */
+package client
+
+import (
+	"CloudDisk/dto"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// v2接口客户端，通过Client.V2访问
+type V2Client struct {
+	c *Client
+}
+
+func (v *V2Client) GetFolder(ctx context.Context, folderID int64) (*dto.Folder, error) {
+	var folder dto.Folder
+	if err := v.c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v2/folders/%d", folderID), nil, &folder); err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+/**
+ * @description: 查询文件夹内容
+ * @param {int64} folderID 文件夹ID
+ * @param {ListOptions} opts 排序、分页和过滤条件
+ * @return {*QueryFolderResult}
+ */
+func (v *V2Client) ListChildren(ctx context.Context, folderID int64, opts ListOptions) (*QueryFolderResult, error) {
+	query := url.Values{}
+	if opts.SortBy != "" {
+		query.Set("sortBy", opts.SortBy)
+	}
+	if opts.Order != "" {
+		query.Set("order", opts.Order)
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+	if opts.Limit != 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	for _, tag := range opts.Tags {
+		query.Add("tag", tag)
+	}
+	for key, value := range opts.Metadata {
+		query.Add("metadata", key+"="+value)
+	}
+
+	path := fmt.Sprintf("/api/v2/folders/%d/children", folderID)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var result QueryFolderResult
+	if err := v.c.doJSON(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (v *V2Client) CreateFolder(ctx context.Context, parentFolderID int64, name string) (*dto.Folder, error) {
+	folder, _, err := v.CreateFolderOnConflict(ctx, parentFolderID, name, "")
+	return folder, err
+}
+
+func (v *V2Client) UploadFile(ctx context.Context, parentFolderID int64, fileName string, content io.Reader) (*dto.File, error) {
+	file, _, err := v.UploadFileOnConflict(ctx, parentFolderID, fileName, content, "")
+	return file, err
+}
+
+// 修改请求，为nil的字段保持不变
+type PatchRequest struct {
+	Name           *string `json:"name,omitempty"`
+	ParentFolderID *int64  `json:"parentFolderId,omitempty"`
+	OnConflict     string  `json:"onConflict,omitempty"` // 名称冲突时的处理方式，见Conflict*常量
+}
+
+func (v *V2Client) PatchFolder(ctx context.Context, folderID int64, req PatchRequest) (*dto.Folder, error) {
+	folder, _, err := v.PatchFolderOnConflict(ctx, folderID, req)
+	return folder, err
+}
+
+func (v *V2Client) DeleteFolder(ctx context.Context, folderID int64) error {
+	return v.c.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/api/v2/folders/%d", folderID), nil, nil)
+}
+
+func (v *V2Client) GetFile(ctx context.Context, fileID int64) (*dto.File, error) {
+	var file dto.File
+	if err := v.c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v2/files/%d", fileID), nil, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (v *V2Client) PatchFile(ctx context.Context, fileID int64, req PatchRequest) (*dto.File, error) {
+	file, _, err := v.PatchFileOnConflict(ctx, fileID, req)
+	return file, err
+}
+
+func (v *V2Client) DeleteFile(ctx context.Context, fileID int64) error {
+	return v.c.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/api/v2/files/%d", fileID), nil, nil)
+}
+
+/**
+ * @description: 下载文件内容
+ * @param {int64} fileID 文件ID
+ * @return {io.ReadCloser} 文件内容，调用者负责关闭
+ */
+func (v *V2Client) DownloadFile(ctx context.Context, fileID int64) (io.ReadCloser, error) {
+	resp, err := v.c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v2/files/%d/content", fileID), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+/**
+ * @description: 替换文件内容
+ * @param {int64} fileID 文件ID
+ * @param {io.Reader} content 新的文件内容
+ * @return {*dto.File}
+ */
+func (v *V2Client) PutFileContent(ctx context.Context, fileID int64, content io.Reader) (*dto.File, error) {
+	return v.PutFileContentIfMatch(ctx, fileID, content, "")
+}
+
+/**
+ * @description: 文件未被修改时替换文件内容
+ * @param {int64} fileID 文件ID
+ * @param {io.Reader} content 新的文件内容
+ * @param {string} etag 读取文件时的ETag，见dto.File.ETag，为空时无条件替换
+ * @return {*dto.File} 文件已被修改时返回状态码为412的*Error
+ */
+func (v *V2Client) PutFileContentIfMatch(ctx context.Context, fileID int64, content io.Reader, etag string) (*dto.File, error) {
+	header := http.Header{"Content-Type": {"application/octet-stream"}, "If-Match": {etag}}
+	resp, err := v.c.doWithHeader(ctx, http.MethodPut, fmt.Sprintf("/api/v2/files/%d/content", fileID), header, content)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var file dto.File
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
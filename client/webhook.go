@@ -0,0 +1,112 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 23:26:04
+ * @LastEditTime: 2026-10-18 23:26:04
+ * @FilePath: \CloudDisk\client\webhook.go
+ * @Description: Webhook订阅接口
+ */
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// 投递请求中的签名请求头，值为sha256=请求体的HMAC-SHA256
+const WebhookSignatureHeader = "X-CloudDisk-Signature"
+
+type Webhook struct {
+	ID         int64     `json:"id"`
+	UserName   string    `json:"userName"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"` // 只在创建时返回
+	Events     []string  `json:"events"`
+	FolderPath string    `json:"folderPath"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`     // 为空时由服务端生成
+	Events     []string `json:"events,omitempty"`     // 变更类型，为空表示全部
+	FolderPath string   `json:"folderPath,omitempty"` // 为空表示根目录
+}
+
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int64      `json:"webhookId"`
+	ChangeID       int64      `json:"changeId"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"` // pending/succeeded/failed
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus"`
+	Error          string     `json:"error"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// 投递的请求体
+type WebhookPayload struct {
+	DeliveryID int64  `json:"deliveryId"`
+	WebhookID  int64  `json:"webhookId"`
+	Event      string `json:"event"`
+	Change     Change `json:"change"`
+}
+
+/**
+ * @description: 校验投递请求的签名，接收方应在解析请求体前调用
+ * @param {string} secret 签名密钥
+ * @param {[]byte} body 原始请求体
+ * @param {string} signature 签名请求头的值
+ * @return {bool}
+ */
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+}
+
+func (c *Client) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	var hook Webhook
+	if err := c.doJSON(ctx, http.MethodPost, "/api/createWebhook", req, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID int64) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/deleteWebhook", map[string]int64{"webhookId": webhookID}, nil)
+}
+
+func (c *Client) QueryWebhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryWebhooks", nil, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+/**
+ * @description: 查询投递记录，最新的在前
+ * @param {int64} webhookID Webhook ID
+ * @param {string} status 投递状态，为空时不过滤
+ * @param {int} limit 最多返回条数，为0时使用服务端默认值
+ * @return {[]WebhookDelivery}
+ */
+func (c *Client) QueryWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]WebhookDelivery, error) {
+	req := struct {
+		WebhookID int64  `json:"webhookId"`
+		Status    string `json:"status,omitempty"`
+		Limit     int    `json:"limit,omitempty"`
+	}{webhookID, status, limit}
+	var deliveries []WebhookDelivery
+	if err := c.doJSON(ctx, http.MethodPost, "/api/queryWebhookDeliveries", req, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,254 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:48:06
+ * @LastEditTime: 2026-10-18 20:48:06
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\commands.go
+ * @Description: ls、mkdir、mv、rm、search子命令
+ */
+package main
+
+import (
+	"CloudDisk/client"
+	"CloudDisk/dto"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+)
+
+func runLs(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("ls", "[-l] [path]")
+	long := fs.Bool("l", false, "show size and modification time")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	e, err := resolve(ctx, a.client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !e.isFolder() {
+		printEntries(nil, []dto.File{*e.File}, *long)
+		return nil
+	}
+
+	result, err := listFolder(ctx, a.client, e.Folder.ID)
+	if err != nil {
+		return err
+	}
+	printEntries(result.Folders, result.Files, *long)
+	return nil
+}
+
+/**
+ * @description: 输出文件夹和文件列表，文件夹名以/结尾
+ * @param {[]dto.Folder} folders 文件夹列表
+ * @param {[]dto.File} files 文件列表
+ * @param {bool} long 是否输出大小和修改时间
+ * @return {*}
+ */
+func printEntries(folders []dto.Folder, files []dto.File, long bool) {
+	if !long {
+		for _, folder := range folders {
+			fmt.Println(folder.Name + "/")
+		}
+		for _, file := range files {
+			fmt.Println(file.Name)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, folder := range folders {
+		fmt.Fprintf(tw, "-\t%s\t%s/\t\n", folder.UpdatedAt.Local().Format(time.DateTime), folder.Name)
+	}
+	for _, file := range files {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", formatSize(file.Size), file.UpdatedAt.Local().Format(time.DateTime), file.Name)
+	}
+	tw.Flush()
+}
+
+/**
+ * @description: 以1024为进制格式化文件大小
+ * @param {int64} size 字节数
+ * @return {string}
+ */
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func runMkdir(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("mkdir", "[-p] path...")
+	parents := fs.Bool("p", false, "create parent folders as needed, no error if the folder exists")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, remotePath := range fs.Args() {
+		remotePath = cleanRemotePath(remotePath)
+		if *parents {
+			if _, err := mkdirAll(ctx, a.client, remotePath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		parent, err := resolveFolder(ctx, a.client, path.Dir(remotePath))
+		if err != nil {
+			return err
+		}
+		if _, err := a.client.CreateFolder(ctx, parent.ID, path.Base(remotePath)); err != nil {
+			return fmt.Errorf("%s: %w", remotePath, err)
+		}
+	}
+	return nil
+}
+
+func runMv(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("mv", "src dst")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	src, err := resolve(ctx, a.client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if src.Path == "/" {
+		return errors.New("cannot move the root folder")
+	}
+
+	// 目标是已存在的文件夹时移动到该文件夹下，否则移动并重命名
+	dstPath := cleanRemotePath(fs.Arg(1))
+	var req client.PatchRequest
+	dst, err := resolve(ctx, a.client, dstPath)
+	switch {
+	case err == nil && dst.isFolder():
+		req.ParentFolderID = &dst.Folder.ID
+	case err == nil:
+		return fmt.Errorf("%s: already exists", dst.Path)
+	case errors.Is(err, errNotFound):
+		parent, err := resolveFolder(ctx, a.client, path.Dir(dstPath))
+		if err != nil {
+			return err
+		}
+		name := path.Base(dstPath)
+		req.ParentFolderID = &parent.ID
+		req.Name = &name
+	default:
+		return err
+	}
+
+	if src.isFolder() {
+		_, err = a.client.V2.PatchFolder(ctx, src.Folder.ID, req)
+	} else {
+		_, err = a.client.V2.PatchFile(ctx, src.File.ID, req)
+	}
+	return err
+}
+
+func runRm(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("rm", "[-r] path...")
+	recursive := fs.Bool("r", false, "delete folders and their contents")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, remotePath := range fs.Args() {
+		e, err := resolve(ctx, a.client, remotePath)
+		if err != nil {
+			return err
+		}
+		if !e.isFolder() {
+			if err := a.client.DeleteFile(ctx, e.File.ID); err != nil {
+				return fmt.Errorf("%s: %w", e.Path, err)
+			}
+			continue
+		}
+
+		if !*recursive {
+			return fmt.Errorf("%s: is a folder, use -r to delete it", e.Path)
+		}
+		if err := a.client.DeleteFolder(ctx, e.Folder.ID); err != nil {
+			return fmt.Errorf("%s: %w", e.Path, err)
+		}
+	}
+	return nil
+}
+
+func runSearch(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("search", "[-type file|folder] [-glob] [-content] [-in path] keyword")
+	itemType := fs.String("type", "", "only match files or folders")
+	glob := fs.Bool("glob", false, "treat keyword as a glob pattern such as *.pdf")
+	content := fs.Bool("content", false, "search file contents instead of names")
+	in := fs.String("in", "/", "only search under this folder")
+	limit := fs.Int("n", 100, "maximum number of results")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	root, err := resolveFolder(ctx, a.client, *in)
+	if err != nil {
+		return err
+	}
+
+	if *content {
+		result, err := a.client.SearchContent(ctx, client.SearchContentRequest{
+			Keyword:      fs.Arg(0),
+			RootFolderID: root.ID,
+			PageSize:     *limit,
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range result.Items {
+			fmt.Printf("%s\t%s\n", item.File.Path, item.Snippet)
+		}
+		return nil
+	}
+
+	req := client.SearchRequest{
+		Keyword:      fs.Arg(0),
+		Type:         *itemType,
+		RootFolderID: root.ID,
+		SortBy:       "path",
+		PageSize:     *limit,
+	}
+	if *glob {
+		req.Mode = "glob"
+	}
+	result, err := a.client.Search(ctx, req)
+	if err != nil {
+		return err
+	}
+	for _, item := range result.Items {
+		if item.Type == dto.FileTypeFolder {
+			fmt.Println(item.Path + "/")
+		} else {
+			fmt.Println(item.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:48:06
+ * @LastEditTime: 2026-10-18 20:48:06
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\config.go
+ * @Description: 命令行客户端配置
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 配置文件示例:
+//
+//	{
+//	    "server": "http://localhost:8080",
+//	    "username": "alice",
+//	    "password": "secret",
+//	    "s3": {
+//	        "endpoint": "http://localhost:9000",
+//	        "region": "us-east-1",
+//	        "accessKeyID": "...",
+//	        "secretAccessKey": "..."
+//	    }
+//	}
+type config struct {
+	Server   string   `json:"server"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	S3       s3Config `json:"s3"` // share命令使用，通过S3预签名URL生成分享链接
+}
+
+type s3Config struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey"`
+}
+
+/**
+ * @description: 默认配置文件路径，可通过CLOUDDISK_CONFIG环境变量覆盖
+ * @return {string}
+ */
+func defaultConfigPath() string {
+	if p := os.Getenv("CLOUDDISK_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "clouddisk.json"
+	}
+	return filepath.Join(dir, "clouddisk", "cli.json")
+}
+
+/**
+ * @description: 读取配置文件
+ * @param {string} configPath 配置文件路径
+ * @return {*config}
+ */
+func loadConfig(configPath string) (*config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	cfg := &config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", configPath, err)
+	}
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("config file %s: server is required", configPath)
+	}
+	if cfg.S3.Region == "" {
+		cfg.S3.Region = "us-east-1"
+	}
+	return cfg, nil
+}
@@ -0,0 +1,104 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:48:06
+ * @LastEditTime: 2026-10-18 21:31:12
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\main.go
+ * @Description: CloudDisk命令行客户端
+ */
+package main
+
+import (
+	"CloudDisk/client"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+const usage = `Usage: clouddisk-cli [-config file] <command> [arguments]
+
+Commands:
+  ls [-l] [path]                      list a folder
+  mkdir [-p] path...                  create folders
+  put [-r] [-f] [-q] local... remote  upload files or folders
+  get [-r] [-q] remote [local]        download a file or folder
+  mv src dst                          move or rename a file or folder
+  rm [-r] path...                     delete files or folders
+  share [-expires duration] path      print a time-limited download link
+  search [-type t] [-glob] [-content] [-in path] keyword
+                                      search by name or by file content
+  sync [-once] [-interval d] local remote
+                                      keep a local directory in sync with a folder
+
+Remote paths are absolute paths in the disk, e.g. /docs/report.pdf.
+The config file defaults to $CLOUDDISK_CONFIG or <user config dir>/clouddisk/cli.json.
+`
+
+// 子命令
+var commands = map[string]func(ctx context.Context, a *app, args []string) error{
+	"ls":     runLs,
+	"mkdir":  runMkdir,
+	"put":    runPut,
+	"get":    runGet,
+	"mv":     runMv,
+	"rm":     runRm,
+	"share":  runShare,
+	"search": runSearch,
+	"sync":   runSync,
+}
+
+// 子命令共用的状态
+type app struct {
+	cfg    *config
+	client *client.Client
+}
+
+func main() {
+	configPath := flag.String("config", defaultConfigPath(), "config file path")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "clouddisk-cli: unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clouddisk-cli: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ctrl+C时取消正在进行的请求
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &app{cfg: cfg, client: client.New(cfg.Server, cfg.Username, cfg.Password)}
+	if err := cmd(ctx, a, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "clouddisk-cli %s: %v\n", flag.Arg(0), err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+/**
+ * @description: 创建子命令的参数解析器，解析失败时输出子命令用法
+ * @param {string} name 子命令名称
+ * @param {string} synopsis 子命令用法
+ * @return {*flag.FlagSet}
+ */
+func newFlagSet(name string, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: clouddisk-cli %s %s\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,108 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:48:06
+ * @LastEditTime: 2026-10-18 21:05:31
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\resolve.go
+ * @Description: 将远程路径解析为文件夹或文件ID
+ */
+package main
+
+import (
+	"CloudDisk/client"
+	"CloudDisk/dto"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+)
+
+var errNotFound = errors.New("no such file or folder")
+
+// 远程路径解析结果，Folder和File只有一个不为nil
+type entry struct {
+	Path   string
+	Folder *dto.Folder
+	File   *dto.File
+}
+
+func (e *entry) isFolder() bool { return e.Folder != nil }
+
+/**
+ * @description: 规范化远程路径，始终以/开头
+ * @param {string} p 远程路径
+ * @return {string}
+ */
+func cleanRemotePath(p string) string {
+	return path.Clean("/" + p)
+}
+
+/**
+ * @description: 列出文件夹的全部内容
+ * @param {*client.Client} c
+ * @param {int64} folderID 文件夹ID
+ * @return {*client.QueryFolderResult}
+ */
+func listFolder(ctx context.Context, c *client.Client, folderID int64) (*client.QueryFolderResult, error) {
+	return c.QueryFolder(ctx, folderID, client.ListOptions{SortBy: "type"})
+}
+
+/**
+ * @description: 查找远程路径
+ * @param {*client.Client} c
+ * @param {string} remotePath 远程路径
+ * @return {*entry} 路径不存在时返回errNotFound
+ */
+func resolve(ctx context.Context, c *client.Client, remotePath string) (*entry, error) {
+	remotePath = cleanRemotePath(remotePath)
+	info, err := c.QueryPath(ctx, remotePath)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", remotePath, errNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	return &entry{Path: remotePath, Folder: info.Folder, File: info.File}, nil
+}
+
+/**
+ * @description: 查找远程文件夹，不存在或不是文件夹时返回错误
+ * @param {*client.Client} c
+ * @param {string} remotePath 远程路径
+ * @return {*dto.Folder}
+ */
+func resolveFolder(ctx context.Context, c *client.Client, remotePath string) (*dto.Folder, error) {
+	e, err := resolve(ctx, c, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isFolder() {
+		return nil, fmt.Errorf("%s: not a folder", e.Path)
+	}
+	return e.Folder, nil
+}
+
+/**
+ * @description: 逐级创建远程文件夹，已存在的文件夹直接使用
+ * @param {*client.Client} c
+ * @param {string} remotePath 远程路径
+ * @return {*dto.Folder}
+ */
+func mkdirAll(ctx context.Context, c *client.Client, remotePath string) (*dto.Folder, error) {
+	remotePath = cleanRemotePath(remotePath)
+	e, err := resolve(ctx, c, remotePath)
+	if err == nil {
+		if !e.isFolder() {
+			return nil, fmt.Errorf("%s: not a folder", e.Path)
+		}
+		return e.Folder, nil
+	} else if !errors.Is(err, errNotFound) {
+		return nil, err
+	}
+
+	parent, err := mkdirAll(ctx, c, path.Dir(remotePath))
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateFolder(ctx, parent.ID, path.Base(remotePath))
+}
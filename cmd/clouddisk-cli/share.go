@@ -0,0 +1,147 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:48:06
+ * @LastEditTime: 2026-10-18 20:48:06
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\share.go
+ * @Description: share子命令，通过S3接口的预签名URL生成限时下载链接
+ */
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3预签名URL的最长有效期
+const maxShareExpires = 7 * 24 * time.Hour
+
+func runShare(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("share", "[-expires duration] path")
+	expires := fs.Duration("expires", 24*time.Hour, "how long the link stays valid, at most 168h")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *expires < time.Second || *expires > maxShareExpires {
+		return fmt.Errorf("expires must be between 1s and %s", maxShareExpires)
+	}
+	if a.cfg.S3.Endpoint == "" || a.cfg.S3.AccessKeyID == "" || a.cfg.S3.SecretAccessKey == "" {
+		return errors.New("share requires s3.endpoint, s3.accessKeyID and s3.secretAccessKey in the config file")
+	}
+
+	e, err := resolve(ctx, a.client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if e.isFolder() {
+		return fmt.Errorf("%s: only files can be shared", e.Path)
+	}
+
+	// S3接口中bucket对应根目录下的文件夹，根目录下的文件无法通过S3访问
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(e.Path, "/"), "/")
+	if !ok {
+		return fmt.Errorf("%s: files directly under / cannot be shared", e.Path)
+	}
+
+	link, err := presignGetObject(a.cfg.S3, bucket, key, *expires, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println(link)
+	return nil
+}
+
+/**
+ * @description: 生成GetObject的SigV4预签名URL
+ * @param {s3Config} cfg S3接口配置
+ * @param {string} bucket bucket名称
+ * @param {string} key 对象key
+ * @param {time.Duration} expires 有效期
+ * @param {time.Time} now 签名时间
+ * @return {string} 预签名URL
+ */
+func presignGetObject(cfg s3Config, bucket string, key string, expires time.Duration, now time.Time) (string, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3.endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return "", fmt.Errorf("invalid s3.endpoint %q", cfg.Endpoint)
+	}
+
+	now = now.UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	scope := strings.Join([]string{date, cfg.Region, "s3", "aws4_request"}, "/")
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    cfg.AccessKeyID + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(expires / time.Second)),
+		"X-Amz-SignedHeaders": "host",
+	}
+	var queryParts []string
+	for name, value := range query {
+		queryParts = append(queryParts, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+	}
+	sort.Strings(queryParts)
+	canonicalQuery := strings.Join(queryParts, "&")
+
+	canonicalURI := awsURIEncode("/"+bucket+"/"+key, false)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalURI,
+		canonicalQuery,
+		"host:" + endpoint.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	signingKey := []byte("AWS4" + cfg.SecretAccessKey)
+	for _, part := range []string{date, cfg.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, []byte(part))
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+
+	return endpoint.Scheme + "://" + endpoint.Host + canonicalURI + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+/**
+ * @description: 按AWS规则进行URI编码，只保留A-Za-z0-9-_.~不编码
+ * @param {string} s 原始字符串
+ * @param {bool} encodeSlash 是否编码斜杠
+ * @return {string} 编码后的字符串
+ */
+func awsURIEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
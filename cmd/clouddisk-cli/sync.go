@@ -0,0 +1,37 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:31:12
+ * @LastEditTime: 2026-10-18 21:31:12
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\sync.go
+ * @Description: sync子命令，双向同步本地目录和远程文件夹
+ */
+package main
+
+import (
+	"CloudDisk/syncagent"
+	"context"
+	"os"
+	"time"
+)
+
+func runSync(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("sync", "[-once] [-interval duration] local remote")
+	once := fs.Bool("once", false, "sync once and exit instead of watching for changes")
+	interval := fs.Duration("interval", 30*time.Second, "how often to check the server for changes")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	agent, err := syncagent.New(ctx, a.client, fs.Arg(0), cleanRemotePath(fs.Arg(1)), syncagent.Options{PollInterval: *interval})
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	if *once {
+		return agent.SyncOnce(ctx)
+	}
+	return agent.Run(ctx)
+}
@@ -0,0 +1,279 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:48:06
+ * @LastEditTime: 2026-10-19 15:20:11
+ * @FilePath: \CloudDisk\cmd\clouddisk-cli\transfer.go
+ * @Description: put、get子命令
+ */
+package main
+
+import (
+	"CloudDisk/client"
+	"CloudDisk/dto"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// 显示传输进度的Reader
+type progressReader struct {
+	r       io.Reader
+	name    string
+	total   int64
+	done    int64
+	printed time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if time.Since(p.printed) >= 200*time.Millisecond {
+		p.print()
+	}
+	return n, err
+}
+
+func (p *progressReader) print() {
+	p.printed = time.Now()
+	percent := int64(100)
+	if p.total > 0 {
+		percent = p.done * 100 / p.total
+	}
+	fmt.Fprintf(os.Stderr, "\r%-40s %9s / %-9s %3d%%", p.name, formatSize(p.done), formatSize(p.total), percent)
+}
+
+// 传输完成后输出最终进度并换行
+func (p *progressReader) finish() {
+	p.print()
+	fmt.Fprintln(os.Stderr)
+}
+
+/**
+ * @description: 根据quiet参数决定是否包装进度显示
+ * @param {io.Reader} r 数据源
+ * @param {string} name 显示的名称
+ * @param {int64} total 总大小
+ * @param {bool} quiet 是否不显示进度
+ * @return {io.Reader}
+ * @return {func()} 传输完成后调用
+ */
+func withProgress(r io.Reader, name string, total int64, quiet bool) (io.Reader, func()) {
+	if quiet {
+		return r, func() {}
+	}
+	p := &progressReader{r: r, name: name, total: total}
+	return p, p.finish
+}
+
+// 上传状态
+type uploader struct {
+	client    *client.Client
+	recursive bool
+	force     bool
+	quiet     bool
+}
+
+/**
+ * @description: 将本地文件或文件夹上传到远程文件夹下
+ * 同名条目由服务端按名称唯一性策略判断，客户端不比较名称，大小写或Unicode形式不同的同名条目也能被覆盖或复用
+ * @param {string} localPath 本地路径
+ * @param {*dto.Folder} parent 远程父文件夹
+ * @param {string} name 远程名称
+ * @return {*}
+ */
+func (u *uploader) put(ctx context.Context, localPath string, parent *dto.Folder, name string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	remotePath := path.Join(parent.Path, name)
+
+	if info.IsDir() {
+		if !u.recursive {
+			return fmt.Errorf("%s: is a directory, use -r to upload it", localPath)
+		}
+
+		// 已有同名文件夹时上传到该文件夹下，已有同名文件时返回409
+		folder, _, err := u.client.CreateFolderOnConflict(ctx, parent.ID, name, client.ConflictOverwrite)
+		if err != nil {
+			return fmt.Errorf("%s: %w", remotePath, err)
+		}
+
+		entries, err := os.ReadDir(localPath)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := u.put(ctx, filepath.Join(localPath, e.Name()), folder, e.Name()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !info.Mode().IsRegular() {
+		fmt.Fprintf(os.Stderr, "skipping %s: not a regular file\n", localPath)
+		return nil
+	}
+
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+
+	// -f时覆盖同名文件的内容，同名文件夹仍返回409
+	onConflict := client.ConflictFail
+	if u.force {
+		onConflict = client.ConflictOverwrite
+	}
+	content, finish := withProgress(localFile, remotePath, info.Size(), u.quiet)
+	_, _, err = u.client.UploadFileOnConflict(ctx, parent.ID, name, content, onConflict)
+	finish()
+
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict && !u.force {
+		return fmt.Errorf("%s: already exists, use -f to overwrite it", remotePath)
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", remotePath, err)
+	}
+	return nil
+}
+
+func runPut(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("put", "[-r] [-f] [-q] local... remote")
+	recursive := fs.Bool("r", false, "upload directories recursively")
+	force := fs.Bool("f", false, "overwrite existing remote files")
+	quiet := fs.Bool("q", false, "do not show progress")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	sources := fs.Args()[:fs.NArg()-1]
+	remotePath := cleanRemotePath(fs.Arg(fs.NArg() - 1))
+
+	u := &uploader{
+		client:    a.client,
+		recursive: *recursive,
+		force:     *force,
+		quiet:     *quiet,
+	}
+
+	// 目标是已存在的文件夹时上传到该文件夹下，否则作为单个源的新名称
+	dst, err := resolve(ctx, a.client, remotePath)
+	switch {
+	case err == nil && dst.isFolder():
+		for _, source := range sources {
+			if err := u.put(ctx, source, dst.Folder, filepath.Base(source)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case err != nil && !errors.Is(err, errNotFound):
+		return err
+	case len(sources) > 1:
+		return fmt.Errorf("%s: not a folder", remotePath)
+	}
+
+	parent, err := resolveFolder(ctx, a.client, path.Dir(remotePath))
+	if err != nil {
+		return err
+	}
+	return u.put(ctx, sources[0], parent, path.Base(remotePath))
+}
+
+/**
+ * @description: 将远程文件或文件夹下载到本地路径
+ * @param {*entry} e 远程条目
+ * @param {string} localPath 本地路径
+ * @param {bool} recursive 是否下载文件夹
+ * @param {bool} quiet 是否不显示进度
+ * @return {*}
+ */
+func download(ctx context.Context, c *client.Client, e *entry, localPath string, recursive bool, quiet bool) error {
+	if e.isFolder() {
+		if !recursive {
+			return fmt.Errorf("%s: is a folder, use -r to download it", e.Path)
+		}
+		if err := os.MkdirAll(localPath, 0755); err != nil {
+			return err
+		}
+
+		listing, err := listFolder(ctx, c, e.Folder.ID)
+		if err != nil {
+			return err
+		}
+		for i := range listing.Folders {
+			child := &entry{Path: listing.Folders[i].Path, Folder: &listing.Folders[i]}
+			if err := download(ctx, c, child, filepath.Join(localPath, listing.Folders[i].Name), recursive, quiet); err != nil {
+				return err
+			}
+		}
+		for i := range listing.Files {
+			child := &entry{Path: listing.Files[i].Path, File: &listing.Files[i]}
+			if err := download(ctx, c, child, filepath.Join(localPath, listing.Files[i].Name), recursive, quiet); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	body, err := c.DownloadFile(ctx, e.File.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	defer body.Close()
+
+	localFile, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	content, finish := withProgress(body, e.Path, e.File.Size, quiet)
+	_, err = io.Copy(localFile, content)
+	finish()
+	if closeErr := localFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath) // 不保留不完整的文件
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	return nil
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("get", "[-r] [-q] remote [local]")
+	recursive := fs.Bool("r", false, "download folders recursively")
+	quiet := fs.Bool("q", false, "do not show progress")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	e, err := resolve(ctx, a.client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// 本地路径是已存在的目录时下载到该目录下
+	localPath := fs.Arg(1)
+	if localPath == "" {
+		localPath = "."
+	}
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		name := path.Base(e.Path)
+		if e.Path == "/" {
+			name = e.Folder.Name
+		}
+		localPath = filepath.Join(localPath, name)
+	}
+	return download(ctx, a.client, e, localPath, *recursive, *quiet)
+}
@@ -1,7 +1,7 @@
 /*
  * @Author: shanghanjin
  * @Date: 2024-08-19 17:51:57
- * @LastEditTime: 2024-08-30 15:58:17
+ * @LastEditTime: 2026-10-19 12:27:40
  * @FilePath: \UserFeedBack\configwrapper\config.go
  * @Description: 配置封装
  */
@@ -14,7 +14,9 @@ import (
 )
 
 type Local struct {
-	BaseFolder string `json:"baseFolder"`
+	BaseFolder     string `json:"baseFolder"`
+	CacheFolder    string `json:"cacheFolder"`    // 缩略图等生成文件的存放目录，默认为程序目录下的cache
+	NameUniqueness string `json:"nameUniqueness"` // 同一文件夹下名称的唯一性策略：case-insensitive(默认)/exact
 }
 
 type Database struct {
@@ -25,9 +27,44 @@ type Database struct {
 	Password string `json:"password"`
 }
 
+type User struct {
+	Name           string   `json:"name"`
+	Password       string   `json:"password"`
+	AuthorizedKeys []string `json:"authorizedKeys"` // SSH公钥，格式同authorized_keys文件中的一行
+}
+
+type S3 struct {
+	Address string `json:"address"` // 监听地址，为空时不启动S3服务
+	Region  string `json:"region"`
+}
+
+type SFTP struct {
+	Address     string `json:"address"`     // 监听地址，为空时不启动SFTP服务
+	HostKeyFile string `json:"hostKeyFile"` // 主机私钥文件，不存在时自动生成
+}
+
+type Transcode struct {
+	Disabled   bool   `json:"disabled"`   // 关闭视频转码
+	FFmpegPath string `json:"ffmpegPath"` // ffmpeg路径，为空时在PATH中查找，找不到时不转码
+}
+
+type Webhook struct {
+	AllowPrivateTargets bool `json:"allowPrivateTargets"` // 允许投递到本机、链路本地和内网地址，默认拒绝
+}
+
+type GRPC struct {
+	Address string `json:"address"` // 监听地址，为空时不启动gRPC服务
+}
+
 type Config struct {
-	Local    Local    `json:"local"`
-	Database Database `json:"database"`
+	Local     Local     `json:"local"`
+	Database  Database  `json:"database"`
+	Users     []User    `json:"users"` // 未配置用户时所有请求以匿名用户身份处理
+	S3        S3        `json:"s3"`
+	SFTP      SFTP      `json:"sftp"`
+	GRPC      GRPC      `json:"grpc"`
+	Transcode Transcode `json:"transcode"`
+	Webhook   Webhook   `json:"webhook"`
 }
 
 var Cfg *Config
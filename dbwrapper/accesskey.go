@@ -0,0 +1,99 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 17:35:40
+ * @LastEditTime: 2026-10-18 17:35:40
+ * @FilePath: \CloudDisk\dbwrapper\accesskey.go
+ * @Description: S3访问密钥
+ */
+package dbwrapper
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrAccessKeyNotExist = errors.New("access key does not exist")
+
+type AccessKey struct {
+	AccessKeyID     string    `json:"accessKeyId"`
+	SecretAccessKey string    `json:"secretAccessKey,omitempty"`
+	UserName        string    `json:"userName"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+/**
+ * @description: 保存访问密钥
+ * @param {string} accessKeyID 访问密钥ID
+ * @param {string} secretAccessKey 访问密钥
+ * @param {string} userName 所属用户
+ * @return
+ */
+func CreateAccessKey(accessKeyID string, secretAccessKey string, userName string) error {
+	query := "INSERT INTO access_keys (access_key_id, secret_access_key, user_name) VALUES (?, ?, ?);"
+	_, err := db.Exec(query, accessKeyID, secretAccessKey, userName)
+	return err
+}
+
+/**
+ * @description: 删除用户的访问密钥
+ * @param {string} accessKeyID 访问密钥ID
+ * @param {string} userName 所属用户
+ * @return
+ */
+func DeleteAccessKey(accessKeyID string, userName string) error {
+	query := "DELETE FROM access_keys WHERE access_key_id = ? AND user_name = ?;"
+	res, err := db.Exec(query, accessKeyID, userName)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrAccessKeyNotExist
+	}
+	return nil
+}
+
+/**
+ * @description: 查询访问密钥
+ * @param {string} accessKeyID 访问密钥ID
+ * @return {*} AccessKey 访问密钥信息，包含密钥
+ */
+func QueryAccessKey(accessKeyID string) (*AccessKey, error) {
+	var key AccessKey
+	query := "SELECT access_key_id, secret_access_key, user_name, created_at FROM access_keys WHERE access_key_id = ?;"
+	err := db.QueryRow(query, accessKeyID).Scan(&key.AccessKeyID, &key.SecretAccessKey, &key.UserName, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAccessKeyNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+/**
+ * @description: 查询用户的全部访问密钥，不返回密钥本身
+ * @param {string} userName 用户名
+ * @return {[]AccessKey} 访问密钥列表
+ */
+func QueryUserAccessKeys(userName string) ([]AccessKey, error) {
+	query := "SELECT access_key_id, user_name, created_at FROM access_keys WHERE user_name = ? ORDER BY created_at;"
+	rows, err := db.Query(query, userName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []AccessKey{}
+	for rows.Next() {
+		var key AccessKey
+		if err := rows.Scan(&key.AccessKeyID, &key.UserName, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,125 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 22:10:45
+ * @LastEditTime: 2026-10-19 11:37:05
+ * @FilePath: \CloudDisk\dbwrapper\change.go
+ * @Description: 变更日志，按递增ID记录文件和文件夹的每次修改
+ */
+package dbwrapper
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// 变更类型
+const (
+	ChangeCreate = "create" // 新建
+	ChangeRename = "rename" // 同一文件夹内改名
+	ChangeMove   = "move"   // 移动到其他文件夹，可能同时改名
+	ChangeDelete = "delete" // 删除
+	ChangeUpdate = "update" // 文件内容更新
+)
+
+// 单条变更，文件夹的移动和删除只记录文件夹本身，子孙条目随之变化
+type Change struct {
+	ID             int64     `json:"id"`
+	ItemType       string    `json:"itemType"` // file/folder
+	ItemID         int64     `json:"itemId"`
+	Action         string    `json:"action"`
+	Path           string    `json:"path"`              // 变更后的路径，删除时为删除前的路径
+	OldPath        string    `json:"oldPath,omitempty"` // 改名和移动前的路径
+	ParentFolderID int64     `json:"parentFolderId"`
+	Size           int64     `json:"size"` // 文件大小，文件夹为0
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// 写入变更到提交事务期间持有，保证变更ID的分配顺序与提交顺序一致，
+// 否则读取方可能先看到较大的ID，游标越过之后才提交的较小ID
+var changeMu sync.Mutex
+
+// 每次写入变更后关闭并替换，等待新变更的一方持有关闭前的通道
+var changeSignal = make(chan struct{})
+
+/**
+ * @description: 按顺序写入变更并提交事务，变更与被记录的修改同时生效
+ * @param {*sql.Tx} tx 修改所在的事务
+ * @param {...Change} changes 变更，ID和CreatedAt由数据库生成
+ * @return {*}
+ */
+func commitWithChange(tx *sql.Tx, changes ...Change) error {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+
+	query := "INSERT INTO changes (item_type, item_id, action, path, old_path, parent_folder_id, size) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	for _, change := range changes {
+		if _, err := tx.Exec(query, change.ItemType, change.ItemID, change.Action, change.Path, change.OldPath, change.ParentFolderID, change.Size); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	close(changeSignal)
+	changeSignal = make(chan struct{})
+	return nil
+}
+
+/**
+ * @description: 获取变更通知通道，之后写入新变更时该通道会被关闭
+ * @return {<-chan struct{}}
+ */
+func ChangeSignal() <-chan struct{} {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+	return changeSignal
+}
+
+/**
+ * @description: 根据父文件夹是否变化区分改名和移动
+ * @param {int64} oldParentFolderID 原父文件夹ID
+ * @param {int64} newParentFolderID 新父文件夹ID
+ * @return {string}
+ */
+func moveAction(oldParentFolderID int64, newParentFolderID int64) string {
+	if oldParentFolderID == newParentFolderID {
+		return ChangeRename
+	}
+	return ChangeMove
+}
+
+/**
+ * @description: 查询游标之后的变更
+ * @param {int64} cursor 上次返回的最大变更ID
+ * @param {int} limit 最多返回条数
+ * @return {[]Change} 按ID升序排列
+ */
+func QueryChanges(cursor int64, limit int) ([]Change, error) {
+	query := "SELECT id, item_type, item_id, action, path, old_path, parent_folder_id, size, created_at FROM changes WHERE id > ? ORDER BY id LIMIT ?;"
+	rows, err := db.Query(query, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []Change{}
+	for rows.Next() {
+		var change Change
+		if err := rows.Scan(&change.ID, &change.ItemType, &change.ItemID, &change.Action, &change.Path, &change.OldPath, &change.ParentFolderID, &change.Size, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+/**
+ * @description: 查询最新的变更ID
+ * @return {int64} 没有变更时为0
+ */
+func LatestChangeID() (int64, error) {
+	var id int64
+	err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM changes;").Scan(&id)
+	return id, err
+}
@@ -0,0 +1,160 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 11:12:08
+ * @LastEditTime: 2026-10-19 09:31:05
+ * @FilePath: \CloudDisk\dbwrapper\content.go
+ * @Description: 文件内容全文索引
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/dto"
+	"strings"
+)
+
+// 搜索结果中返回的关键字附近文本的字符数，用于生成摘要
+const (
+	contentWindowBefore = 200
+	contentWindowSize   = 400
+)
+
+// 全文搜索条件
+type ContentSearchOptions struct {
+	Keyword      string // 搜索关键字
+	RootFolderID int64  // 搜索的子树根目录ID
+	Page         int    // 页码，从1开始
+	PageSize     int    // 每页条数
+}
+
+type ContentSearchHit struct {
+	File      dto.File `json:"file"`
+	Score     float64  `json:"score"`
+	Snippet   string   `json:"snippet"`
+	Content   string   `json:"-"` // 关键字附近的文本，用于生成摘要
+	CutBefore bool     `json:"-"` // Content之前是否还有文本
+	CutAfter  bool     `json:"-"` // Content之后是否还有文本
+}
+
+type ContentSearchResult struct {
+	Total int64              `json:"total"`
+	Items []ContentSearchHit `json:"items"`
+}
+
+/**
+ * @description: 保存文件的文本内容，已存在则覆盖
+ * @param {int64} fileID 文件ID
+ * @param {string} content 文本内容
+ * @return
+ */
+func SaveFileContent(fileID int64, content string) error {
+	query := "INSERT INTO file_contents (file_id, content) VALUES (?, ?) ON DUPLICATE KEY UPDATE content = VALUES(content);"
+	_, err := db.Exec(query, fileID, content)
+	return err
+}
+
+/**
+ * @description: 查询尚未建立内容索引的文件ID
+ * @return {[]int64} 文件ID列表
+ */
+func QueryUnindexedFileIDs() ([]int64, error) {
+	query := "SELECT f.id FROM files f LEFT JOIN file_contents c ON c.file_id = f.id WHERE c.file_id IS NULL;"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+/**
+ * @description: 按文件内容全文搜索，结果按相关度排序
+ * @param {ContentSearchOptions} opts 搜索条件
+ * @return {*} ContentSearchResult 搜索结果
+ */
+func SearchFileContent(opts ContentSearchOptions) (*ContentSearchResult, error) {
+	// 查询子树根目录路径
+	rootPath, err := QueryFolderPath(opts.RootFolderID)
+	if err != nil {
+		return nil, err
+	}
+	subtreePattern := escapeLike(strings.TrimSuffix(rootPath, "/")+"/") + "%"
+
+	// 查询总数
+	var total int64
+	query := "SELECT COUNT(*) FROM file_contents c JOIN files f ON f.id = c.file_id " +
+		"WHERE MATCH(c.content) AGAINST(? IN NATURAL LANGUAGE MODE) AND f.path LIKE ?;"
+	if err := db.QueryRow(query, opts.Keyword, subtreePattern).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	// 分页参数
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PageSize < 1 || opts.PageSize > 100 {
+		opts.PageSize = 20
+	}
+
+	// 只取关键字附近的文本，不传输完整内容，关键字整体未出现时依次定位各个词
+	start, startArgs := contentWindowStart(opts.Keyword)
+	query = "SELECT f.id, f.name, f.path, f.size, f.created_at, f.updated_at, f.parent_folder_id, " +
+		"MATCH(c.content) AGAINST(? IN NATURAL LANGUAGE MODE) AS score, " +
+		"SUBSTRING(c.content, " + start + ", ?), " + start + " > 1, " + start + " + ? <= CHAR_LENGTH(c.content) " +
+		"FROM file_contents c JOIN files f ON f.id = c.file_id " +
+		"WHERE MATCH(c.content) AGAINST(? IN NATURAL LANGUAGE MODE) AND f.path LIKE ? " +
+		"ORDER BY score DESC, f.id LIMIT ? OFFSET ?;"
+	args := []interface{}{opts.Keyword}
+	args = append(args, startArgs...)
+	args = append(args, contentWindowSize)
+	args = append(args, startArgs...)
+	args = append(args, startArgs...)
+	args = append(args, contentWindowSize, opts.Keyword, subtreePattern, opts.PageSize, (opts.Page-1)*opts.PageSize)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ContentSearchHit{}
+	for rows.Next() {
+		var hit ContentSearchHit
+		hit.File.Type = dto.FileTypeFile
+		if err := rows.Scan(&hit.File.ID, &hit.File.Name, &hit.File.Path, &hit.File.Size, &hit.File.CreatedAt, &hit.File.UpdatedAt, &hit.File.ParentFolderID, &hit.Score, &hit.Content, &hit.CutBefore, &hit.CutAfter); err != nil {
+			return nil, err
+		}
+		items = append(items, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &ContentSearchResult{Total: total, Items: items}, nil
+}
+
+/**
+ * @description: 生成关键字附近文本窗口起始位置的表达式，位置从1开始
+ * @param {string} keyword 搜索关键字
+ * @return {string} 表达式
+ * @return {[]interface{}} 表达式参数
+ */
+func contentWindowStart(keyword string) (string, []interface{}) {
+	terms := append([]string{keyword}, strings.Fields(keyword)...)
+	locates := make([]string, 0, len(terms))
+	args := make([]interface{}, 0, len(terms)+1)
+	for _, term := range terms {
+		locates = append(locates, "NULLIF(LOCATE(?, c.content), 0)")
+		args = append(args, term)
+	}
+	args = append(args, contentWindowBefore)
+	return "GREATEST(COALESCE(" + strings.Join(locates, ", ") + ", 1) - ?, 1)", args
+}
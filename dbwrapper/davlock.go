@@ -0,0 +1,210 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 14:05:12
+ * @LastEditTime: 2026-10-19 14:05:12
+ * @FilePath: \CloudDisk\dbwrapper\davlock.go
+ * @Description: WebDAV锁，保存在数据库中，重启后保留并在多个实例之间共享
+ */
+package dbwrapper
+
+import (
+	"database/sql"
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDAVLockNotExist = errors.New("webdav lock does not exist")
+	ErrDAVLocked       = errors.New("webdav resource is locked")
+)
+
+// settings表中的一行，新建锁时以FOR UPDATE锁定，串行化不同实例的冲突检查和写入
+const davLockGuardSetting = "webdav_lock_guard"
+
+type DAVLock struct {
+	Token     string
+	Root      string        // 锁定的路径
+	ZeroDepth bool          // 只锁定Root本身，不包括子项
+	OwnerXML  string        // LOCK请求中的owner元素
+	Duration  time.Duration // 锁的时长
+	ExpiresAt time.Time     // 过期时间
+}
+
+/**
+ * @description: 计算用于比较的锁路径，各级名称按名称唯一性策略规范化，同一条目的不同写法互相冲突
+ * @param {string} root 锁定的路径
+ * @return {string}
+ */
+func davLockRootKey(root string) string {
+	segments := strings.Split(path.Clean("/"+root), "/")
+	for i := range segments {
+		segments[i] = NormalizeName(segments[i])
+	}
+	return path.Clean(strings.Join(segments, "/"))
+}
+
+/**
+ * @description: 新建锁，先删除过期的锁，再检查与已有锁是否冲突
+ * 已有锁在同一路径、已有锁为深度锁且在祖先路径、新锁为深度锁且已有锁在子路径时冲突
+ * @param {*DAVLock} lock 锁信息
+ * @param {time.Time} now 当前时间
+ * @return {*} 冲突时返回ErrDAVLocked
+ */
+func CreateDAVLock(lock *DAVLock, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var guard string
+	if err := tx.QueryRow("SELECT value FROM settings WHERE name = ? FOR UPDATE;", davLockGuardSetting).Scan(&guard); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM locks WHERE expires_at <= ?;", now); err != nil {
+		return err
+	}
+
+	rootKey := davLockRootKey(lock.Root)
+	query := "SELECT COUNT(*) FROM locks WHERE root_key = ?"
+	args := []interface{}{rootKey}
+	for p := rootKey; p != "/"; {
+		p = path.Dir(p)
+		query += " OR (zero_depth = 0 AND root_key = ?)"
+		args = append(args, p)
+	}
+	if !lock.ZeroDepth {
+		query += " OR root_key LIKE ?"
+		args = append(args, escapeLike(strings.TrimSuffix(rootKey, "/"))+"/%")
+	}
+
+	var conflicts int
+	if err := tx.QueryRow(query+";", args...).Scan(&conflicts); err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return ErrDAVLocked
+	}
+
+	query = "INSERT INTO locks (token, root, root_key, zero_depth, owner_xml, duration_ms, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	if _, err := tx.Exec(query, lock.Token, lock.Root, rootKey, lock.ZeroDepth, lock.OwnerXML, lock.Duration.Milliseconds(), lock.ExpiresAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/**
+ * @description: 延长未过期的锁
+ * @param {string} token 锁令牌
+ * @param {time.Time} now 当前时间
+ * @param {time.Duration} duration 新的时长
+ * @return {*DAVLock} 延长后的锁信息
+ */
+func RefreshDAVLock(token string, now time.Time, duration time.Duration) (*DAVLock, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT token, root, zero_depth, owner_xml, duration_ms, expires_at FROM locks WHERE token = ? AND expires_at > ? FOR UPDATE;"
+	lock, err := scanDAVLock(tx.QueryRow(query, token, now).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrDAVLockNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock.Duration = duration
+	lock.ExpiresAt = now.Add(duration)
+	query = "UPDATE locks SET duration_ms = ?, expires_at = ? WHERE token = ?;"
+	if _, err := tx.Exec(query, duration.Milliseconds(), lock.ExpiresAt, token); err != nil {
+		return nil, err
+	}
+	return lock, tx.Commit()
+}
+
+/**
+ * @description: 删除未过期的锁
+ * @param {string} token 锁令牌
+ * @param {time.Time} now 当前时间
+ * @return {*} 锁不存在或已过期时返回ErrDAVLockNotExist
+ */
+func DeleteDAVLock(token string, now time.Time) error {
+	res, err := db.Exec("DELETE FROM locks WHERE token = ? AND expires_at > ?;", token, now)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrDAVLockNotExist
+	}
+	return nil
+}
+
+/**
+ * @description: 按令牌查询未过期的锁，不存在的令牌被忽略
+ * @param {[]string} tokens 锁令牌
+ * @param {time.Time} now 当前时间
+ * @return {[]DAVLock}
+ */
+func QueryDAVLocks(tokens []string, now time.Time) ([]DAVLock, error) {
+	locks := []DAVLock{}
+	if len(tokens) == 0 {
+		return locks, nil
+	}
+
+	args := make([]interface{}, 0, len(tokens)+1)
+	for _, token := range tokens {
+		args = append(args, token)
+	}
+	args = append(args, now)
+	query := "SELECT token, root, zero_depth, owner_xml, duration_ms, expires_at FROM locks WHERE token IN (?" +
+		strings.Repeat(", ?", len(tokens)-1) + ") AND expires_at > ?;"
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lock, err := scanDAVLock(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, *lock)
+	}
+	return locks, rows.Err()
+}
+
+/**
+ * @description: 判断锁是否覆盖路径，深度锁覆盖所有子路径
+ * @param {string} name 路径
+ * @return {bool}
+ */
+func (lock *DAVLock) Covers(name string) bool {
+	rootKey, nameKey := davLockRootKey(lock.Root), davLockRootKey(name)
+	if nameKey == rootKey {
+		return true
+	}
+	return !lock.ZeroDepth && (rootKey == "/" || strings.HasPrefix(nameKey, rootKey+"/"))
+}
+
+/**
+ * @description: 扫描一行锁
+ * @param {func(dest ...interface{}) error} scan
+ * @return {*DAVLock}
+ */
+func scanDAVLock(scan func(dest ...interface{}) error) (*DAVLock, error) {
+	var lock DAVLock
+	var durationMs int64
+	if err := scan(&lock.Token, &lock.Root, &lock.ZeroDepth, &lock.OwnerXML, &durationMs, &lock.ExpiresAt); err != nil {
+		return nil, err
+	}
+	lock.Duration = time.Duration(durationMs) * time.Millisecond
+	return &lock, nil
+}
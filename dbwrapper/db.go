@@ -1,7 +1,7 @@
 /*
  * @Author: shanghanjin
  * @Date: 2024-08-25 20:51:47
- * @LastEditTime: 2025-01-17 17:07:19
+ * @LastEditTime: 2026-10-19 14:05:12
  * @FilePath: \CloudDisk\dbwrapper\db.go
  * @Description: 数据库操作封装
  */
@@ -13,6 +13,7 @@ import (
 	"CloudDisk/logwrapper"
 	"errors"
 	"path"
+	"strings"
 
 	"database/sql"
 	"fmt"
@@ -28,6 +29,15 @@ var (
 	once sync.Once
 )
 
+var (
+	ErrFolderNotExist       = errors.New("folder does not exist")
+	ErrFileNotExist         = errors.New("file does not exist")
+	ErrParentFolderNotExist = errors.New("parent folder does not exist")
+	ErrFolderExist          = errors.New("folder already exists")
+	ErrFileExist            = errors.New("file already exists")
+	ErrMoveFolderIntoSelf   = errors.New("cannot move folder into itself")
+)
+
 /**
  * @description: 初始化数据库连接
  * @return
@@ -57,7 +67,7 @@ func InitDB() {
 		CREATE TABLE IF NOT EXISTS folders (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 文件夹唯一标识
 			name VARCHAR(255) NOT NULL,            -- 文件夹名
-			path VARCHAR(1024) NOT NULL,           -- 文件夹路径
+			path VARCHAR(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,  -- 文件夹路径，区分大小写和重音
 			parent_folder_id BIGINT,               -- 父文件夹ID,根目录为NULL
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 文件夹创建时间
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  -- 文件夹更新时间
@@ -119,7 +129,7 @@ func InitDB() {
 		CREATE TABLE IF NOT EXISTS files (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 文件唯一标识
 			name VARCHAR(255) NOT NULL,            -- 文件名
-			path VARCHAR(1024) NOT NULL,           -- 文件存储路径
+			path VARCHAR(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,  -- 文件存储路径，区分大小写和重音
 			size BIGINT NOT NULL,                  -- 文件大小（以字节为单位）
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 文件创建时间
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  -- 文件更新时间
@@ -131,6 +141,253 @@ func InitDB() {
 		if _, err := db.Exec(createTabFile); err != nil {
 			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
 		}
+
+		// 检查 file_contents 表是否存在，如果不存在则创建，使用ngram解析器以支持中文分词
+		createTabFileContent := `
+		CREATE TABLE IF NOT EXISTS file_contents (
+			file_id BIGINT PRIMARY KEY,            -- 文件ID
+			content MEDIUMTEXT NOT NULL,           -- 提取出的文本内容
+			indexed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  -- 索引时间
+			FULLTEXT INDEX ft_file_contents (content) WITH PARSER ngram,
+			CONSTRAINT fk_content_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE  -- 文件ID外键,删除文件时级联删除内容
+		);
+		`
+
+		if _, err := db.Exec(createTabFileContent); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 file_mime_types 表是否存在，如果不存在则创建
+		createTabFileMimeType := `
+		CREATE TABLE IF NOT EXISTS file_mime_types (
+			file_id BIGINT PRIMARY KEY,            -- 文件ID
+			mime_type VARCHAR(255) NOT NULL,       -- 根据内容和扩展名识别的MIME类型
+			detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  -- 识别时间
+			CONSTRAINT fk_mime_type_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE  -- 文件ID外键,删除文件时级联删除记录
+		);
+		`
+
+		if _, err := db.Exec(createTabFileMimeType); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 tags 表是否存在，如果不存在则创建，file_id和folder_id只有一个有值
+		createTabTag := `
+		CREATE TABLE IF NOT EXISTS tags (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 标签唯一标识
+			name VARCHAR(64) NOT NULL,             -- 标签名
+			file_id BIGINT,                        -- 所属文件ID
+			folder_id BIGINT,                      -- 所属文件夹ID
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 标签创建时间
+			UNIQUE KEY uk_tags_file (file_id, name),
+			UNIQUE KEY uk_tags_folder (folder_id, name),
+			INDEX idx_tags_name (name),
+			CONSTRAINT fk_tag_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,  -- 删除文件时级联删除标签
+			CONSTRAINT fk_tag_folder FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE  -- 删除文件夹时级联删除标签
+		);
+		`
+
+		if _, err := db.Exec(createTabTag); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 metadata 表是否存在，如果不存在则创建，file_id和folder_id只有一个有值
+		createTabMetadata := `
+		CREATE TABLE IF NOT EXISTS metadata (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 元数据唯一标识
+			meta_key VARCHAR(64) NOT NULL,         -- 键
+			meta_value VARCHAR(1024) NOT NULL,     -- 值
+			file_id BIGINT,                        -- 所属文件ID
+			folder_id BIGINT,                      -- 所属文件夹ID
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  -- 更新时间
+			UNIQUE KEY uk_metadata_file (file_id, meta_key),
+			UNIQUE KEY uk_metadata_folder (folder_id, meta_key),
+			INDEX idx_metadata_key_value (meta_key, meta_value(255)),
+			CONSTRAINT fk_metadata_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,  -- 删除文件时级联删除元数据
+			CONSTRAINT fk_metadata_folder FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE  -- 删除文件夹时级联删除元数据
+		);
+		`
+
+		if _, err := db.Exec(createTabMetadata); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 favorites 表是否存在，如果不存在则创建，file_id和folder_id只有一个有值
+		createTabFavorite := `
+		CREATE TABLE IF NOT EXISTS favorites (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 收藏唯一标识
+			user_name VARCHAR(64) NOT NULL,        -- 用户名
+			file_id BIGINT,                        -- 收藏的文件ID
+			folder_id BIGINT,                      -- 收藏的文件夹ID
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 收藏时间
+			UNIQUE KEY uk_favorites_file (user_name, file_id),
+			UNIQUE KEY uk_favorites_folder (user_name, folder_id),
+			CONSTRAINT fk_favorite_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,  -- 删除文件时级联删除收藏
+			CONSTRAINT fk_favorite_folder FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE  -- 删除文件夹时级联删除收藏
+		);
+		`
+
+		if _, err := db.Exec(createTabFavorite); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 recent_files 表是否存在，如果不存在则创建
+		createTabRecentFile := `
+		CREATE TABLE IF NOT EXISTS recent_files (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 记录唯一标识
+			user_name VARCHAR(64) NOT NULL,        -- 用户名
+			file_id BIGINT NOT NULL,               -- 访问的文件ID
+			action VARCHAR(16) NOT NULL,           -- 访问方式：upload/download
+			accessed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 访问时间
+			UNIQUE KEY uk_recent_files (user_name, file_id),
+			INDEX idx_recent_files_accessed (user_name, accessed_at),
+			CONSTRAINT fk_recent_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE  -- 删除文件时级联删除记录
+		);
+		`
+
+		if _, err := db.Exec(createTabRecentFile); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 access_keys 表是否存在，如果不存在则创建
+		createTabAccessKey := `
+		CREATE TABLE IF NOT EXISTS access_keys (
+			access_key_id VARCHAR(32) PRIMARY KEY,     -- 访问密钥ID
+			secret_access_key VARCHAR(64) NOT NULL,    -- 访问密钥
+			user_name VARCHAR(64) NOT NULL,            -- 所属用户
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 创建时间
+			INDEX idx_access_keys_user (user_name)
+		);
+		`
+
+		if _, err := db.Exec(createTabAccessKey); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 changes 表是否存在，如果不存在则创建，不设外键以保留已删除条目的记录
+		createTabChange := `
+		CREATE TABLE IF NOT EXISTS changes (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 变更ID，即游标
+			item_type VARCHAR(16) NOT NULL,        -- 条目类型：file/folder
+			item_id BIGINT NOT NULL,               -- 文件/文件夹ID
+			action VARCHAR(16) NOT NULL,           -- 变更类型：create/rename/move/delete/update
+			path VARCHAR(1024) NOT NULL,           -- 变更后的路径
+			old_path VARCHAR(1024) NOT NULL DEFAULT '',  -- 变更前的路径
+			parent_folder_id BIGINT NOT NULL,      -- 父文件夹ID
+			size BIGINT NOT NULL DEFAULT 0,        -- 文件大小
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP  -- 变更时间
+		);
+		`
+
+		if _, err := db.Exec(createTabChange); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 webhooks 表是否存在，如果不存在则创建
+		createTabWebhook := `
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- Webhook ID
+			user_name VARCHAR(64) NOT NULL,        -- 所属用户
+			url VARCHAR(2048) NOT NULL,            -- 接收地址
+			secret VARCHAR(128) NOT NULL,          -- 签名密钥
+			events VARCHAR(255) NOT NULL DEFAULT '',  -- 订阅的变更类型，逗号分隔，为空表示全部
+			folder_path VARCHAR(1024) NOT NULL DEFAULT '/',  -- 只投递该路径及其下的变更
+			last_change_id BIGINT NOT NULL DEFAULT 0,  -- 已生成投递记录的最大变更ID
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 创建时间
+			INDEX idx_webhooks_user (user_name)
+		);
+		`
+
+		if _, err := db.Exec(createTabWebhook); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 webhook_deliveries 表是否存在，如果不存在则创建
+		createTabWebhookDelivery := `
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,  -- 投递记录ID
+			webhook_id BIGINT NOT NULL,            -- Webhook ID
+			change_id BIGINT NOT NULL,             -- 投递的变更ID
+			status VARCHAR(16) NOT NULL,           -- 状态：pending/succeeded/failed
+			attempts INT NOT NULL DEFAULT 0,       -- 已投递次数
+			response_status INT NOT NULL DEFAULT 0,  -- 最后一次投递的HTTP状态码
+			error VARCHAR(1024) NOT NULL DEFAULT '',  -- 最后一次投递的错误
+			next_attempt_at TIMESTAMP NULL,        -- 下次投递时间，不再投递时为NULL
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- 创建时间
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  -- 更新时间
+			UNIQUE KEY uk_webhook_deliveries (webhook_id, change_id),
+			INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+			CONSTRAINT fk_delivery_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE  -- 删除Webhook时级联删除投递记录
+		);
+		`
+
+		if _, err := db.Exec(createTabWebhookDelivery); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 settings 表是否存在，如果不存在则创建
+		createTabSetting := `
+		CREATE TABLE IF NOT EXISTS settings (
+			name VARCHAR(64) PRIMARY KEY,          -- 设置项
+			value VARCHAR(255) NOT NULL            -- 设置值
+		);
+		`
+
+		if _, err := db.Exec(createTabSetting); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+
+		// 检查 locks 表是否存在，如果不存在则创建，并写入新建锁时加锁用的设置行
+		createTabLock := `
+		CREATE TABLE IF NOT EXISTS locks (
+			token VARCHAR(64) PRIMARY KEY,         -- 锁令牌
+			root VARCHAR(1024) NOT NULL,           -- 锁定的路径
+			root_key VARCHAR(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,  -- 按名称唯一性策略规范化的路径
+			zero_depth BOOLEAN NOT NULL,           -- 是否只锁定路径本身
+			owner_xml TEXT NOT NULL,               -- LOCK请求中的owner元素
+			duration_ms BIGINT NOT NULL,           -- 锁的时长
+			expires_at DATETIME(3) NOT NULL,       -- 过期时间
+			INDEX idx_locks_root_key (root_key(255)),
+			INDEX idx_locks_expires_at (expires_at)
+		);
+		`
+
+		if _, err := db.Exec(createTabLock); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create table: %v", err)
+		}
+		if _, err := db.Exec("INSERT IGNORE INTO settings (name, value) VALUES (?, '');", davLockGuardSetting); err != nil {
+			logwrapper.Logger.Fatalf("Failed to create setting: %v", err)
+		}
+
+		// 已有的路径列改为二进制排序规则
+		if err := migratePathCollation(); err != nil {
+			logwrapper.Logger.Fatalf("Failed to migrate path collation: %v", err)
+		}
+
+		// 按名称唯一性策略建立规范化名称列和唯一索引
+		if err := migrateNormalizedNames(); err != nil {
+			logwrapper.Logger.Fatalf("Failed to migrate normalized names: %v", err)
+		}
+
+		// 创建搜索用的索引，path列过长，只对前缀建索引
+		indexes := []struct {
+			tableName string
+			indexName string
+			columns   string
+		}{
+			{"folders", "idx_folders_name", "name"},
+			{"folders", "idx_folders_path", "path(255)"},
+			{"folders", "idx_folders_updated_at", "updated_at"},
+			{"files", "idx_files_name", "name"},
+			{"files", "idx_files_path", "path(255)"},
+			{"files", "idx_files_size", "size"},
+			{"files", "idx_files_updated_at", "updated_at"},
+		}
+		for _, index := range indexes {
+			if err := createIndexIfNotExist(index.tableName, index.indexName, index.columns); err != nil {
+				logwrapper.Logger.Fatalf("Failed to create index %s: %v", index.indexName, err)
+			}
+		}
 	})
 }
 
@@ -143,55 +400,117 @@ func CloseDB() error {
 }
 
 type QueryFolderResult struct {
-	Self    *dto.Folder  `json:"self"`
-	Folders []dto.Folder `json:"folders"`
-	Files   []dto.File   `json:"files"`
+	Self       *dto.Folder  `json:"self"`
+	Folders    []dto.Folder `json:"folders"`
+	Files      []dto.File   `json:"files"`
+	Total      int64        `json:"total"`      // 子文件夹和子文件总数
+	NextCursor string       `json:"nextCursor"` // 下一页游标，没有下一页时为空
 }
 
 /**
- * @description: 查询文件夹信息，包括文件夹本身信息和所有子文件夹&子文件信息
+ * @description: 查询文件夹信息，包括文件夹本身信息和子文件夹&子文件信息，支持排序和游标分页
  * @param {int64} folderID 文件夹ID
+ * @param {ListOptions} opts 排序和分页条件
  * @return {*} QueryFolderResult 被查询信息
  */
-func QueryFolderInfoFull(folderID int64) (*QueryFolderResult, error) {
+func QueryFolderInfoFull(folderID int64, opts ListOptions) (*QueryFolderResult, error) {
 	var (
-		folders    = []dto.Folder{}
-		files      = []dto.File{}
-		rowsFolder *sql.Rows
-		rowsFile   *sql.Rows
-		err        error
+		folders = []dto.Folder{}
+		files   = []dto.File{}
+		total   int64
+		err     error
 	)
 
-	// 查询文件夹信息
-	query := "SELECT id, name, path, parent_folder_id, created_at, updated_at FROM folders WHERE parent_folder_id = ?;"
-	if rowsFolder, err = db.Query(query, folderID); err != nil {
+	// 生成排序和游标条件
+	sortExpr, cursorCond, cursorArgs, orderBy, err := buildListOrder(opts)
+	if err != nil {
 		return nil, err
 	}
-	defer rowsFolder.Close()
 
-	for rowsFolder.Next() {
-		var folder dto.Folder
-		if err := rowsFolder.Scan(&folder.ID, &folder.Name, &folder.Path, &folder.ParentFolderID, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
-			return nil, err
-		}
+	// 按标签和元数据过滤
+	folderConds, folderArgs := labelFilterConds("folder", "folders.id", opts.Tags, opts.Metadata)
+	folderWhere := strings.Join(append([]string{"parent_folder_id = ?"}, folderConds...), " AND ")
+	folderArgs = append([]interface{}{folderID}, folderArgs...)
+	fileConds, fileArgs := labelFilterConds("file", "files.id", opts.Tags, opts.Metadata)
+	fileWhere := strings.Join(append([]string{"parent_folder_id = ?"}, fileConds...), " AND ")
+	fileArgs = append([]interface{}{folderID}, fileArgs...)
+
+	// 查询总数
+	query := "SELECT (SELECT COUNT(*) FROM folders WHERE " + folderWhere + ") + (SELECT COUNT(*) FROM files WHERE " + fileWhere + ");"
+	if err = db.QueryRow(query, append(append([]interface{}{}, folderArgs...), fileArgs...)...).Scan(&total); err != nil {
+		return nil, err
+	}
 
-		folders = append(folders, folder)
+	// 合并查询子文件夹和子文件
+	query = "SELECT kind, id, name, path, size, parent_folder_id, created_at, updated_at, " + sortExpr + " AS sort_key FROM (" +
+		"SELECT 0 AS kind, id, name, path, 0 AS size, parent_folder_id, created_at, updated_at FROM folders WHERE " + folderWhere + " " +
+		"UNION ALL " +
+		"SELECT 1 AS kind, id, name, path, size, parent_folder_id, created_at, updated_at FROM files WHERE " + fileWhere +
+		") AS t"
+	args := append(append([]interface{}{}, folderArgs...), fileArgs...)
+	if cursorCond != "" {
+		query += " WHERE " + cursorCond
+		args = append(args, cursorArgs...)
+	}
+	query += " " + orderBy
+	if opts.Limit > 0 {
+		// 多查一条用于判断是否有下一页
+		query += " LIMIT ?"
+		args = append(args, opts.Limit+1)
 	}
 
-	// 查询文件信息
-	query = "SELECT id, name, path, size, created_at, updated_at, parent_folder_id FROM files WHERE parent_folder_id = ?;"
-	if rowsFile, err = db.Query(query, folderID); err != nil {
+	rows, err := db.Query(query, args...)
+	if err != nil {
 		return nil, err
 	}
-	defer rowsFile.Close()
+	defer rows.Close()
+
+	var (
+		count   int
+		hasMore bool
+		lastKey interface{}
+		lastID  int64
+		lastKnd int
+	)
+	for rows.Next() {
+		if opts.Limit > 0 && count == opts.Limit {
+			hasMore = true
+			break
+		}
 
-	for rowsFile.Next() {
-		var file dto.File
-		if err := rowsFile.Scan(&file.ID, &file.Name, &file.Path, &file.Size, &file.CreatedAt, &file.UpdatedAt, &file.ParentFolderID); err != nil {
+		var (
+			item    dto.File
+			kind    int
+			sortKey interface{}
+		)
+		if err := rows.Scan(&kind, &item.ID, &item.Name, &item.Path, &item.Size, &item.ParentFolderID, &item.CreatedAt, &item.UpdatedAt, &sortKey); err != nil {
 			return nil, err
 		}
 
-		files = append(files, file)
+		if kind == 0 {
+			folders = append(folders, dto.Folder{ID: item.ID, ParentFolderID: item.ParentFolderID, Name: item.Name, Path: item.Path, CreatedAt: item.CreatedAt, UpdatedAt: item.UpdatedAt})
+		} else {
+			item.Type = dto.FileTypeFile
+			files = append(files, item)
+		}
+
+		// 驱动以[]byte返回字符串列
+		if b, ok := sortKey.([]byte); ok {
+			sortKey = string(b)
+		}
+		lastKnd, lastKey, lastID = kind, sortKey, item.ID
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 填充标签和元数据
+	if err := fillFolderLabels(folders); err != nil {
+		return nil, err
+	}
+	if err := fillFileLabels(files); err != nil {
+		return nil, err
 	}
 
 	// 查询自己本身信息
@@ -200,7 +519,11 @@ func QueryFolderInfoFull(folderID int64) (*QueryFolderResult, error) {
 		return nil, err
 	}
 
-	return &QueryFolderResult{Self: self, Folders: folders, Files: files}, nil
+	result := &QueryFolderResult{Self: self, Folders: folders, Files: files, Total: total}
+	if hasMore {
+		result.NextCursor = encodeListCursor(opts, lastKnd, lastKey, lastID)
+	}
+	return result, nil
 }
 
 /**
@@ -215,7 +538,7 @@ func QueryFolderInfo(folderID int64) (*dto.Folder, error) {
 	err := db.QueryRow(query, folderID).Scan(&folder.ID, &folder.Name, &folder.Path, &parentFolderID, &folder.CreatedAt, &folder.UpdatedAt)
 	if err == sql.ErrNoRows {
 		// 如果没有找到记录，返回错误
-		return nil, errors.New("folder does not exist")
+		return nil, ErrFolderNotExist
 	} else if err != nil {
 		// 其他错误情况
 		return nil, err
@@ -227,7 +550,13 @@ func QueryFolderInfo(folderID int64) (*dto.Folder, error) {
 	} else {
 		folder.ParentFolderID = 0
 	}
-	return &folder, nil
+
+	// 填充标签和元数据
+	folders := []dto.Folder{folder}
+	if err := fillFolderLabels(folders); err != nil {
+		return nil, err
+	}
+	return &folders[0], nil
 }
 
 /**
@@ -243,13 +572,19 @@ func QueryFileInfo(fileID int64) (*dto.File, error) {
 	err := db.QueryRow(query, fileID).Scan(&file.ID, &file.Name, &file.Path, &file.Size, &file.CreatedAt, &file.UpdatedAt, &file.ParentFolderID)
 	if err == sql.ErrNoRows {
 		// 如果没有找到记录，返回错误
-		return nil, errors.New("file does not exist")
+		return nil, ErrFileNotExist
 	} else if err != nil {
 		// 其他错误情况
 		return nil, err
 	}
 
-	return &file, nil
+	// 填充标签和元数据
+	file.Type = dto.FileTypeFile
+	files := []dto.File{file}
+	if err := fillFileLabels(files); err != nil {
+		return nil, err
+	}
+	return &files[0], nil
 }
 
 /**
@@ -263,7 +598,7 @@ func QueryFolderPath(folderID int64) (string, error) {
 
 	err := db.QueryRow(query, folderID).Scan(&folderPath)
 	if err == sql.ErrNoRows {
-		return "", errors.New("folder does not exist")
+		return "", ErrFolderNotExist
 	} else if err != nil {
 		return "", err
 	}
@@ -282,7 +617,7 @@ func CreateFolder(folderName string, parentFolderID int64) (int64, error) {
 	if idExist, err := FolderExistByID(parentFolderID); err != nil {
 		return 0, err
 	} else if !idExist {
-		return 0, errors.New("parent folder does not exist")
+		return 0, ErrParentFolderNotExist
 	}
 
 	// 查询父文件夹路径
@@ -294,21 +629,26 @@ func CreateFolder(folderName string, parentFolderID int64) (int64, error) {
 	// 拼接文件夹路径
 	folderPath := path.Join(pathParent, folderName)
 
-	// 检查文件夹是否已存在，如果存在则失败
-	if pathExist, err := FolderExistByPath(folderPath); err != nil {
+	tx, err := db.Begin()
+	if err != nil {
 		return 0, err
-	} else if pathExist {
-		return 0, errors.New("folder already exists")
 	}
+	defer tx.Rollback()
 
-	// 插入新文件夹
-	query := "INSERT INTO folders (name, path, parent_folder_id) VALUES (?, ?, ?);"
-	res, err := db.Exec(query, folderName, folderPath, parentFolderID)
+	// 插入新文件夹，同名文件夹已存在时违反唯一索引
+	query := "INSERT INTO folders (name, normalized_name, path, parent_folder_id) VALUES (?, ?, ?, ?);"
+	res, err := tx.Exec(query, folderName, NormalizeName(folderName), folderPath, parentFolderID)
+	if isDuplicateEntry(err) {
+		return 0, ErrFolderExist
+	} else if err != nil {
+		return 0, err
+	}
+	folderID, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
 
-	return res.LastInsertId()
+	return folderID, commitWithChange(tx, Change{ItemType: "folder", ItemID: folderID, Action: ChangeCreate, Path: folderPath, ParentFolderID: parentFolderID})
 }
 
 /**
@@ -323,7 +663,7 @@ func CreateFile(fileName string, fileSize int64, parentFolderID int64) (int64, e
 	if idExist, err := FolderExistByID(parentFolderID); err != nil {
 		return 0, err
 	} else if !idExist {
-		return 0, errors.New("parent folder does not exist")
+		return 0, ErrParentFolderNotExist
 	}
 
 	// 查询父文件夹路径
@@ -335,21 +675,26 @@ func CreateFile(fileName string, fileSize int64, parentFolderID int64) (int64, e
 	// 拼接文件路径
 	filePath := path.Join(pathParent, fileName)
 
-	// 检查文件是否已存在，如果存在则失败
-	if pathExist, err := FileExistByPath(filePath); err != nil {
+	tx, err := db.Begin()
+	if err != nil {
 		return 0, err
-	} else if pathExist {
-		return 0, errors.New("file already exists")
 	}
+	defer tx.Rollback()
 
-	// 插入新文件
-	query := "INSERT INTO files (name, path, size, parent_folder_id) VALUES (?, ?, ?, ?);"
-	res, err := db.Exec(query, fileName, filePath, fileSize, parentFolderID)
+	// 插入新文件，同名文件已存在时违反唯一索引
+	query := "INSERT INTO files (name, normalized_name, path, size, parent_folder_id) VALUES (?, ?, ?, ?, ?);"
+	res, err := tx.Exec(query, fileName, NormalizeName(fileName), filePath, fileSize, parentFolderID)
+	if isDuplicateEntry(err) {
+		return 0, ErrFileExist
+	} else if err != nil {
+		return 0, err
+	}
+	fileID, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
 
-	return res.LastInsertId()
+	return fileID, commitWithChange(tx, Change{ItemType: "file", ItemID: fileID, Action: ChangeCreate, Path: filePath, ParentFolderID: parentFolderID, Size: fileSize})
 }
 
 /**
@@ -359,67 +704,215 @@ func CreateFile(fileName string, fileSize int64, parentFolderID int64) (int64, e
  * @return
  */
 func RenameFolder(folderID int64, folderNewName string) error {
-	// 检查文件夹是否存在
-	if idExists, err := FolderExistByID(folderID); err != nil {
+	// 查询父文件夹ID
+	parentFolderID, err := QueryParentFolderID(folderID, "folders")
+	if err == sql.ErrNoRows {
+		return ErrFolderNotExist
+	} else if err != nil {
 		return err
-	} else if !idExists {
-		return errors.New("folder does not exist")
 	}
 
+	return MoveFolder(folderID, parentFolderID, folderNewName)
+}
+
+/**
+ * @description: 重命名文件
+ * @param {int64} fileID 文件ID
+ * @param {string} fileName 文件名称
+ * @return
+ */
+func RenameFile(fileID int64, fileNewName string) error {
 	// 查询父文件夹ID
-	parentFolderID, err := QueryParentFolderID(folderID, "folders")
-	if err != nil {
+	parentFolderID, err := QueryParentFolderID(fileID, "files")
+	if err == sql.ErrNoRows {
+		return ErrFileNotExist
+	} else if err != nil {
 		return err
 	}
 
-	// 查询父文件夹路径
-	parentPath, err := QueryFolderPath(parentFolderID)
+	return MoveFile(fileID, parentFolderID, fileNewName)
+}
+
+/**
+ * @description: 移动文件夹，同时更新所有子文件夹和子文件的路径
+ * @param {int64} folderID 文件夹ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} folderNewName 新的文件夹名称
+ * @return
+ */
+func MoveFolder(folderID int64, newParentFolderID int64, folderNewName string) error {
+	return moveFolder(folderID, newParentFolderID, folderNewName, 0)
+}
+
+/**
+ * @description: 移动文件夹并替换新位置上的同名文件夹，删除和移动在同一事务中完成
+ * @param {int64} folderID 文件夹ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} folderNewName 新的文件夹名称
+ * @param {int64} replacedFolderID 被替换的文件夹ID
+ * @return
+ */
+func MoveFolderReplacing(folderID int64, newParentFolderID int64, folderNewName string, replacedFolderID int64) error {
+	return moveFolder(folderID, newParentFolderID, folderNewName, replacedFolderID)
+}
+
+/**
+ * @description: 移动文件夹，同时更新所有子文件夹和子文件的路径
+ * @param {int64} folderID 文件夹ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} folderNewName 新的文件夹名称
+ * @param {int64} replacedFolderID 被替换的文件夹ID，为0时不替换
+ * @return
+ */
+func moveFolder(folderID int64, newParentFolderID int64, folderNewName string, replacedFolderID int64) error {
+	// 查询文件夹当前路径
+	oldPath, err := QueryFolderPath(folderID)
+	if err != nil {
+		return err
+	}
+	oldParentFolderID, err := QueryParentFolderID(folderID, "folders")
 	if err != nil {
 		return err
 	}
 
+	// 查询新的父文件夹路径
+	parentPath, err := QueryFolderPath(newParentFolderID)
+	if err == ErrFolderNotExist {
+		return ErrParentFolderNotExist
+	} else if err != nil {
+		return err
+	}
+
+	// 不能移动到自身或子文件夹下
+	if parentPath == oldPath || strings.HasPrefix(parentPath, oldPath+"/") {
+		return ErrMoveFolderIntoSelf
+	}
+
 	// 拼接新文件夹路径
 	newPath := path.Join(parentPath, folderNewName)
 
-	// 更新文件夹名称和路径
-	query := "UPDATE folders SET name = ?, path = ? WHERE id = ?;"
-	_, err = db.Exec(query, folderNewName, newPath, folderID)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 先删除被替换的文件夹，级联删除其下的条目
+	var changes []Change
+	if replacedFolderID != 0 {
+		change, err := deleteFolderTx(tx, replacedFolderID)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+
+	// 更新文件夹名称、路径和父文件夹，同名文件夹已存在时违反唯一索引
+	query := "UPDATE folders SET name = ?, normalized_name = ?, path = ?, parent_folder_id = ? WHERE id = ?;"
+	if _, err := tx.Exec(query, folderNewName, NormalizeName(folderNewName), newPath, newParentFolderID, folderID); isDuplicateEntry(err) {
+		return ErrFolderExist
+	} else if err != nil {
+		return err
+	}
+
+	// 替换子孙文件夹和文件的路径前缀
+	prefixPattern := escapeLike(oldPath+"/") + "%"
+	for _, tableName := range []string{"folders", "files"} {
+		query = fmt.Sprintf("UPDATE %s SET path = CONCAT(?, SUBSTRING(path, ?)) WHERE path LIKE ?;", tableName)
+		if _, err := tx.Exec(query, newPath, len([]rune(oldPath))+1, prefixPattern); err != nil {
+			return err
+		}
+	}
+
+	if newPath != oldPath {
+		changes = append(changes, Change{ItemType: "folder", ItemID: folderID, Action: moveAction(oldParentFolderID, newParentFolderID), Path: newPath, OldPath: oldPath, ParentFolderID: newParentFolderID})
+	}
+	if len(changes) == 0 {
+		return tx.Commit()
+	}
+	return commitWithChange(tx, changes...)
 }
 
 /**
- * @description: 重命名文件
+ * @description: 移动文件
  * @param {int64} fileID 文件ID
- * @param {string} fileName 文件名称
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} fileNewName 新的文件名称
  * @return
  */
-func RenameFile(fileID int64, fileNewName string) error {
-	// 检查文件是否存在
-	if idExists, err := FileExistByID(fileID); err != nil {
-		return err
-	} else if !idExists {
-		return errors.New("file does not exist")
-	}
+func MoveFile(fileID int64, newParentFolderID int64, fileNewName string) error {
+	return moveFile(fileID, newParentFolderID, fileNewName, 0)
+}
 
-	// 查询父文件夹ID
-	parentFolderID, err := QueryParentFolderID(fileID, "files")
+/**
+ * @description: 移动文件并替换新位置上的同名文件，删除和移动在同一事务中完成
+ * @param {int64} fileID 文件ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} fileNewName 新的文件名称
+ * @param {int64} replacedFileID 被替换的文件ID
+ * @return
+ */
+func MoveFileReplacing(fileID int64, newParentFolderID int64, fileNewName string, replacedFileID int64) error {
+	return moveFile(fileID, newParentFolderID, fileNewName, replacedFileID)
+}
+
+/**
+ * @description: 移动文件
+ * @param {int64} fileID 文件ID
+ * @param {int64} newParentFolderID 新的父文件夹ID
+ * @param {string} fileNewName 新的文件名称
+ * @param {int64} replacedFileID 被替换的文件ID，为0时不替换
+ * @return
+ */
+func moveFile(fileID int64, newParentFolderID int64, fileNewName string, replacedFileID int64) error {
+	// 查询文件当前路径
+	file, err := QueryFileInfo(fileID)
 	if err != nil {
 		return err
 	}
 
-	// 查询父文件夹路径
-	parentPath, err := QueryFolderPath(parentFolderID)
-	if err != nil {
+	// 查询新的父文件夹路径
+	parentPath, err := QueryFolderPath(newParentFolderID)
+	if err == ErrFolderNotExist {
+		return ErrParentFolderNotExist
+	} else if err != nil {
 		return err
 	}
 
 	// 拼接新文件路径
 	newPath := path.Join(parentPath, fileNewName)
 
-	// 更新文件名称和路径
-	query := "UPDATE files SET name = ?, path = ? WHERE id = ?;"
-	_, err = db.Exec(query, fileNewName, newPath, fileID)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 先删除被替换的文件
+	var changes []Change
+	if replacedFileID != 0 {
+		change, err := deleteFileTx(tx, replacedFileID)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+
+	// 更新文件名称、路径和父文件夹，同名文件已存在时违反唯一索引
+	query := "UPDATE files SET name = ?, normalized_name = ?, path = ?, parent_folder_id = ? WHERE id = ?;"
+	if _, err := tx.Exec(query, fileNewName, NormalizeName(fileNewName), newPath, newParentFolderID, fileID); isDuplicateEntry(err) {
+		return ErrFileExist
+	} else if err != nil {
+		return err
+	}
+
+	if newPath != file.Path {
+		changes = append(changes, Change{ItemType: "file", ItemID: fileID, Action: moveAction(file.ParentFolderID, newParentFolderID), Path: newPath, OldPath: file.Path, ParentFolderID: newParentFolderID, Size: file.Size})
+	}
+	if len(changes) == 0 {
+		return tx.Commit()
+	}
+	return commitWithChange(tx, changes...)
 }
 
 /**
@@ -428,17 +921,39 @@ func RenameFile(fileID int64, fileNewName string) error {
  * @return
  */
 func DeleteFolder(folderID int64) error {
-	// 检查文件夹是否存在
-	if idExists, err := FolderExistByID(folderID); err != nil {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	change, err := deleteFolderTx(tx, folderID)
+	if err != nil {
 		return err
-	} else if !idExists {
-		return errors.New("folder does not exist")
+	}
+	return commitWithChange(tx, change)
+}
+
+/**
+ * @description: 在事务中删除文件夹
+ * @param {*sql.Tx} tx 事务
+ * @param {int64} folderID 文件夹ID
+ * @return {Change} 待写入的变更
+ */
+func deleteFolderTx(tx *sql.Tx, folderID int64) (Change, error) {
+	// 检查文件夹是否存在
+	folder, err := QueryFolderInfo(folderID)
+	if err != nil {
+		return Change{}, err
 	}
 
 	// 删除文件夹，级联关系保证了子文件夹和文件也会被删除
 	query := "DELETE FROM folders WHERE id = ?;"
-	_, err := db.Exec(query, folderID)
-	return err
+	if _, err := tx.Exec(query, folderID); err != nil {
+		return Change{}, err
+	}
+
+	return Change{ItemType: "folder", ItemID: folderID, Action: ChangeDelete, Path: folder.Path, ParentFolderID: folder.ParentFolderID}, nil
 }
 
 /**
@@ -447,17 +962,39 @@ func DeleteFolder(folderID int64) error {
  * @return
  */
 func DeleteFile(fileID int64) error {
-	// 检查文件是否存在
-	if idExists, err := FileExistByID(fileID); err != nil {
+	tx, err := db.Begin()
+	if err != nil {
 		return err
-	} else if !idExists {
-		return errors.New("file does not exist")
+	}
+	defer tx.Rollback()
+
+	change, err := deleteFileTx(tx, fileID)
+	if err != nil {
+		return err
+	}
+	return commitWithChange(tx, change)
+}
+
+/**
+ * @description: 在事务中删除文件
+ * @param {*sql.Tx} tx 事务
+ * @param {int64} fileID 文件ID
+ * @return {Change} 待写入的变更
+ */
+func deleteFileTx(tx *sql.Tx, fileID int64) (Change, error) {
+	// 检查文件是否存在
+	file, err := QueryFileInfo(fileID)
+	if err != nil {
+		return Change{}, err
 	}
 
 	// 删除文件
 	query := "DELETE FROM files WHERE id = ?;"
-	_, err := db.Exec(query, fileID)
-	return err
+	if _, err := tx.Exec(query, fileID); err != nil {
+		return Change{}, err
+	}
+
+	return Change{ItemType: "file", ItemID: fileID, Action: ChangeDelete, Path: file.Path, ParentFolderID: file.ParentFolderID, Size: file.Size}, nil
 }
 
 /**
@@ -486,6 +1023,40 @@ func UpdateFolderUpdateTime(folderID int64) error {
 	return err
 }
 
+/**
+ * @description: 按路径查询文件夹信息
+ * @param {string} folderPath 文件夹路径
+ * @return {*} dto.Folder 被查询信息
+ */
+func QueryFolderInfoByPath(folderPath string) (*dto.Folder, error) {
+	var folderID int64
+	err := db.QueryRow("SELECT id FROM folders WHERE path = ?;", normalizePath(folderPath)).Scan(&folderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrFolderNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	return QueryFolderInfo(folderID)
+}
+
+/**
+ * @description: 按路径查询文件信息
+ * @param {string} filePath 文件路径
+ * @return {*} dto.File 被查询信息
+ */
+func QueryFileInfoByPath(filePath string) (*dto.File, error) {
+	var fileID int64
+	err := db.QueryRow("SELECT id FROM files WHERE path = ?;", normalizePath(filePath)).Scan(&fileID)
+	if err == sql.ErrNoRows {
+		return nil, ErrFileNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	return QueryFileInfo(fileID)
+}
+
 /**
  * @description: 文件夹路径是否存在
  * @param {string} path 文件夹路径
@@ -529,9 +1100,23 @@ func FileExistByID(fileID int64) (bool, error) {
  * @return
  */
 func UpdateFileUpdateTimeAndSize(fileID int64, fileSize int64) error {
+	file, err := QueryFileInfo(fileID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := "UPDATE files SET updated_at = NOW(), size = ? WHERE id = ?;"
-	_, err := db.Exec(query, fileSize, fileID)
-	return err
+	if _, err := tx.Exec(query, fileSize, fileID); err != nil {
+		return err
+	}
+
+	return commitWithChange(tx, Change{ItemType: "file", ItemID: fileID, Action: ChangeUpdate, Path: file.Path, ParentFolderID: file.ParentFolderID, Size: fileSize})
 }
 
 func isTableEmpty(tableName string) (bool, error) {
@@ -559,6 +1144,30 @@ func triggerExist(triggerName string, tableName string) (bool, error) {
 	return exists == 1, nil
 }
 
+func indexExist(tableName string, indexName string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?);"
+	var exists int
+
+	err := db.QueryRow(query, tableName, indexName).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists == 1, nil
+}
+
+func createIndexIfNotExist(tableName string, indexName string, columns string) error {
+	if exists, err := indexExist(tableName, indexName); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	query := fmt.Sprintf("CREATE INDEX %s ON %s (%s);", indexName, tableName, columns)
+	_, err := db.Exec(query)
+	return err
+}
+
 func idExist(id int64, tableName string) (bool, error) {
 	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = ?);", tableName)
 	var exists int
@@ -575,7 +1184,7 @@ func pathExist(path string, tableName string) (bool, error) {
 	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE path = ?);", tableName)
 	var exists int
 
-	err := db.QueryRow(query, path).Scan(&exists)
+	err := db.QueryRow(query, normalizePath(path)).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,182 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 15:03:44
+ * @LastEditTime: 2026-10-18 15:03:44
+ * @FilePath: \CloudDisk\dbwrapper\favorite.go
+ * @Description: 收藏和最近访问
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/dto"
+)
+
+// 每个用户保留的最近访问记录条数
+const maxRecentFiles = 100
+
+/**
+ * @description: 收藏文件/文件夹，已收藏时忽略
+ * @param {string} userName 用户名
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @return
+ */
+func AddFavorite(userName string, itemType string, itemID int64) error {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return err
+	}
+	if err := checkLabelItem(itemType, itemID); err != nil {
+		return err
+	}
+
+	query := "INSERT IGNORE INTO favorites (user_name, " + column + ") VALUES (?, ?);"
+	_, err = db.Exec(query, userName, itemID)
+	return err
+}
+
+/**
+ * @description: 取消收藏文件/文件夹
+ * @param {string} userName 用户名
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @return
+ */
+func RemoveFavorite(userName string, itemType string, itemID int64) error {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return err
+	}
+
+	query := "DELETE FROM favorites WHERE user_name = ? AND " + column + " = ?;"
+	_, err = db.Exec(query, userName, itemID)
+	return err
+}
+
+/**
+ * @description: 查询用户收藏的文件夹和文件，按收藏时间倒序
+ * @param {string} userName 用户名
+ * @return {*} QueryFolderResult 以虚拟文件夹形式返回的收藏列表
+ */
+func QueryFavorites(userName string) (*QueryFolderResult, error) {
+	folders := []dto.Folder{}
+	files := []dto.File{}
+
+	// 查询收藏的文件夹，根目录的parent_folder_id为NULL
+	query := "SELECT f.id, f.name, f.path, IFNULL(f.parent_folder_id, 0), f.created_at, f.updated_at FROM favorites fav " +
+		"JOIN folders f ON f.id = fav.folder_id WHERE fav.user_name = ? ORDER BY fav.created_at DESC, fav.id DESC;"
+	rowsFolder, err := db.Query(query, userName)
+	if err != nil {
+		return nil, err
+	}
+	defer rowsFolder.Close()
+
+	for rowsFolder.Next() {
+		var folder dto.Folder
+		if err := rowsFolder.Scan(&folder.ID, &folder.Name, &folder.Path, &folder.ParentFolderID, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	if err := rowsFolder.Err(); err != nil {
+		return nil, err
+	}
+
+	// 查询收藏的文件
+	query = "SELECT f.id, f.name, f.path, f.size, f.created_at, f.updated_at, f.parent_folder_id FROM favorites fav " +
+		"JOIN files f ON f.id = fav.file_id WHERE fav.user_name = ? ORDER BY fav.created_at DESC, fav.id DESC;"
+	if files, err = queryFileList(query, userName); err != nil {
+		return nil, err
+	}
+
+	// 填充标签和元数据
+	if err := fillFolderLabels(folders); err != nil {
+		return nil, err
+	}
+	if err := fillFileLabels(files); err != nil {
+		return nil, err
+	}
+
+	return &QueryFolderResult{
+		Self:    &dto.Folder{Name: "favorites"},
+		Folders: folders,
+		Files:   files,
+		Total:   int64(len(folders) + len(files)),
+	}, nil
+}
+
+/**
+ * @description: 记录用户最近访问的文件，只保留最近的若干条
+ * @param {string} userName 用户名
+ * @param {int64} fileID 文件ID
+ * @param {string} action 访问方式：upload/download
+ * @return
+ */
+func RecordRecentFile(userName string, fileID int64, action string) error {
+	query := "INSERT INTO recent_files (user_name, file_id, action, accessed_at) VALUES (?, ?, ?, NOW()) " +
+		"ON DUPLICATE KEY UPDATE action = VALUES(action), accessed_at = VALUES(accessed_at);"
+	if _, err := db.Exec(query, userName, fileID, action); err != nil {
+		return err
+	}
+
+	// 删除超出条数的旧记录，MySQL不支持在子查询中直接LIMIT被删除的表，所以多包一层
+	query = "DELETE FROM recent_files WHERE user_name = ? AND id NOT IN (" +
+		"SELECT id FROM (SELECT id FROM recent_files WHERE user_name = ? ORDER BY accessed_at DESC, id DESC LIMIT ?) AS t);"
+	_, err := db.Exec(query, userName, userName, maxRecentFiles)
+	return err
+}
+
+/**
+ * @description: 查询用户最近访问的文件，按访问时间倒序
+ * @param {string} userName 用户名
+ * @param {int} limit 条数，为0时返回全部
+ * @return {*} QueryFolderResult 以虚拟文件夹形式返回的最近访问列表
+ */
+func QueryRecentFiles(userName string, limit int) (*QueryFolderResult, error) {
+	if limit <= 0 || limit > maxRecentFiles {
+		limit = maxRecentFiles
+	}
+
+	query := "SELECT f.id, f.name, f.path, f.size, f.created_at, f.updated_at, f.parent_folder_id FROM recent_files r " +
+		"JOIN files f ON f.id = r.file_id WHERE r.user_name = ? ORDER BY r.accessed_at DESC, r.id DESC LIMIT ?;"
+	files, err := queryFileList(query, userName, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// 填充标签和元数据
+	if err := fillFileLabels(files); err != nil {
+		return nil, err
+	}
+
+	return &QueryFolderResult{
+		Self:    &dto.Folder{Name: "recent"},
+		Folders: []dto.Folder{},
+		Files:   files,
+		Total:   int64(len(files)),
+	}, nil
+}
+
+/**
+ * @description: 执行查询并读取文件列表，查询的列需与files表读取顺序一致
+ * @param {string} query 查询语句
+ * @param {...interface{}} args 查询参数
+ * @return {[]dto.File} 文件列表
+ */
+func queryFileList(query string, args ...interface{}) ([]dto.File, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []dto.File{}
+	for rows.Next() {
+		file := dto.File{Type: dto.FileTypeFile}
+		if err := rows.Scan(&file.ID, &file.Name, &file.Path, &file.Size, &file.CreatedAt, &file.UpdatedAt, &file.ParentFolderID); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
@@ -0,0 +1,365 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 13:48:10
+ * @LastEditTime: 2026-10-19 09:46:52
+ * @FilePath: \CloudDisk\dbwrapper\label.go
+ * @Description: 文件/文件夹的标签和自定义元数据
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/dto"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidItemType = errors.New("invalid item type, must be file or folder")
+
+// 标签统计
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+/**
+ * @description: 根据条目类型获取标签和元数据表中的关联列名
+ * @param {string} itemType 条目类型：file/folder
+ * @return {string} 列名
+ */
+func labelColumn(itemType string) (string, error) {
+	switch itemType {
+	case "file":
+		return "file_id", nil
+	case "folder":
+		return "folder_id", nil
+	default:
+		return "", ErrInvalidItemType
+	}
+}
+
+/**
+ * @description: 检查条目是否存在
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @return
+ */
+func checkLabelItem(itemType string, itemID int64) error {
+	var (
+		exists      bool
+		err         error
+		errNotExist error
+	)
+	if itemType == "file" {
+		exists, err = FileExistByID(itemID)
+		errNotExist = ErrFileNotExist
+	} else {
+		exists, err = FolderExistByID(itemID)
+		errNotExist = ErrFolderNotExist
+	}
+	if err != nil {
+		return err
+	} else if !exists {
+		return errNotExist
+	}
+	return nil
+}
+
+/**
+ * @description: 给文件/文件夹添加标签，已有的标签忽略
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @param {[]string} tags 标签
+ * @return
+ */
+func AddTags(itemType string, itemID int64, tags []string) error {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return err
+	}
+	if err := checkLabelItem(itemType, itemID); err != nil {
+		return err
+	}
+
+	query := "INSERT IGNORE INTO tags (name, " + column + ") VALUES (?, ?);"
+	for _, tag := range tags {
+		if _, err := db.Exec(query, tag, itemID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * @description: 删除文件/文件夹的标签
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @param {[]string} tags 标签
+ * @return
+ */
+func RemoveTags(itemType string, itemID int64, tags []string) error {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := "DELETE FROM tags WHERE " + column + " = ? AND name IN (" + placeholders(len(tags)) + ");"
+	args := []interface{}{itemID}
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	_, err = db.Exec(query, args...)
+	return err
+}
+
+/**
+ * @description: 查询所有标签及使用次数
+ * @return {[]TagCount} 标签列表
+ */
+func QueryAllTags() ([]TagCount, error) {
+	rows, err := db.Query("SELECT name, COUNT(*) FROM tags GROUP BY name ORDER BY name;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+/**
+ * @description: 设置文件/文件夹的元数据，已存在的键覆盖
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @param {map[string]string} metadata 元数据
+ * @return
+ */
+func SetMetadata(itemType string, itemID int64, metadata map[string]string) error {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return err
+	}
+	if err := checkLabelItem(itemType, itemID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO metadata (meta_key, meta_value, " + column + ") VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE meta_value = VALUES(meta_value);"
+	for key, value := range metadata {
+		if _, err := db.Exec(query, key, value, itemID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * @description: 删除文件/文件夹的元数据
+ * @param {string} itemType 条目类型：file/folder
+ * @param {int64} itemID 条目ID
+ * @param {[]string} keys 元数据键
+ * @return
+ */
+func DeleteMetadata(itemType string, itemID int64, keys []string) error {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	query := "DELETE FROM metadata WHERE " + column + " = ? AND meta_key IN (" + placeholders(len(keys)) + ");"
+	args := []interface{}{itemID}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	_, err = db.Exec(query, args...)
+	return err
+}
+
+/**
+ * @description: 批量查询条目的标签和元数据
+ * @param {string} itemType 条目类型：file/folder
+ * @param {[]int64} ids 条目ID
+ * @return {map[int64][]string} 标签
+ * @return {map[int64]map[string]string} 元数据
+ */
+func queryLabels(itemType string, ids []int64) (map[int64][]string, map[int64]map[string]string, error) {
+	tags := map[int64][]string{}
+	metadata := map[int64]map[string]string{}
+	if len(ids) == 0 {
+		return tags, metadata, nil
+	}
+
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return nil, nil, err
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	// 查询标签
+	query := "SELECT " + column + ", name FROM tags WHERE " + column + " IN (" + placeholders(len(ids)) + ") ORDER BY name;"
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, nil, err
+		}
+		tags[id] = append(tags[id], name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// 查询元数据
+	query = "SELECT " + column + ", meta_key, meta_value FROM metadata WHERE " + column + " IN (" + placeholders(len(ids)) + ");"
+	rowsMeta, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rowsMeta.Close()
+	for rowsMeta.Next() {
+		var (
+			id         int64
+			key, value string
+		)
+		if err := rowsMeta.Scan(&id, &key, &value); err != nil {
+			return nil, nil, err
+		}
+		if metadata[id] == nil {
+			metadata[id] = map[string]string{}
+		}
+		metadata[id][key] = value
+	}
+
+	return tags, metadata, rowsMeta.Err()
+}
+
+/**
+ * @description: 填充文件夹列表的标签和元数据
+ * @param {[]dto.Folder} folders 文件夹列表
+ * @return
+ */
+func fillFolderLabels(folders []dto.Folder) error {
+	ids := make([]int64, len(folders))
+	for i := range folders {
+		ids[i] = folders[i].ID
+	}
+
+	tags, metadata, err := queryLabels("folder", ids)
+	if err != nil {
+		return err
+	}
+	for i := range folders {
+		folders[i].Tags = tags[folders[i].ID]
+		folders[i].Metadata = metadata[folders[i].ID]
+	}
+	return nil
+}
+
+/**
+ * @description: 填充文件列表的标签、元数据和MIME类型，列表中的文件夹条目按文件夹查询
+ * @param {[]dto.File} files 文件列表
+ * @return
+ */
+func fillFileLabels(files []dto.File) error {
+	var fileIDs, folderIDs []int64
+	for i := range files {
+		if files[i].Type == dto.FileTypeFolder {
+			folderIDs = append(folderIDs, files[i].ID)
+		} else {
+			fileIDs = append(fileIDs, files[i].ID)
+		}
+	}
+
+	fileTags, fileMetadata, err := queryLabels("file", fileIDs)
+	if err != nil {
+		return err
+	}
+	folderTags, folderMetadata, err := queryLabels("folder", folderIDs)
+	if err != nil {
+		return err
+	}
+	mimeTypes, err := queryMimeTypes(fileIDs)
+	if err != nil {
+		return err
+	}
+	for i := range files {
+		if files[i].Type == dto.FileTypeFolder {
+			files[i].Tags = folderTags[files[i].ID]
+			files[i].Metadata = folderMetadata[files[i].ID]
+		} else {
+			files[i].Tags = fileTags[files[i].ID]
+			files[i].Metadata = fileMetadata[files[i].ID]
+			files[i].MimeType = mimeTypes[files[i].ID]
+		}
+	}
+	return nil
+}
+
+/**
+ * @description: 生成按标签和元数据过滤的条件，条目需包含全部指定标签并匹配全部元数据
+ * @param {string} itemType 条目类型：file/folder
+ * @param {string} idExpr 条目ID表达式
+ * @param {[]string} tags 标签
+ * @param {map[string]string} metadata 元数据
+ * @return {[]string} 过滤条件
+ * @return {[]interface{}} 过滤条件参数
+ */
+func labelFilterConds(itemType string, idExpr string, tags []string, metadata map[string]string) ([]string, []interface{}) {
+	column, err := labelColumn(itemType)
+	if err != nil {
+		return nil, nil
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, tag := range tags {
+		conds = append(conds, "EXISTS (SELECT 1 FROM tags WHERE tags."+column+" = "+idExpr+" AND tags.name = ?)")
+		args = append(args, tag)
+	}
+
+	// 按键排序，保证生成的语句稳定
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		conds = append(conds, "EXISTS (SELECT 1 FROM metadata WHERE metadata."+column+" = "+idExpr+" AND metadata.meta_key = ? AND metadata.meta_value = ?)")
+		args = append(args, key, metadata[key])
+	}
+
+	return conds, args
+}
+
+/**
+ * @description: 生成IN语句的占位符
+ * @param {int} n 占位符个数
+ * @return {string} 占位符
+ */
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,171 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 13:05:19
+ * @LastEditTime: 2026-10-19 10:52:13
+ * @FilePath: \CloudDisk\dbwrapper\listing.go
+ * @Description: 文件夹列表的排序与游标分页
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/dto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidSortField = errors.New("invalid sort field")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+)
+
+// 文件夹列表查询条件
+type ListOptions struct {
+	SortBy string // 排序字段：name/size/createdAt/updatedAt/type
+	Desc   bool   // 是否降序
+	Cursor string // 上一页返回的游标，为空时从头开始
+	Limit  int    // 每页条数，为0时返回全部
+
+	Tags     []string          // 只返回包含全部标签的条目
+	Metadata map[string]string // 只返回匹配全部元数据的条目
+}
+
+// 分页游标，记录上一页最后一项的排序键
+type listCursor struct {
+	Kind  int             `json:"k"`
+	Key   json.RawMessage `json:"v"`
+	ID    int64           `json:"i"`
+	Sort  string          `json:"s"`
+	Order bool            `json:"d"`
+}
+
+// 排序字段对应的排序表达式，folders和files合并后的列
+var listSortExprs = map[string]string{
+	"":          "name",
+	"name":      "name",
+	"size":      "size",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+	"type":      "LOWER(IF(LOCATE('.', name) > 0, SUBSTRING_INDEX(name, '.', -1), ''))",
+}
+
+/**
+ * @description: 生成列表查询的排序和游标条件
+ * @param {ListOptions} opts 查询条件
+ * @return {string} 排序表达式
+ * @return {string} 游标过滤条件，没有游标时为空
+ * @return {[]interface{}} 游标过滤条件参数
+ * @return {string} ORDER BY子句
+ */
+func buildListOrder(opts ListOptions) (string, string, []interface{}, string, error) {
+	sortExpr, ok := listSortExprs[opts.SortBy]
+	if !ok {
+		return "", "", nil, "", ErrInvalidSortField
+	}
+
+	// 默认文件夹在前，按类型排序时文件夹和文件的先后也随排序方向变化
+	dir, cmp := "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	kindDir, kindCmp := "ASC", ">"
+	if opts.SortBy == "type" {
+		kindDir, kindCmp = dir, cmp
+	}
+	orderBy := "ORDER BY kind " + kindDir + ", " + sortExpr + " " + dir + ", id " + dir
+
+	if opts.Cursor == "" {
+		return sortExpr, "", nil, orderBy, nil
+	}
+
+	// 解析游标，游标只能用于生成它的排序方式
+	raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return "", "", nil, "", ErrInvalidCursor
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return "", "", nil, "", ErrInvalidCursor
+	}
+	if cursor.Sort != opts.SortBy || cursor.Order != opts.Desc {
+		return "", "", nil, "", fmt.Errorf("%w: does not match sort order", ErrInvalidCursor)
+	}
+
+	var key interface{}
+	switch opts.SortBy {
+	case "size":
+		var size int64
+		err = json.Unmarshal(cursor.Key, &size)
+		key = size
+	case "createdAt", "updatedAt":
+		var t time.Time
+		err = json.Unmarshal(cursor.Key, &t)
+		key = t
+	default:
+		var s string
+		err = json.Unmarshal(cursor.Key, &s)
+		key = s
+	}
+	if err != nil {
+		return "", "", nil, "", ErrInvalidCursor
+	}
+
+	cond := "(kind " + kindCmp + " ? OR (kind = ? AND (" + sortExpr + " " + cmp + " ? OR (" + sortExpr + " = ? AND id " + cmp + " ?))))"
+	args := []interface{}{cursor.Kind, cursor.Kind, key, key, cursor.ID}
+	return sortExpr, cond, args, orderBy, nil
+}
+
+/**
+ * @description: 根据最后一项生成下一页游标
+ * @param {ListOptions} opts 查询条件
+ * @param {int} kind 最后一项的类型
+ * @param {interface{}} key 最后一项的排序键
+ * @param {int64} id 最后一项的ID
+ * @return {string} 游标
+ */
+func encodeListCursor(opts ListOptions, kind int, key interface{}, id int64) string {
+	keyJSON, _ := json.Marshal(key)
+	raw, _ := json.Marshal(listCursor{Kind: kind, Key: keyJSON, ID: id, Sort: opts.SortBy, Order: opts.Desc})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+/**
+ * @description: 查询路径以指定前缀开头的全部文件夹和文件，按路径排序
+ * @param {string} pathPrefix 路径前缀
+ * @return {[]dto.Folder} 文件夹列表
+ * @return {[]dto.File} 文件列表
+ */
+func QueryByPathPrefix(pathPrefix string) ([]dto.Folder, []dto.File, error) {
+	pattern := escapeLike(normalizePath(pathPrefix)) + "%"
+
+	// 查询文件夹，根目录的parent_folder_id为NULL
+	query := "SELECT id, name, path, IFNULL(parent_folder_id, 0), created_at, updated_at FROM folders WHERE path LIKE ? ORDER BY path;"
+	rows, err := db.Query(query, pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	folders := []dto.Folder{}
+	for rows.Next() {
+		var folder dto.Folder
+		if err := rows.Scan(&folder.ID, &folder.Name, &folder.Path, &folder.ParentFolderID, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
+			return nil, nil, err
+		}
+		folders = append(folders, folder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// 查询文件
+	query = "SELECT id, name, path, size, created_at, updated_at, parent_folder_id FROM files WHERE path LIKE ? ORDER BY path;"
+	files, err := queryFileList(query, pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return folders, files, nil
+}
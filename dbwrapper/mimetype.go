@@ -0,0 +1,68 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 00:48:52
+ * @LastEditTime: 2026-10-19 00:48:52
+ * @FilePath: \CloudDisk\dbwrapper\mimetype.go
+ * @Description: 文件的MIME类型
+ */
+package dbwrapper
+
+import "database/sql"
+
+/**
+ * @description: 保存文件的MIME类型，已存在则覆盖
+ * @param {int64} fileID 文件ID
+ * @param {string} mimeType MIME类型
+ * @return
+ */
+func SaveFileMimeType(fileID int64, mimeType string) error {
+	query := "INSERT INTO file_mime_types (file_id, mime_type) VALUES (?, ?) ON DUPLICATE KEY UPDATE mime_type = VALUES(mime_type);"
+	_, err := db.Exec(query, fileID, mimeType)
+	return err
+}
+
+/**
+ * @description: 查询文件的MIME类型
+ * @param {int64} fileID 文件ID
+ * @return {string} MIME类型，尚未识别时为空
+ */
+func QueryFileMimeType(fileID int64) (string, error) {
+	var mimeType string
+	err := db.QueryRow("SELECT mime_type FROM file_mime_types WHERE file_id = ?;", fileID).Scan(&mimeType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return mimeType, err
+}
+
+/**
+ * @description: 批量查询文件的MIME类型
+ * @param {[]int64} fileIDs 文件ID列表
+ * @return {map[int64]string} 文件ID -> MIME类型，尚未识别的文件不在其中
+ */
+func queryMimeTypes(fileIDs []int64) (map[int64]string, error) {
+	mimeTypes := make(map[int64]string)
+	if len(fileIDs) == 0 {
+		return mimeTypes, nil
+	}
+
+	args := make([]interface{}, len(fileIDs))
+	for i, id := range fileIDs {
+		args[i] = id
+	}
+	rows, err := db.Query("SELECT file_id, mime_type FROM file_mime_types WHERE file_id IN ("+placeholders(len(fileIDs))+");", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID int64
+		var mimeType string
+		if err := rows.Scan(&fileID, &mimeType); err != nil {
+			return nil, err
+		}
+		mimeTypes[fileID] = mimeType
+	}
+	return mimeTypes, rows.Err()
+}
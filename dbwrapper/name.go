@@ -0,0 +1,458 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 03:34:18
+ * @LastEditTime: 2026-10-19 17:05:36
+ * @FilePath: \CloudDisk\dbwrapper\name.go
+ * @Description: 名称唯一性策略，同一文件夹下规范化名称相同的文件或文件夹只能有一个
+ * 同类条目之间由各表的唯一索引保证，文件与文件夹之间由触发器维护的names表的主键保证
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/configwrapper"
+	"CloudDisk/logwrapper"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 名称唯一性策略
+const (
+	NameUniquenessCaseInsensitive = "case-insensitive" // NFC规范化并忽略大小写，与Windows和macOS的默认文件系统一致
+	NameUniquenessExact           = "exact"            // NFC规范化后区分大小写，只适用于区分大小写的文件系统
+)
+
+// 记录当前normalized_name列按哪种策略计算
+const nameUniquenessSetting = "name_uniqueness"
+
+// 路径列的定义，使用二进制排序规则，使按路径查找和按前缀替换与normalized_name唯一索引一致
+// 否则exact策略下只有大小写不同的两个路径会互相匹配
+const pathColumnDefinition = "VARCHAR(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL"
+
+// 名称唯一索引
+var nameUniqueIndexes = map[string]string{
+	"folders": "uk_folders_parent_name",
+	"files":   "uk_files_parent_name",
+}
+
+// 文件和文件夹共用的名称表，每个非根条目一行，主键保证同一文件夹下的文件和文件夹不重名
+// 插入和修改由folders、files表上的触发器在同一事务中完成，违反主键时原语句失败并返回重复键错误
+// 删除由外键级联完成，级联删除不触发触发器，因此不使用删除触发器
+const createTabName = `
+CREATE TABLE IF NOT EXISTS names (
+	parent_folder_id BIGINT NOT NULL,      -- 父文件夹ID
+	normalized_name VARCHAR(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,  -- 规范化名称
+	folder_id BIGINT NULL,                 -- 文件夹ID，条目为文件时为NULL
+	file_id BIGINT NULL,                   -- 文件ID，条目为文件夹时为NULL
+	PRIMARY KEY (parent_folder_id, normalized_name),
+	UNIQUE KEY uk_names_folder (folder_id),
+	UNIQUE KEY uk_names_file (file_id),
+	CONSTRAINT fk_names_folder FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE,
+	CONSTRAINT fk_names_file FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+);
+`
+
+// 维护names表的触发器，根目录没有父文件夹，不占用名称
+// 只有父文件夹或规范化名称改变时才更新，移动文件夹时替换子孙路径前缀的语句不修改names表
+var nameTriggers = []struct {
+	triggerName string
+	tableName   string
+	body        string
+}{
+	{"names_folder_insert", "folders", `AFTER INSERT ON folders FOR EACH ROW
+		BEGIN
+			IF NEW.parent_folder_id IS NOT NULL THEN
+				INSERT INTO names (parent_folder_id, normalized_name, folder_id) VALUES (NEW.parent_folder_id, NEW.normalized_name, NEW.id);
+			END IF;
+		END`},
+	{"names_folder_update", "folders", `AFTER UPDATE ON folders FOR EACH ROW
+		BEGIN
+			IF NOT (NEW.parent_folder_id <=> OLD.parent_folder_id AND NEW.normalized_name <=> OLD.normalized_name) THEN
+				UPDATE names SET parent_folder_id = NEW.parent_folder_id, normalized_name = NEW.normalized_name WHERE folder_id = NEW.id;
+			END IF;
+		END`},
+	{"names_file_insert", "files", `AFTER INSERT ON files FOR EACH ROW
+		BEGIN
+			INSERT INTO names (parent_folder_id, normalized_name, file_id) VALUES (NEW.parent_folder_id, NEW.normalized_name, NEW.id);
+		END`},
+	{"names_file_update", "files", `AFTER UPDATE ON files FOR EACH ROW
+		BEGIN
+			IF NOT (NEW.parent_folder_id <=> OLD.parent_folder_id AND NEW.normalized_name <=> OLD.normalized_name) THEN
+				UPDATE names SET parent_folder_id = NEW.parent_folder_id, normalized_name = NEW.normalized_name WHERE file_id = NEW.id;
+			END IF;
+		END`},
+}
+
+/**
+ * @description: 获取配置的名称唯一性策略
+ * @return {string}
+ */
+func nameUniqueness() string {
+	if configwrapper.Cfg.Local.NameUniqueness == NameUniquenessExact {
+		return NameUniquenessExact
+	}
+	return NameUniquenessCaseInsensitive
+}
+
+/**
+ * @description: 按唯一性策略计算规范化名称，规范化名称相同的视为同一名称
+ * @param {string} name 名称
+ * @return {string}
+ */
+func NormalizeName(name string) string {
+	name = norm.NFC.String(name)
+	if nameUniqueness() == NameUniquenessCaseInsensitive {
+		name = cases.Fold().String(name)
+	}
+	return name
+}
+
+/**
+ * @description: 规范化查找用的路径，名称保存时已转为NFC，macOS等客户端发送NFD形式的路径时也能找到对应条目
+ * @param {string} p 路径
+ * @return {string}
+ */
+func normalizePath(p string) string {
+	return norm.NFC.String(p)
+}
+
+/**
+ * @description: 判断是否为违反唯一索引的错误
+ * @param {error} err
+ * @return {bool}
+ */
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+/**
+ * @description: 按名称查询文件夹下的子文件夹，按唯一性策略比较
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} name 名称
+ * @param {int64} excludeID 不参与比较的文件夹ID，重命名时为文件夹自身，新建时为0
+ * @return {int64} 文件夹ID，不存在时为0
+ */
+func QueryFolderIDByName(parentFolderID int64, name string, excludeID int64) (int64, error) {
+	return queryIDByName("folders", parentFolderID, name, excludeID)
+}
+
+/**
+ * @description: 按名称查询文件夹下的文件，按唯一性策略比较
+ * @param {int64} parentFolderID 父文件夹ID
+ * @param {string} name 名称
+ * @param {int64} excludeID 不参与比较的文件ID，重命名时为文件自身，新建时为0
+ * @return {int64} 文件ID，不存在时为0
+ */
+func QueryFileIDByName(parentFolderID int64, name string, excludeID int64) (int64, error) {
+	return queryIDByName("files", parentFolderID, name, excludeID)
+}
+
+func queryIDByName(tableName string, parentFolderID int64, name string, excludeID int64) (int64, error) {
+	query := fmt.Sprintf("SELECT id FROM %s WHERE parent_folder_id = ? AND normalized_name = ? AND id <> ?;", tableName)
+	var id int64
+
+	err := db.QueryRow(query, parentFolderID, NormalizeName(name), excludeID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+/**
+ * @description: 迁移normalized_name列、唯一索引和names表，唯一性策略改变时按新策略重新计算
+ * 已有数据按新策略存在重名时无法建立唯一索引或填充names表，返回的错误中列出重名的路径
+ * @return
+ */
+func migrateNormalizedNames() error {
+	policy := nameUniqueness()
+	var stored string
+	err := db.QueryRow("SELECT value FROM settings WHERE name = ?;", nameUniquenessSetting).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// 新建names表或策略改变时重新填充names表
+	// 策略改变时先清空，使重新计算规范化名称时更新触发器不会因为旧的名称互相冲突
+	namesExist, err := tableExist("names")
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(createTabName); err != nil {
+		return err
+	}
+	if stored != policy {
+		if _, err := db.Exec("DELETE FROM names;"); err != nil {
+			return err
+		}
+	}
+
+	for tableName, indexName := range nameUniqueIndexes {
+		// 大小写折叠后可能变长，使用二进制排序规则，避免数据库按自己的规则忽略大小写和重音
+		if err := addColumnIfNotExist(tableName, "normalized_name", "VARCHAR(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL"); err != nil {
+			return err
+		}
+
+		if stored != policy {
+			logwrapper.Logger.Infof("Recomputing normalized names of %s with policy %s", tableName, policy)
+			if err := dropIndexIfExist(tableName, indexName); err != nil {
+				return err
+			}
+			if err := recomputeNormalizedNames(tableName); err != nil {
+				return err
+			}
+		}
+
+		if exists, err := indexExist(tableName, indexName); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+		query := fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (parent_folder_id, normalized_name);", indexName, tableName)
+		if _, err := db.Exec(query); isDuplicateEntry(err) {
+			return fmt.Errorf("names conflict under policy %s, rename them or set local.nameUniqueness to %s: %s",
+				policy, NameUniquenessExact, strings.Join(queryDuplicateNames(tableName), ", "))
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range nameTriggers {
+		if err := createTriggerIfNotExist(t.triggerName, t.tableName, t.body); err != nil {
+			return err
+		}
+	}
+	if !namesExist || stored != policy {
+		if err := fillNames(); err != nil {
+			return err
+		}
+	}
+
+	if stored == policy {
+		return nil
+	}
+	query := "INSERT INTO settings (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value);"
+	_, err = db.Exec(query, nameUniquenessSetting, policy)
+	return err
+}
+
+/**
+ * @description: 将旧版本建立的路径列改为二进制排序规则
+ * @return
+ */
+func migratePathCollation() error {
+	for tableName := range nameUniqueIndexes {
+		var collation string
+		query := "SELECT IFNULL(collation_name, '') FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = 'path';"
+		if err := db.QueryRow(query, tableName).Scan(&collation); err != nil {
+			return err
+		}
+		if collation == "utf8mb4_bin" {
+			continue
+		}
+
+		logwrapper.Logger.Infof("Changing collation of %s.path from %s to utf8mb4_bin", tableName, collation)
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN path %s;", tableName, pathColumnDefinition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * @description: 按当前策略重新计算全部规范化名称
+ * @param {string} tableName 表名
+ * @return
+ */
+func recomputeNormalizedNames(tableName string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT id, name FROM %s;", tableName))
+	if err != nil {
+		return err
+	}
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("UPDATE %s SET normalized_name = ? WHERE id = ?;", tableName))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, name := range names {
+		if _, err := stmt.Exec(NormalizeName(name), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/**
+ * @description: 按已有的文件和文件夹重新填充names表
+ * 同一文件夹下存在规范化名称相同的文件和文件夹时失败，返回的错误中列出重名的路径
+ * @return
+ */
+func fillNames() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"DELETE FROM names;",
+		"INSERT INTO names (parent_folder_id, normalized_name, folder_id) SELECT parent_folder_id, normalized_name, id FROM folders WHERE parent_folder_id IS NOT NULL;",
+		"INSERT INTO names (parent_folder_id, normalized_name, file_id) SELECT parent_folder_id, normalized_name, id FROM files;",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); isDuplicateEntry(err) {
+			return fmt.Errorf("files and folders with the same name under policy %s, rename them: %s",
+				nameUniqueness(), strings.Join(queryFileFolderDuplicateNames(), ", "))
+		} else if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/**
+ * @description: 查询同一文件夹下与文件夹规范化名称相同的文件路径，用于迁移失败时提示
+ * @return {[]string} 路径，最多列出100个
+ */
+func queryFileFolderDuplicateNames() []string {
+	query := `
+		SELECT f.path FROM files f
+		JOIN folders d ON f.parent_folder_id = d.parent_folder_id AND f.normalized_name = d.normalized_name
+		ORDER BY f.path LIMIT 100;`
+	rows, err := db.Query(query)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			break
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+/**
+ * @description: 查询规范化名称重复的路径，用于迁移失败时提示
+ * @param {string} tableName 表名
+ * @return {[]string} 路径，最多列出100个
+ */
+func queryDuplicateNames(tableName string) []string {
+	query := fmt.Sprintf(`
+		SELECT t.path FROM %[1]s t
+		JOIN (SELECT parent_folder_id, normalized_name FROM %[1]s GROUP BY parent_folder_id, normalized_name HAVING COUNT(*) > 1) d
+		ON t.parent_folder_id = d.parent_folder_id AND t.normalized_name = d.normalized_name
+		ORDER BY t.path LIMIT 100;`, tableName)
+	rows, err := db.Query(query)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			break
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func tableExist(tableName string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?);"
+	var exists int
+
+	err := db.QueryRow(query, tableName).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists == 1, nil
+}
+
+/**
+ * @description: 触发器不存在时创建，与protect_root_delete一样指定DEFINER
+ * @param {string} triggerName 触发器名
+ * @param {string} tableName 表名
+ * @param {string} body 触发时机、表和触发器主体
+ * @return
+ */
+func createTriggerIfNotExist(triggerName string, tableName string, body string) error {
+	if exists, err := triggerExist(triggerName, tableName); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	definerClause := fmt.Sprintf("DEFINER=`%s`@`%s`", configwrapper.Cfg.Database.User, "localhost")
+	_, err := db.Exec(fmt.Sprintf("CREATE %s TRIGGER %s %s", definerClause, triggerName, body))
+	return err
+}
+
+func columnExist(tableName string, columnName string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?);"
+	var exists int
+
+	err := db.QueryRow(query, tableName, columnName).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists == 1, nil
+}
+
+func addColumnIfNotExist(tableName string, columnName string, definition string) error {
+	if exists, err := columnExist(tableName, columnName); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", tableName, columnName, definition)
+	_, err := db.Exec(query)
+	return err
+}
+
+func dropIndexIfExist(tableName string, indexName string) error {
+	if exists, err := indexExist(tableName, indexName); err != nil {
+		return err
+	} else if !exists {
+		return nil
+	}
+
+	query := fmt.Sprintf("DROP INDEX %s ON %s;", indexName, tableName)
+	_, err := db.Exec(query)
+	return err
+}
@@ -0,0 +1,63 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 19:20:08
+ * @LastEditTime: 2026-10-19 19:20:08
+ * @FilePath: \CloudDisk\dbwrapper\name_test.go
+ * @Description: 名称唯一性策略测试：两种策略下哪些名称视为同一名称，不需要数据库
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/configwrapper"
+	"testing"
+)
+
+/**
+ * @description: 测试期间使用指定的名称唯一性策略
+ * @param {*testing.T} t
+ * @param {string} policy 唯一性策略，为空时使用默认策略
+ * @return {*}
+ */
+func useNameUniqueness(t *testing.T, policy string) {
+	t.Helper()
+	previous := configwrapper.Cfg
+	configwrapper.Cfg = &configwrapper.Config{}
+	configwrapper.Cfg.Local.NameUniqueness = policy
+	t.Cleanup(func() { configwrapper.Cfg = previous })
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		exact  bool // exact策略下是否视为同一名称
+		folded bool // case-insensitive策略下是否视为同一名称
+	}{
+		{"report.pdf", "report.pdf", true, true},
+		{"Report.pdf", "report.pdf", false, true},
+		{"REPORT.PDF", "report.pdf", false, true},
+		// NFC和NFD形式的"é"
+		{"caf\u00e9", "cafe\u0301", true, true},
+		{"CAFE\u0301", "caf\u00e9", false, true},
+		// 大小写折叠不只是转小写
+		{"Straße", "STRASSE", false, true},
+		{"ΣΊΣΥΦΟΣ", "σίσυφος", false, true},
+		{"report.pdf", "report.pdf ", false, false},
+		{"a", "b", false, false},
+	}
+
+	// 未配置时使用case-insensitive策略
+	for name, policy := range map[string]string{"exact": NameUniquenessExact, "case-insensitive": NameUniquenessCaseInsensitive, "default": ""} {
+		t.Run(name, func(t *testing.T) {
+			useNameUniqueness(t, policy)
+			for _, tt := range tests {
+				want := tt.folded
+				if policy == NameUniquenessExact {
+					want = tt.exact
+				}
+				if got := NormalizeName(tt.a) == NormalizeName(tt.b); got != want {
+					t.Errorf("%q and %q same name = %v, want %v", tt.a, tt.b, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 10:12:31
+ * @LastEditTime: 2026-10-19 10:34:26
+ * @FilePath: \CloudDisk\dbwrapper\search.go
+ * @Description: 文件/文件夹搜索
+ */
+package dbwrapper
+
+import (
+	"CloudDisk/dto"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// 搜索条件
+type SearchOptions struct {
+	Keyword        string            // 搜索关键字
+	Glob           bool              // 关键字是否为通配符模式(*和?)，否则为子串匹配
+	MatchPath      bool              // 是否匹配完整路径，否则只匹配名称
+	Type           string            // 类型过滤：file/folder，为空时两者都搜索
+	MinSize        *int64            // 最小文件大小
+	MaxSize        *int64            // 最大文件大小
+	ModifiedAfter  *time.Time        // 修改时间下限
+	ModifiedBefore *time.Time        // 修改时间上限
+	RootFolderID   int64             // 搜索的子树根目录ID
+	Tags           []string          // 只返回包含全部标签的条目
+	Metadata       map[string]string // 只返回匹配全部元数据的条目
+	SortBy         string            // 排序字段：name/path/size/createdAt/updatedAt
+	Desc           bool              // 是否降序
+	Page           int               // 页码，从1开始
+	PageSize       int               // 每页条数
+}
+
+type SearchResult struct {
+	Total int64      `json:"total"`
+	Items []dto.File `json:"items"`
+}
+
+// 排序字段与列名的映射
+var searchSortColumns = map[string]string{
+	"":          "name",
+	"name":      "name",
+	"path":      "path",
+	"size":      "size",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+/**
+ * @description: 在指定子树下按名称或路径搜索文件和文件夹
+ * @param {SearchOptions} opts 搜索条件
+ * @return {*} SearchResult 搜索结果
+ */
+func Search(opts SearchOptions) (*SearchResult, error) {
+	sortColumn, ok := searchSortColumns[opts.SortBy]
+	if !ok {
+		return nil, ErrInvalidSortField
+	}
+
+	// 查询子树根目录路径
+	rootPath, err := QueryFolderPath(opts.RootFolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 公共过滤条件
+	var (
+		conds []string
+		args  []interface{}
+	)
+	subtreePrefix := strings.TrimSuffix(rootPath, "/") + "/"
+	conds = append(conds, "path LIKE ?")
+	args = append(args, escapeLike(subtreePrefix)+"%")
+
+	if opts.Keyword != "" {
+		pattern := "%" + escapeLike(opts.Keyword) + "%"
+		if opts.Glob {
+			pattern = globToLike(opts.Keyword)
+		}
+
+		// path列区分大小写，关键字匹配时转为小写比较
+		if opts.MatchPath {
+			conds = append(conds, "LOWER(path) LIKE ?")
+			args = append(args, strings.ToLower(pattern))
+		} else {
+			conds = append(conds, "name LIKE ?")
+			args = append(args, pattern)
+		}
+	}
+	if opts.ModifiedAfter != nil {
+		conds = append(conds, "updated_at >= ?")
+		args = append(args, *opts.ModifiedAfter)
+	}
+	if opts.ModifiedBefore != nil {
+		conds = append(conds, "updated_at <= ?")
+		args = append(args, *opts.ModifiedBefore)
+	}
+
+	// 子树根目录自身不作为搜索结果
+	folderConds := append([]string{}, conds...)
+	folderArgs := append([]interface{}{}, args...)
+	folderConds = append(folderConds, "id <> ?")
+	folderArgs = append(folderArgs, opts.RootFolderID)
+
+	// 按标签和元数据过滤
+	labelConds, labelArgs := labelFilterConds("folder", "folders.id", opts.Tags, opts.Metadata)
+	folderConds = append(folderConds, labelConds...)
+	folderArgs = append(folderArgs, labelArgs...)
+
+	fileConds := append([]string{}, conds...)
+	fileArgs := append([]interface{}{}, args...)
+	labelConds, labelArgs = labelFilterConds("file", "files.id", opts.Tags, opts.Metadata)
+	fileConds = append(fileConds, labelConds...)
+	fileArgs = append(fileArgs, labelArgs...)
+
+	// 文件额外的大小过滤条件
+	if opts.MinSize != nil {
+		fileConds = append(fileConds, "size >= ?")
+		fileArgs = append(fileArgs, *opts.MinSize)
+	}
+	if opts.MaxSize != nil {
+		fileConds = append(fileConds, "size <= ?")
+		fileArgs = append(fileArgs, *opts.MaxSize)
+	}
+
+	// 按类型拼接子查询，指定了大小范围时文件夹不参与搜索
+	searchFolders := opts.Type != "file" && opts.MinSize == nil && opts.MaxSize == nil
+	searchFiles := opts.Type != "folder"
+	var (
+		subQueries []string
+		subArgs    []interface{}
+	)
+	if searchFolders {
+		subQueries = append(subQueries, "SELECT 0 AS kind, id, name, path, 0 AS size, parent_folder_id, created_at, updated_at FROM folders WHERE "+strings.Join(folderConds, " AND "))
+		subArgs = append(subArgs, folderArgs...)
+	}
+	if searchFiles {
+		subQueries = append(subQueries, "SELECT 1 AS kind, id, name, path, size, parent_folder_id, created_at, updated_at FROM files WHERE "+strings.Join(fileConds, " AND "))
+		subArgs = append(subArgs, fileArgs...)
+	}
+	if len(subQueries) == 0 {
+		return &SearchResult{Items: []dto.File{}}, nil
+	}
+	union := strings.Join(subQueries, " UNION ALL ")
+
+	// 查询总数
+	var total int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM ("+union+") AS t;", subArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	// 分页参数
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PageSize < 1 || opts.PageSize > 1000 {
+		opts.PageSize = 100
+	}
+	direction := "ASC"
+	if opts.Desc {
+		direction = "DESC"
+	}
+
+	query := "SELECT kind, id, name, path, size, parent_folder_id, created_at, updated_at FROM (" + union + ") AS t " +
+		"ORDER BY " + sortColumn + " " + direction + ", kind, id LIMIT ? OFFSET ?;"
+	rows, err := db.Query(query, append(subArgs, opts.PageSize, (opts.Page-1)*opts.PageSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []dto.File{}
+	for rows.Next() {
+		var (
+			item           dto.File
+			parentFolderID sql.NullInt64
+		)
+		if err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.Path, &item.Size, &parentFolderID, &item.CreatedAt, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		item.ParentFolderID = parentFolderID.Int64
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 填充标签和元数据
+	if err := fillFileLabels(items); err != nil {
+		return nil, err
+	}
+
+	return &SearchResult{Total: total, Items: items}, nil
+}
+
+/**
+ * @description: 转义LIKE语句中的特殊字符
+ * @param {string} s 原始字符串
+ * @return {string} 转义后的字符串
+ */
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
+
+/**
+ * @description: 将通配符模式转换为LIKE模式，*匹配任意字符串，?匹配单个字符
+ * @param {string} glob 通配符模式
+ * @return {string} LIKE模式
+ */
+func globToLike(glob string) string {
+	var sb strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteByte('%')
+		case '?':
+			sb.WriteByte('_')
+		default:
+			sb.WriteString(escapeLike(string(r)))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,279 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 23:26:04
+ * @LastEditTime: 2026-10-19 12:27:40
+ * @FilePath: \CloudDisk\dbwrapper\webhook.go
+ * @Description: Webhook订阅及投递记录
+ */
+package dbwrapper
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrWebhookNotExist = errors.New("webhook does not exist")
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"   // 等待投递或重试
+	DeliverySucceeded = "succeeded" // 接收方返回2xx
+	DeliveryFailed    = "failed"    // 重试次数用尽
+)
+
+type Webhook struct {
+	ID           int64     `json:"id"`
+	UserName     string    `json:"userName"`
+	URL          string    `json:"url"`
+	Secret       string    `json:"secret,omitempty"`
+	Events       []string  `json:"events"`     // 订阅的变更类型，为空表示全部
+	FolderPath   string    `json:"folderPath"` // 只投递该路径及其下的变更
+	LastChangeID int64     `json:"-"`          // 已生成投递记录的最大变更ID
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int64      `json:"webhookId"`
+	ChangeID       int64      `json:"changeId"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus"` // 最后一次投递的HTTP状态码，未收到响应时为0
+	Error          string     `json:"error"`          // 最后一次投递的错误
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// 待投递的任务
+type WebhookTask struct {
+	DeliveryID int64
+	Attempts   int
+	WebhookID  int64
+	URL        string
+	Secret     string
+	Change     Change
+}
+
+/**
+ * @description: 新建Webhook，只投递创建之后的变更
+ * @param {*Webhook} hook 订阅信息，成功后写入ID和创建时间
+ * @return {*}
+ */
+func CreateWebhook(hook *Webhook) error {
+	lastChangeID, err := LatestChangeID()
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO webhooks (user_name, url, secret, events, folder_path, last_change_id) VALUES (?, ?, ?, ?, ?, ?);"
+	res, err := db.Exec(query, hook.UserName, hook.URL, hook.Secret, strings.Join(hook.Events, ","), hook.FolderPath, lastChangeID)
+	if err != nil {
+		return err
+	}
+	if hook.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	hook.LastChangeID = lastChangeID
+	return db.QueryRow("SELECT created_at FROM webhooks WHERE id = ?;", hook.ID).Scan(&hook.CreatedAt)
+}
+
+/**
+ * @description: 删除用户的Webhook，投递记录级联删除
+ * @param {int64} webhookID Webhook ID
+ * @param {string} userName 所属用户
+ * @return {*}
+ */
+func DeleteWebhook(webhookID int64, userName string) error {
+	res, err := db.Exec("DELETE FROM webhooks WHERE id = ? AND user_name = ?;", webhookID, userName)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrWebhookNotExist
+	}
+	return nil
+}
+
+/**
+ * @description: 扫描一行Webhook
+ * @param {func(dest ...interface{}) error} scan
+ * @return {*Webhook}
+ */
+func scanWebhook(scan func(dest ...interface{}) error) (*Webhook, error) {
+	var hook Webhook
+	var events string
+	if err := scan(&hook.ID, &hook.UserName, &hook.URL, &hook.Secret, &events, &hook.FolderPath, &hook.LastChangeID, &hook.CreatedAt); err != nil {
+		return nil, err
+	}
+	hook.Events = []string{}
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	return &hook, nil
+}
+
+const webhookColumns = "id, user_name, url, secret, events, folder_path, last_change_id, created_at"
+
+/**
+ * @description: 查询用户的Webhook
+ * @param {int64} webhookID Webhook ID
+ * @param {string} userName 所属用户
+ * @return {*Webhook} 包含密钥
+ */
+func QueryWebhook(webhookID int64, userName string) (*Webhook, error) {
+	row := db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ? AND user_name = ?;", webhookID, userName)
+	hook, err := scanWebhook(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotExist
+	}
+	return hook, err
+}
+
+/**
+ * @description: 查询Webhook列表
+ * @param {string} userName 所属用户，为空时查询全部用户的Webhook
+ * @return {[]Webhook} 包含密钥
+ */
+func QueryWebhooks(userName string) ([]Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks ORDER BY id;"
+	args := []interface{}{}
+	if userName != "" {
+		query = "SELECT " + webhookColumns + " FROM webhooks WHERE user_name = ? ORDER BY id;"
+		args = append(args, userName)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *hook)
+	}
+	return hooks, rows.Err()
+}
+
+/**
+ * @description: 为匹配的变更生成投递记录，并推进Webhook的变更游标
+ * @param {int64} webhookID Webhook ID
+ * @param {[]int64} changeIDs 匹配的变更ID
+ * @param {int64} lastChangeID 已处理的最大变更ID
+ * @return {*}
+ */
+func EnqueueWebhookDeliveries(webhookID int64, changeIDs []int64, lastChangeID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 列不保存小数秒，MySQL写入时四舍五入，截断后立即到期
+	now := time.Now().Truncate(time.Second)
+	for _, changeID := range changeIDs {
+		query := "INSERT IGNORE INTO webhook_deliveries (webhook_id, change_id, status, next_attempt_at) VALUES (?, ?, ?, ?);"
+		if _, err := tx.Exec(query, webhookID, changeID, DeliveryPending, now); err != nil {
+			return err
+		}
+	}
+	query := "UPDATE webhooks SET last_change_id = ? WHERE id = ? AND last_change_id < ?;"
+	if _, err := tx.Exec(query, lastChangeID, webhookID, lastChangeID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/**
+ * @description: 查询到期的待投递任务
+ * @param {time.Time} now 当前时间
+ * @param {int} limit 最多返回条数
+ * @return {[]WebhookTask} 按下次投递时间排列
+ */
+func QueryDueWebhookTasks(now time.Time, limit int) ([]WebhookTask, error) {
+	query := `SELECT d.id, d.attempts, w.id, w.url, w.secret,
+		c.id, c.item_type, c.item_id, c.action, c.path, c.old_path, c.parent_folder_id, c.size, c.created_at
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		JOIN changes c ON c.id = d.change_id
+		WHERE d.status = ? AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.id LIMIT ?;`
+	rows, err := db.Query(query, DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []WebhookTask{}
+	for rows.Next() {
+		var task WebhookTask
+		change := &task.Change
+		if err := rows.Scan(&task.DeliveryID, &task.Attempts, &task.WebhookID, &task.URL, &task.Secret,
+			&change.ID, &change.ItemType, &change.ItemID, &change.Action, &change.Path, &change.OldPath, &change.ParentFolderID, &change.Size, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+/**
+ * @description: 记录一次投递的结果
+ * @param {int64} deliveryID 投递记录ID
+ * @param {string} status 投递后的状态
+ * @param {int} attempts 累计投递次数
+ * @param {int} responseStatus HTTP状态码
+ * @param {string} errMsg 错误信息
+ * @param {*time.Time} nextAttemptAt 下次重试时间，不再重试时为nil
+ * @return {*}
+ */
+func UpdateWebhookDelivery(deliveryID int64, status string, attempts int, responseStatus int, errMsg string, nextAttemptAt *time.Time) error {
+	query := "UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, error = ?, next_attempt_at = ? WHERE id = ?;"
+	_, err := db.Exec(query, status, attempts, responseStatus, errMsg, nextAttemptAt, deliveryID)
+	return err
+}
+
+/**
+ * @description: 查询Webhook的投递记录
+ * @param {int64} webhookID Webhook ID
+ * @param {string} status 投递状态，为空时不过滤
+ * @param {int} limit 最多返回条数
+ * @return {[]WebhookDelivery} 按ID倒序排列
+ */
+func QueryWebhookDeliveries(webhookID int64, status string, limit int) ([]WebhookDelivery, error) {
+	query := `SELECT d.id, d.webhook_id, d.change_id, c.action, d.status, d.attempts, d.response_status, d.error, d.next_attempt_at, d.created_at, d.updated_at
+		FROM webhook_deliveries d
+		JOIN changes c ON c.id = d.change_id
+		WHERE d.webhook_id = ? AND (? = '' OR d.status = ?)
+		ORDER BY d.id DESC LIMIT ?;`
+	rows, err := db.Query(query, webhookID, status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var nextAttemptAt sql.NullTime
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.ChangeID, &delivery.Event, &delivery.Status, &delivery.Attempts,
+			&delivery.ResponseStatus, &delivery.Error, &nextAttemptAt, &delivery.CreatedAt, &delivery.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if nextAttemptAt.Valid {
+			delivery.NextAttemptAt = &nextAttemptAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
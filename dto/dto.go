@@ -1,13 +1,16 @@
 /*
  * @Author: shanghanjin
  * @Date: 2024-08-20 15:00:52
- * @LastEditTime: 2024-08-29 11:39:05
+ * @LastEditTime: 2026-10-19 15:41:07
  * @FilePath: \UserFeedBack\dto\dto.go
  * @Description: 公共结构体
  */
 package dto
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // FileType represents whether it's a folder or a file
 type FileType int
@@ -29,21 +32,35 @@ func (f FileType) String() string {
 }
 
 type File struct {
-	ID             int64     `json:"id"`
-	ParentFolderID int64     `json:"parentFolderId"`
-	Name           string    `json:"name"`
-	Type           FileType  `json:"fileType"`
-	Path           string    `json:"path"`
-	Size           int64     `json:"size"`
-	CreatedAt      time.Time `json:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt"`
+	ID             int64             `json:"id"`
+	ParentFolderID int64             `json:"parentFolderId"`
+	Name           string            `json:"name"`
+	Type           FileType          `json:"fileType"`
+	Path           string            `json:"path"`
+	Size           int64             `json:"size"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+	Tags           []string          `json:"tags,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	MimeType       string            `json:"mimeType,omitempty"` // 尚未识别时为空
+}
+
+/**
+ * @description: 文件的实体标签，由ID、大小和更新时间组成，内容被替换后改变，用于v2接口的ETag和If-Match
+ * 更新时间只精确到秒，同一秒内写入相同大小的内容时标签不变
+ * @return {string} 带引号的标签
+ */
+func (f *File) ETag() string {
+	return fmt.Sprintf(`"%d-%d-%d"`, f.ID, f.Size, f.UpdatedAt.Unix())
 }
 
 type Folder struct {
-	ID             int64     `json:"id"`
-	ParentFolderID int64     `json:"parentFolderId"`
-	Name           string    `json:"name"`
-	Path           string    `json:"path"`
-	CreatedAt      time.Time `json:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt"`
+	ID             int64             `json:"id"`
+	ParentFolderID int64             `json:"parentFolderId"`
+	Name           string            `json:"name"`
+	Path           string            `json:"path"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+	Tags           []string          `json:"tags,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
 }
@@ -0,0 +1,10 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 19:02:15
+ * @LastEditTime: 2026-10-18 19:02:15
+ * @FilePath: \CloudDisk\grpcapi\generate.go
+ * @Description: gRPC接口定义，clouddisk.pb.go和clouddisk_grpc.pb.go由clouddisk.proto生成
+ */
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative clouddisk.proto
@@ -1,7 +1,7 @@
 /*
  * @Author: shanghanjin
  * @Date: 2024-08-12 11:38:02
- * @LastEditTime: 2024-12-25 17:02:07
+ * @LastEditTime: 2026-10-19 10:03:18
  * @FilePath: \CloudDisk\main.go
  * @Description:main
  */
@@ -12,6 +12,7 @@ import (
 	"CloudDisk/configwrapper"
 	"CloudDisk/dbwrapper"
 	"CloudDisk/logwrapper"
+	"CloudDisk/openapi"
 	"net/http"
 
 	"github.com/rs/cors"
@@ -36,6 +37,18 @@ func main() {
 	// 初始化基础文件夹
 	business.MakeAbsoluteFolder("/")
 
+	// 启动文件内容索引
+	business.StartIndexer()
+
+	// 启动缩略图生成
+	business.StartThumbnailer()
+
+	// 启动视频转码，未安装ffmpeg时跳过
+	business.StartTranscoder(business.NewFFmpegTranscoder())
+
+	// 启动Webhook投递
+	business.StartWebhookWorker()
+
 	// 创建一个新的多路复用器
 	mux := http.NewServeMux()
 
@@ -43,25 +56,87 @@ func main() {
 	staticFS := http.FileServer(http.Dir("./dist"))
 	mux.Handle("/", staticFS)
 
-	// 设置各接口响应函数
-	mux.HandleFunc("/api/queryFolder", business.QueryFolder)
-	mux.HandleFunc("/api/createFolder", business.CreateFolder)
-	mux.HandleFunc("/api/uploadFile", business.UploadFile)
-	mux.HandleFunc("/api/renameFolder", business.RenameFolder)
-	mux.HandleFunc("/api/renameFile", business.RenameFile)
-	mux.HandleFunc("/api/deleteFile", business.DeleteFile)
-	mux.HandleFunc("/api/deleteFolder", business.DeleteFolder)
-	mux.HandleFunc("/api/downloadFile", business.DownloadFile)
+	// 设置各接口响应函数，配置了用户时所有接口都需要认证
+	api := http.NewServeMux()
+	api.HandleFunc("/api/queryFolder", business.QueryFolder)
+	api.HandleFunc("/api/createFolder", business.CreateFolder)
+	api.HandleFunc("/api/uploadFile", business.UploadFile)
+	api.HandleFunc("/api/renameFolder", business.RenameFolder)
+	api.HandleFunc("/api/renameFile", business.RenameFile)
+	api.HandleFunc("/api/deleteFile", business.DeleteFile)
+	api.HandleFunc("/api/deleteFolder", business.DeleteFolder)
+	api.HandleFunc("/api/downloadFile", business.DownloadFile)
+	api.HandleFunc("/api/queryPath", business.QueryPath)
+	api.HandleFunc("/api/search", business.Search)
+	api.HandleFunc("/api/searchContent", business.SearchContent)
+	api.HandleFunc("/api/addTags", business.AddTags)
+	api.HandleFunc("/api/removeTags", business.RemoveTags)
+	api.HandleFunc("/api/queryTags", business.QueryTags)
+	api.HandleFunc("/api/setMetadata", business.SetMetadata)
+	api.HandleFunc("/api/deleteMetadata", business.DeleteMetadata)
+	api.HandleFunc("/api/addFavorite", business.AddFavorite)
+	api.HandleFunc("/api/removeFavorite", business.RemoveFavorite)
+	api.HandleFunc("/api/queryFavorites", business.QueryFavorites)
+	api.HandleFunc("/api/queryRecent", business.QueryRecent)
+	api.HandleFunc("/api/createAccessKey", business.CreateAccessKey)
+	api.HandleFunc("/api/deleteAccessKey", business.DeleteAccessKey)
+	api.HandleFunc("/api/queryAccessKeys", business.QueryAccessKeys)
+	api.HandleFunc("/api/changes", business.QueryChanges)
+	api.HandleFunc("/api/events", business.Events)
+	api.HandleFunc("/api/createWebhook", business.CreateWebhook)
+	api.HandleFunc("/api/deleteWebhook", business.DeleteWebhook)
+	api.HandleFunc("/api/queryWebhooks", business.QueryWebhooks)
+	api.HandleFunc("/api/queryWebhookDeliveries", business.QueryWebhookDeliveries)
+	api.HandleFunc("/api/thumbnail", business.Thumbnail)
+	api.HandleFunc("/api/preview", business.Preview)
+	api.HandleFunc("/api/video/{fileId}/{name...}", business.VideoFile)
+	mux.Handle("/api/", business.WithAuth(api))
+
+	// 提供OpenAPI接口描述
+	mux.HandleFunc("/api/openapi.json", openapi.Handler)
+
+	// 注册v2接口
+	business.RegisterV2(mux)
+
+	// 挂载WebDAV服务
+	mux.Handle("/dav/", business.NewWebDAVHandler("/dav"))
 
 	// 设置跨域请求
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "Content-Disposition"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"},
+		AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "Content-Disposition", "Depth", "Destination", "Overwrite", "If", "Lock-Token", "Timeout", "Last-Event-ID", "Range", "If-None-Match", "If-Range"},
 	})
 
 	handler := c.Handler(mux)
 
+	// 启动gRPC服务
+	if grpcCfg := configwrapper.Cfg.GRPC; grpcCfg.Address != "" {
+		go func() {
+			if err := business.ServeGRPC(grpcCfg.Address); err != nil {
+				logwrapper.Logger.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
+
+	// 启动SFTP服务
+	if sftpCfg := configwrapper.Cfg.SFTP; sftpCfg.Address != "" {
+		go func() {
+			if err := business.ServeSFTP(sftpCfg.Address, sftpCfg.HostKeyFile); err != nil {
+				logwrapper.Logger.Fatalf("SFTP server failed: %v", err)
+			}
+		}()
+	}
+
+	// 启动S3兼容服务
+	if s3Cfg := configwrapper.Cfg.S3; s3Cfg.Address != "" {
+		go func() {
+			if err := business.ServeS3(s3Cfg.Address, s3Cfg.Region); err != nil {
+				logwrapper.Logger.Fatalf("S3 server failed: %v", err)
+			}
+		}()
+	}
+
 	logwrapper.Logger.Info("Server is running")
 
 	// 启动服务
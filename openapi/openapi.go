@@ -0,0 +1,27 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 20:12:48
+ * @LastEditTime: 2026-10-18 20:12:48
+ * @FilePath: \CloudDisk\openapi\openapi.go
+ * @Description: OpenAPI接口描述，编译进程序并通过HTTP提供
+ */
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+/**
+ * @description: 返回OpenAPI描述的处理函数
+ * @param {http.ResponseWriter} w
+ * @param {*http.Request} r
+ * @return {*}
+ */
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
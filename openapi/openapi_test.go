@@ -0,0 +1,435 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 12:18:53
+ * @LastEditTime: 2026-10-19 12:18:53
+ * @FilePath: \CloudDisk\openapi\openapi_test.go
+ * @Description: 检查OpenAPI描述与注册的接口一致：路径、方法和请求体字段
+ */
+package openapi
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 路径和方法
+type route struct {
+	path   string
+	method string
+}
+
+func (r route) String() string {
+	return r.method + " " + r.path
+}
+
+// 请求体，内容类型到字段名集合的映射，application/octet-stream没有字段
+type requestBody map[string][]string
+
+// business包的语法树
+type businessPackage struct {
+	funcs  map[string]*ast.FuncDecl
+	types  map[string]*ast.TypeSpec
+	consts map[string]string
+}
+
+/**
+ * @description: 解析business包
+ * @param {*testing.T} t
+ * @return {*businessPackage}
+ */
+func parseBusiness(t *testing.T) *businessPackage {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), "../business", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := &businessPackage{funcs: map[string]*ast.FuncDecl{}, types: map[string]*ast.TypeSpec{}, consts: map[string]string{}}
+	for _, file := range pkgs["business"].Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					pkg.funcs[decl.Name.Name] = decl
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						pkg.types[spec.Name.Name] = spec
+					case *ast.ValueSpec:
+						for i, name := range spec.Names {
+							if i < len(spec.Values) {
+								if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+									pkg.consts[name.Name], _ = strconv.Unquote(lit.Value)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return pkg
+}
+
+/**
+ * @description: 字符串字面量的值，不是字符串字面量时返回false
+ */
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// 通配路径参数{name...}在OpenAPI中写作{name}
+var wildcardParam = regexp.MustCompile(`\{(\w+)\.\.\.\}`)
+
+/**
+ * @description: 收集main.go和RegisterV2中注册的/api/路由
+ * @param {*testing.T} t
+ * @param {*businessPackage} pkg
+ * @return {map[route]string} 路由到处理函数名的映射
+ */
+func registeredRoutes(t *testing.T, pkg *businessPackage) map[route]string {
+	routes := map[route]string{}
+	add := func(path string, method string, handler string) {
+		routes[route{wildcardParam.ReplaceAllString(path, "{$1}"), method}] = handler
+	}
+
+	// main.go中的v1接口，方法由处理函数检查，OpenAPI描述本身不在描述中
+	file, err := parser.ParseFile(token.NewFileSet(), "../main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		fun, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (fun.Sel.Name != "HandleFunc" && fun.Sel.Name != "Handle") {
+			return true
+		}
+		path, ok := stringLit(call.Args[0])
+		handler, isSel := call.Args[1].(*ast.SelectorExpr)
+		if !ok || !isSel || !strings.HasPrefix(path, "/api/") {
+			return true
+		}
+		if x, ok := handler.X.(*ast.Ident); !ok || x.Name != "business" {
+			return true
+		}
+
+		decl := pkg.funcs[handler.Sel.Name]
+		if decl == nil {
+			t.Fatalf("handler %s for %s not found", handler.Sel.Name, path)
+		}
+		methods := handlerMethods(decl)
+		if len(methods) == 0 {
+			t.Errorf("handler %s for %s does not check the request method", handler.Sel.Name, path)
+		}
+		for _, method := range methods {
+			add(path, method, handler.Sel.Name)
+		}
+		return true
+	})
+
+	// RegisterV2中v2Route注册的接口，方法由映射的键给出
+	register := pkg.funcs["RegisterV2"]
+	if register == nil {
+		t.Fatal("RegisterV2 not found")
+	}
+	ast.Inspect(register, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if fun, ok := call.Fun.(*ast.Ident); !ok || fun.Name != "v2Route" {
+			return true
+		}
+		pattern, ok := stringLit(call.Args[1])
+		if !ok {
+			t.Fatal("v2Route pattern is not a string literal")
+		}
+		for _, elt := range call.Args[2].(*ast.CompositeLit).Elts {
+			kv := elt.(*ast.KeyValueExpr)
+			method := strings.ToUpper(strings.TrimPrefix(kv.Key.(*ast.SelectorExpr).Sel.Name, "Method"))
+			add(pkg.consts["v2Prefix"]+pattern, method, kv.Value.(*ast.Ident).Name)
+		}
+		return true
+	})
+
+	if len(routes) == 0 {
+		t.Fatal("no routes found")
+	}
+	return routes
+}
+
+/**
+ * @description: 处理函数通过r.Method != http.MethodX检查接受的方法，HEAD随GET隐含，不单独列出
+ * @param {*ast.FuncDecl} decl
+ * @return {[]string}
+ */
+func handlerMethods(decl *ast.FuncDecl) []string {
+	var methods []string
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		bin, ok := n.(*ast.BinaryExpr)
+		if !ok || bin.Op != token.NEQ {
+			return true
+		}
+		if left, ok := bin.X.(*ast.SelectorExpr); !ok || left.Sel.Name != "Method" {
+			return true
+		}
+		if right, ok := bin.Y.(*ast.SelectorExpr); ok && strings.HasPrefix(right.Sel.Name, "Method") {
+			method := strings.ToUpper(strings.TrimPrefix(right.Sel.Name, "Method"))
+			if method != "HEAD" {
+				methods = append(methods, method)
+			}
+		}
+		return true
+	})
+	return methods
+}
+
+/**
+ * @description: 从处理函数的语法树推断请求体：Decode的目标结构体为JSON，FormFile和FormValue为multipart，
+ * 直接使用r.Body为application/octet-stream
+ * @param {*testing.T} t
+ * @param {*businessPackage} pkg
+ * @param {*ast.FuncDecl} decl 处理函数
+ * @return {requestBody}
+ */
+func handlerRequestBody(t *testing.T, pkg *businessPackage, decl *ast.FuncDecl) requestBody {
+	body := requestBody{}
+	localTypes := map[string]*ast.TypeSpec{}
+	varTypes := map[string]ast.Expr{}
+	decoderArgs := map[ast.Node]bool{}
+	var rawBody bool
+
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.TypeSpec:
+			localTypes[n.Name.Name] = n
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				varTypes[name.Name] = n.Type
+			}
+		case *ast.CallExpr:
+			fun, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			switch fun.Sel.Name {
+			case "NewDecoder":
+				decoderArgs[n.Args[0]] = true
+			case "Decode":
+				arg, ok := n.Args[0].(*ast.UnaryExpr)
+				if !ok {
+					t.Fatalf("%s: Decode argument is not &variable", decl.Name.Name)
+				}
+				name := arg.X.(*ast.Ident).Name
+				typ, ok := varTypes[name].(*ast.Ident)
+				if !ok {
+					t.Fatalf("%s: type of %s not found", decl.Name.Name, name)
+				}
+				spec := localTypes[typ.Name]
+				if spec == nil {
+					spec = pkg.types[typ.Name]
+				}
+				if spec == nil {
+					t.Fatalf("%s: type %s not found", decl.Name.Name, typ.Name)
+				}
+				body["application/json"] = jsonFields(t, pkg, spec)
+			case "FormFile", "FormValue":
+				if name, ok := stringLit(n.Args[0]); ok {
+					body["multipart/form-data"] = append(body["multipart/form-data"], name)
+				}
+			}
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok && x.Name == "r" && n.Sel.Name == "Body" && !decoderArgs[n] {
+				rawBody = true
+			}
+		}
+		return true
+	})
+
+	if rawBody && len(body) == 0 {
+		body["application/octet-stream"] = nil
+	}
+	for _, fields := range body {
+		sort.Strings(fields)
+	}
+	return body
+}
+
+/**
+ * @description: 结构体按encoding/json规则序列化的字段名，展开匿名嵌入的结构体
+ * @return {[]string}
+ */
+func jsonFields(t *testing.T, pkg *businessPackage, spec *ast.TypeSpec) []string {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		t.Fatalf("type %s is not a struct", spec.Name.Name)
+	}
+
+	var fields []string
+	for _, field := range st.Fields.List {
+		var tag string
+		if field.Tag != nil {
+			raw, _ := strconv.Unquote(field.Tag.Value)
+			tag, _, _ = strings.Cut(reflect.StructTag(raw).Get("json"), ",")
+		}
+		if tag == "-" {
+			continue
+		}
+		if len(field.Names) == 0 {
+			embedded, ok := field.Type.(*ast.Ident)
+			if ok && tag == "" && pkg.types[embedded.Name] != nil {
+				fields = append(fields, jsonFields(t, pkg, pkg.types[embedded.Name])...)
+				continue
+			}
+			t.Fatalf("type %s: unsupported embedded field", spec.Name.Name)
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			if tag != "" {
+				fields = append(fields, tag)
+			} else {
+				fields = append(fields, name.Name)
+			}
+		}
+	}
+	return fields
+}
+
+// OpenAPI描述中用到的部分
+type openAPISpec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type schema struct {
+	Ref        string                     `json:"$ref"`
+	AllOf      []*schema                  `json:"allOf"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+/**
+ * @description: 解析$ref并合并allOf后的属性名
+ * @return {[]string}
+ */
+func (spec *openAPISpec) properties(t *testing.T, s *schema) []string {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		ref := spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if ref == nil {
+			t.Fatalf("unresolved reference %s", s.Ref)
+		}
+		return spec.properties(t, ref)
+	}
+
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	for _, sub := range s.AllOf {
+		names = append(names, spec.properties(t, sub)...)
+	}
+	return names
+}
+
+/**
+ * @description: 描述中/api/下的路由及其请求体
+ * @return {map[route]requestBody}
+ */
+func describedRoutes(t *testing.T) map[route]requestBody {
+	var spec openAPISpec
+	if err := json.Unmarshal(Spec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := map[route]requestBody{}
+	for path, item := range spec.Paths {
+		if !strings.HasPrefix(path, "/api/") {
+			continue
+		}
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			body := requestBody{}
+			if op.RequestBody != nil {
+				for contentType, content := range op.RequestBody.Content {
+					fields := spec.properties(t, content.Schema)
+					sort.Strings(fields)
+					body[contentType] = fields
+				}
+			}
+			routes[route{path, strings.ToUpper(method)}] = body
+		}
+	}
+	return routes
+}
+
+func TestSpecMatchesHandlers(t *testing.T) {
+	pkg := parseBusiness(t)
+	registered := registeredRoutes(t, pkg)
+	described := describedRoutes(t)
+
+	// 每个注册的路由都有描述，且请求体字段一致
+	for r, handler := range registered {
+		body, ok := described[r]
+		if !ok {
+			t.Errorf("%s (%s) is not described in openapi.json", r, handler)
+			continue
+		}
+		actual := handlerRequestBody(t, pkg, pkg.funcs[handler])
+		for contentType, fields := range actual {
+			if specFields, ok := body[contentType]; !ok {
+				t.Errorf("%s: %s request body is not described", r, contentType)
+			} else if strings.Join(fields, ",") != strings.Join(specFields, ",") {
+				t.Errorf("%s: %s fields are %v in %s, %v in openapi.json", r, contentType, fields, handler, specFields)
+			}
+		}
+		for contentType := range body {
+			if _, ok := actual[contentType]; !ok {
+				t.Errorf("%s: described %s request body is not read by %s", r, contentType, handler)
+			}
+		}
+	}
+
+	// 每个描述的路由都已注册
+	for r := range described {
+		if _, ok := registered[r]; !ok {
+			t.Errorf("%s is described in openapi.json but not registered", r)
+		}
+	}
+}
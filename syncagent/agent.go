@@ -0,0 +1,235 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:31:12
+ * @LastEditTime: 2026-10-18 22:10:45
+ * @FilePath: \CloudDisk\syncagent\agent.go
+ * @Description: 本地目录与CloudDisk文件夹的双向同步
+ */
+package syncagent
+
+import (
+	"CloudDisk/client"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 本地目录下保存同步状态的目录，不参与同步
+const stateDirName = ".clouddisk-sync"
+
+// 本地变化后等待的时间，合并短时间内的多次变化
+const debounceDelay = time.Second
+
+// 同步选项
+type Options struct {
+	PollInterval time.Duration // 查询远程变化的间隔，默认30秒，服务端支持变更日志时没有变化不会重新扫描
+	Logger       *log.Logger   // 默认输出到标准错误
+}
+
+// 同步代理
+type Agent struct {
+	client     *client.Client
+	localRoot  string // 本地目录的绝对路径
+	remoteRoot string // 远程文件夹路径
+	remoteID   int64  // 远程文件夹ID
+	state      *stateDB
+	opts       Options
+	hostname   string // 用于冲突副本的命名
+
+	remoteIDs map[string]int64 // 本次同步中远程文件夹的相对路径 -> ID
+
+	remotes map[string]*remoteItem // 上次扫描的远程条目，变更日志中没有相关变更时复用
+	cursor  string                 // 上次扫描前的变更日志游标，服务端不支持变更日志时为空
+}
+
+/**
+ * @description: 创建同步代理，首次同步时在本地目录下创建状态数据库
+ * @param {*client.Client} c 服务端客户端
+ * @param {string} localRoot 本地目录
+ * @param {string} remoteRoot 远程文件夹路径，必须已存在
+ * @param {Options} opts 同步选项
+ * @return {*Agent}
+ */
+func New(ctx context.Context, c *client.Client, localRoot string, remoteRoot string, opts Options) (*Agent, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 30 * time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	localRoot, err := filepath.Abs(localRoot)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(localRoot); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", localRoot)
+	}
+
+	info, err := c.QueryPath(ctx, remoteRoot)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", remoteRoot, err)
+	}
+	if info.Folder == nil {
+		return nil, fmt.Errorf("%s: not a folder", remoteRoot)
+	}
+
+	if err := os.MkdirAll(filepath.Join(localRoot, stateDirName), 0700); err != nil {
+		return nil, err
+	}
+	state, err := openState(filepath.Join(localRoot, stateDirName, "state.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	// 远程文件夹变化(例如被删除后重建)时原有记录不再有效，重新进行首次同步
+	remoteKey := strconv.FormatInt(info.Folder.ID, 10) + ":" + info.Folder.Path
+	if previous, err := state.getMeta("remote"); err != nil {
+		state.Close()
+		return nil, err
+	} else if previous != remoteKey {
+		if err := state.reset(); err != nil {
+			state.Close()
+			return nil, err
+		}
+		if err := state.setMeta("remote", remoteKey); err != nil {
+			state.Close()
+			return nil, err
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	return &Agent{
+		client:     c,
+		localRoot:  localRoot,
+		remoteRoot: info.Folder.Path,
+		remoteID:   info.Folder.ID,
+		state:      state,
+		opts:       opts,
+		hostname:   hostname,
+	}, nil
+}
+
+func (a *Agent) Close() error {
+	return a.state.Close()
+}
+
+/**
+ * @description: 执行一次完整的同步
+ * @return {*}
+ */
+func (a *Agent) SyncOnce(ctx context.Context) error {
+	records, err := a.state.all()
+	if err != nil {
+		return err
+	}
+	locals, err := a.scanLocal(records)
+	if err != nil {
+		return err
+	}
+	remotes, err := a.remoteState(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.apply(ctx, plan(records, locals, remotes), remotes)
+}
+
+/**
+ * @description: 持续同步，本地变化通过fsnotify触发，远程变化定期查询
+ * @return {*} ctx取消时返回nil
+ */
+func (a *Agent) Run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := a.watchTree(watcher, a.localRoot); err != nil {
+		return err
+	}
+
+	syncNow := func() {
+		if err := a.SyncOnce(ctx); err != nil && ctx.Err() == nil {
+			// 离线时等待下次触发重试，已完成的操作都已记录
+			a.logf("sync failed: %v", err)
+		}
+	}
+	syncNow()
+
+	ticker := time.NewTicker(a.opts.PollInterval)
+	defer ticker.Stop()
+	debounce := time.NewTimer(debounceDelay)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if a.isStatePath(event.Name) {
+				continue
+			}
+			// 新建的目录需要单独监听
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := a.watchTree(watcher, event.Name); err != nil {
+						a.logf("watch %s: %v", event.Name, err)
+					}
+				}
+			}
+			debounce.Reset(debounceDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			a.logf("watch: %v", err)
+		case <-debounce.C:
+			syncNow()
+		case <-ticker.C:
+			syncNow()
+		}
+	}
+}
+
+/**
+ * @description: 监听目录及其所有子目录
+ * @param {*fsnotify.Watcher} watcher
+ * @param {string} root 目录
+ * @return {*}
+ */
+func (a *Agent) watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if a.isStatePath(p) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(p)
+	})
+}
+
+func (a *Agent) isStatePath(p string) bool {
+	stateDir := filepath.Join(a.localRoot, stateDirName)
+	return p == stateDir || filepath.Dir(p) == stateDir
+}
+
+func (a *Agent) logf(format string, args ...interface{}) {
+	a.opts.Logger.Printf(format, args...)
+}
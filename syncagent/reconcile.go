@@ -0,0 +1,655 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:31:12
+ * @LastEditTime: 2026-10-19 15:41:07
+ * @FilePath: \CloudDisk\syncagent\reconcile.go
+ * @Description: 对比同步记录、本地状态和远程状态，决定并执行同步操作
+ */
+package syncagent
+
+import (
+	"CloudDisk/client"
+	"CloudDisk/dto"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 同步操作
+type action int
+
+const (
+	actRecord       action = iota // 两端一致，只更新记录
+	actForget                     // 两端都已删除
+	actUpload                     // 上传本地文件
+	actDownload                   // 下载远程文件
+	actMkdirRemote                // 新建远程文件夹
+	actMkdirLocal                 // 新建本地目录
+	actDeleteRemote               // 删除远程条目
+	actDeleteLocal                // 删除本地条目
+	actCompare                    // 两端都有未记录的同名文件，比较内容后决定
+	actConflict                   // 两端都有修改
+)
+
+// 单个路径的同步操作
+type change struct {
+	rel    string
+	act    action
+	rec    *record
+	local  *localItem
+	remote *remoteItem
+}
+
+/**
+ * @description: 本地条目相对记录是否有变化
+ * @param {*record} rec 同步记录
+ * @param {*localItem} l 本地条目
+ * @return {bool}
+ */
+func localChanged(rec *record, l *localItem) bool {
+	if rec.Folder != l.Folder {
+		return true
+	}
+	return !l.Folder && l.Hash != "" && l.Hash != rec.Hash
+}
+
+/**
+ * @description: 远程条目相对记录是否有变化
+ * @param {*record} rec 同步记录
+ * @param {*remoteItem} r 远程条目
+ * @return {bool}
+ */
+func remoteChanged(rec *record, r *remoteItem) bool {
+	if rec.Folder != r.Folder {
+		return true
+	}
+	return !r.Folder && (r.ID != rec.RemoteID || r.Size != rec.RemoteSize || !r.Updated.Equal(rec.RemoteUpdated))
+}
+
+/**
+ * @description: 按路径层级排序的键，父目录排在其下所有条目之前且子树连续
+ * @param {string} rel 相对路径
+ * @return {string}
+ */
+func sortKey(rel string) string {
+	return strings.ReplaceAll(rel, "/", "\x00")
+}
+
+/**
+ * @description: 根据同步记录、本地状态和远程状态生成同步操作
+ * @param {map[string]*record} records 同步记录
+ * @param {map[string]*localItem} locals 本地条目
+ * @param {map[string]*remoteItem} remotes 远程条目
+ * @return {[]*change} 按路径层级排序的操作
+ */
+func plan(records map[string]*record, locals map[string]*localItem, remotes map[string]*remoteItem) []*change {
+	seen := make(map[string]bool)
+	for rel := range records {
+		seen[rel] = true
+	}
+	for rel := range locals {
+		seen[rel] = true
+	}
+	for rel := range remotes {
+		seen[rel] = true
+	}
+	rels := make([]string, 0, len(seen))
+	for rel := range seen {
+		rels = append(rels, rel)
+	}
+	sort.Slice(rels, func(i, j int) bool { return sortKey(rels[i]) < sortKey(rels[j]) })
+
+	changes := make([]*change, 0, len(rels))
+	for _, rel := range rels {
+		c := &change{rel: rel, rec: records[rel], local: locals[rel], remote: remotes[rel]}
+		c.act = decide(c.rec, c.local, c.remote)
+		changes = append(changes, c)
+	}
+
+	// 一端删除了文件夹而另一端在其下有新内容时，保留文件夹
+	for i, c := range changes {
+		if c.act != actDeleteLocal && c.act != actDeleteRemote {
+			continue
+		}
+		if (c.local != nil && !c.local.Folder) || (c.remote != nil && !c.remote.Folder) {
+			continue
+		}
+		prefix := sortKey(c.rel) + "\x00"
+		for _, d := range changes[i+1:] {
+			if !strings.HasPrefix(sortKey(d.rel), prefix) {
+				break
+			}
+			if d.act != c.act && d.act != actForget {
+				if c.act == actDeleteLocal {
+					c.act = actMkdirRemote
+				} else {
+					c.act = actMkdirLocal
+				}
+				break
+			}
+		}
+	}
+	return changes
+}
+
+/**
+ * @description: 决定单个路径的同步操作
+ * @param {*record} rec 同步记录，首次出现时为nil
+ * @param {*localItem} l 本地条目，不存在时为nil
+ * @param {*remoteItem} r 远程条目，不存在时为nil
+ * @return {action}
+ */
+func decide(rec *record, l *localItem, r *remoteItem) action {
+	switch {
+	case l == nil && r == nil:
+		return actForget
+	case rec == nil && r == nil:
+		if l.Folder {
+			return actMkdirRemote
+		}
+		return actUpload
+	case rec == nil && l == nil:
+		if r.Folder {
+			return actMkdirLocal
+		}
+		return actDownload
+	case rec == nil:
+		if l.Folder && r.Folder {
+			return actRecord
+		} else if !l.Folder && !r.Folder {
+			return actCompare
+		}
+		return actConflict
+	case r == nil:
+		// 远程已删除，本地修改过则重新上传
+		if !localChanged(rec, l) {
+			return actDeleteLocal
+		} else if l.Folder {
+			return actMkdirRemote
+		}
+		return actUpload
+	case l == nil:
+		// 本地已删除，远程修改过则重新下载
+		if !remoteChanged(rec, r) {
+			return actDeleteRemote
+		} else if r.Folder {
+			return actMkdirLocal
+		}
+		return actDownload
+	}
+
+	lChanged, rChanged := localChanged(rec, l), remoteChanged(rec, r)
+	switch {
+	case l.Folder && r.Folder:
+		return actRecord
+	case l.Folder != r.Folder:
+		return actConflict
+	case lChanged && rChanged:
+		return actConflict
+	case lChanged:
+		return actUpload
+	case rChanged:
+		return actDownload
+	}
+	return actRecord
+}
+
+/**
+ * @description: 执行同步操作，新建按父目录在前的顺序执行，删除按子条目在前的顺序执行
+ * @param {[]*change} changes 同步操作
+ * @param {map[string]*remoteItem} remotes 远程条目，用于查询父文件夹ID
+ * @return {*} 网络错误时中止并返回，单个条目的其他错误只记录日志
+ */
+func (a *Agent) apply(ctx context.Context, changes []*change, remotes map[string]*remoteItem) error {
+	a.remoteIDs = make(map[string]int64)
+	for rel, r := range remotes {
+		if r.Folder {
+			a.remoteIDs[rel] = r.ID
+		}
+	}
+
+	var deletes []*change
+	for _, c := range changes {
+		if c.act == actDeleteLocal || c.act == actDeleteRemote {
+			deletes = append(deletes, c)
+			continue
+		}
+		if err := a.applyOne(ctx, c); err != nil {
+			if err := a.handleError(ctx, c, err); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(deletes) - 1; i >= 0; i-- {
+		if err := a.applyOne(ctx, deletes[i]); err != nil {
+			if err := a.handleError(ctx, deletes[i], err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/**
+ * @description: 处理单个条目的错误，网络错误(如离线)中止本次同步，其他错误跳过该条目
+ * @param {*change} c 同步操作
+ * @param {error} err 错误
+ * @return {*}
+ */
+func (a *Agent) handleError(ctx context.Context, c *change, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", c.rel, err)
+	}
+	a.logf("%s: %v", c.rel, err)
+	return nil
+}
+
+// 下载或删除前本地文件又发生了变化，留到下次同步处理
+var errLocalChanged = errors.New("local file changed during sync, will retry")
+
+func (a *Agent) applyOne(ctx context.Context, c *change) error {
+	switch c.act {
+	case actRecord:
+		return a.record(c.rel, c.local, c.remote, c.rec)
+	case actForget:
+		if c.rec == nil {
+			return nil
+		}
+		return a.state.deleteTree(c.rel)
+	case actUpload:
+		a.logf("upload %s", c.rel)
+		err := a.upload(ctx, c.rel, c.remote)
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionFailed {
+			return err
+		}
+		// 扫描后远程文件又被修改，按两端都有修改处理
+		a.logf("conflict %s", c.rel)
+		file, err := a.client.V2.GetFile(ctx, c.remote.ID)
+		if err != nil {
+			return err
+		}
+		c.remote = &remoteItem{ID: file.ID, Size: file.Size, Updated: file.UpdatedAt}
+		return a.resolveConflict(ctx, c, "")
+	case actDownload:
+		a.logf("download %s", c.rel)
+		return a.download(ctx, c.rel, c.local, c.remote, "")
+	case actMkdirRemote:
+		a.logf("create remote folder %s", c.rel)
+		return a.mkdirRemote(ctx, c.rel)
+	case actMkdirLocal:
+		a.logf("create local folder %s", c.rel)
+		if err := os.MkdirAll(a.localPath(c.rel), 0755); err != nil {
+			return err
+		}
+		return a.record(c.rel, &localItem{Folder: true}, c.remote, nil)
+	case actDeleteRemote:
+		a.logf("delete remote %s", c.rel)
+		return a.deleteRemote(ctx, c.rel, c.remote)
+	case actDeleteLocal:
+		a.logf("delete local %s", c.rel)
+		return a.deleteLocal(c.rel, c.local)
+	case actCompare:
+		return a.compare(ctx, c)
+	case actConflict:
+		a.logf("conflict %s", c.rel)
+		return a.conflict(ctx, c)
+	}
+	return nil
+}
+
+func (a *Agent) localPath(rel string) string {
+	return filepath.Join(a.localRoot, filepath.FromSlash(rel))
+}
+
+/**
+ * @description: 查询相对路径的父文件夹ID
+ * @param {string} rel 相对路径
+ * @return {int64}
+ */
+func (a *Agent) parentID(rel string) (int64, error) {
+	parent := path.Dir(rel)
+	if parent == "." {
+		return a.remoteID, nil
+	}
+	id, ok := a.remoteIDs[parent]
+	if !ok {
+		return 0, &client.Error{Message: "remote parent folder " + parent + " does not exist"}
+	}
+	return id, nil
+}
+
+/**
+ * @description: 两端一致时写入记录
+ * @param {string} rel 相对路径
+ * @param {*localItem} l 本地条目
+ * @param {*remoteItem} r 远程条目
+ * @param {*record} previous 原记录，用于在未重新计算哈希时保留哈希
+ * @return {*}
+ */
+func (a *Agent) record(rel string, l *localItem, r *remoteItem, previous *record) error {
+	rec := &record{
+		Folder:        l.Folder,
+		RemoteID:      r.ID,
+		RemoteSize:    r.Size,
+		RemoteUpdated: r.Updated,
+		LocalSize:     l.Size,
+		LocalModTime:  l.ModTime,
+		Hash:          l.Hash,
+	}
+	if rec.Hash == "" && previous != nil {
+		rec.Hash = previous.Hash
+	}
+	if previous != nil && previous.equal(rec) {
+		return nil
+	}
+	return a.state.put(rel, rec)
+}
+
+/**
+ * @description: 检查本地文件与扫描时是否一致
+ * @param {string} rel 相对路径
+ * @param {*localItem} l 扫描时的本地条目，为nil表示扫描时不存在
+ * @return {*} 不一致时返回errLocalChanged
+ */
+func (a *Agent) checkLocalUnchanged(rel string, l *localItem) error {
+	info, err := os.Stat(a.localPath(rel))
+	if os.IsNotExist(err) && l == nil {
+		return nil
+	} else if err != nil {
+		if os.IsNotExist(err) {
+			return errLocalChanged
+		}
+		return err
+	}
+	if l == nil || l.Folder != info.IsDir() || (!l.Folder && (l.Size != info.Size() || !l.ModTime.Equal(info.ModTime()))) {
+		return errLocalChanged
+	}
+	return nil
+}
+
+/**
+ * @description: 上传本地文件，远程文件存在时只在其与扫描时一致的情况下替换内容
+ * @param {string} rel 相对路径
+ * @param {*remoteItem} r 扫描时的远程文件，为nil时新建文件
+ * @return {*} 远程文件在扫描后被修改时返回状态码为412的*client.Error
+ */
+func (a *Agent) upload(ctx context.Context, rel string, r *remoteItem) error {
+	localFile, err := os.Open(a.localPath(rel))
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+	info, err := localFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 上传的同时计算哈希，记录的是实际上传的内容
+	h := sha256.New()
+	content := io.TeeReader(localFile, h)
+	var file *dto.File
+	if r != nil {
+		file, err = a.client.V2.PutFileContentIfMatch(ctx, r.ID, content, r.etag())
+	} else {
+		var parentID int64
+		if parentID, err = a.parentID(rel); err != nil {
+			return err
+		}
+		file, err = a.client.UploadFile(ctx, parentID, path.Base(rel), content)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.state.put(rel, &record{
+		RemoteID:      file.ID,
+		RemoteSize:    file.Size,
+		RemoteUpdated: file.UpdatedAt,
+		LocalSize:     info.Size(),
+		LocalModTime:  info.ModTime(),
+		Hash:          hex.EncodeToString(h.Sum(nil)),
+	})
+}
+
+/**
+ * @description: 下载远程文件到临时文件
+ * @param {*remoteItem} r 远程条目
+ * @return {string} 临时文件路径，调用者负责删除或移动
+ * @return {string} 内容哈希
+ */
+func (a *Agent) fetch(ctx context.Context, r *remoteItem) (string, string, error) {
+	body, err := a.client.DownloadFile(ctx, r.ID)
+	if err != nil {
+		return "", "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(a.localRoot, stateDirName), "download-*")
+	if err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", err
+	}
+	return tmp.Name(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+/**
+ * @description: 下载远程文件并替换本地文件，本地文件在扫描后又被修改时放弃替换
+ * @param {string} rel 相对路径
+ * @param {*localItem} l 扫描时的本地条目
+ * @param {*remoteItem} r 远程条目
+ * @param {string} tmpPath 已下载的临时文件，为空时重新下载
+ * @return {*}
+ */
+func (a *Agent) download(ctx context.Context, rel string, l *localItem, r *remoteItem, tmpPath string) error {
+	var hash string
+	if tmpPath == "" {
+		var err error
+		if tmpPath, hash, err = a.fetch(ctx, r); err != nil {
+			return err
+		}
+	}
+	defer os.Remove(tmpPath) // 移动成功后临时文件已不存在
+
+	if err := a.checkLocalUnchanged(rel, l); err != nil {
+		return err
+	}
+	localPath := a.localPath(rel)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, localPath); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		if hash, err = hashFile(localPath); err != nil {
+			return err
+		}
+	}
+	return a.state.put(rel, &record{
+		RemoteID:      r.ID,
+		RemoteSize:    r.Size,
+		RemoteUpdated: r.Updated,
+		LocalSize:     info.Size(),
+		LocalModTime:  info.ModTime(),
+		Hash:          hash,
+	})
+}
+
+func (a *Agent) mkdirRemote(ctx context.Context, rel string) error {
+	parentID, err := a.parentID(rel)
+	if err != nil {
+		return err
+	}
+	folder, err := a.client.CreateFolder(ctx, parentID, path.Base(rel))
+	if err != nil {
+		return err
+	}
+	a.remoteIDs[rel] = folder.ID
+	return a.state.put(rel, &record{Folder: true, RemoteID: folder.ID})
+}
+
+/**
+ * @description: 删除远程条目，文件夹只在为空时删除
+ * @param {string} rel 相对路径
+ * @param {*remoteItem} r 远程条目
+ * @return {*}
+ */
+func (a *Agent) deleteRemote(ctx context.Context, rel string, r *remoteItem) error {
+	if !r.Folder {
+		if err := a.client.DeleteFile(ctx, r.ID); err != nil {
+			return err
+		}
+		return a.state.deleteTree(rel)
+	}
+
+	// 扫描后远程文件夹中可能又有了新内容，删除文件夹会连同新内容一起删除
+	listing, err := a.client.QueryFolder(ctx, r.ID, client.ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if listing.Total > 0 {
+		a.logf("%s: remote folder is not empty, keeping it", rel)
+		return nil
+	}
+	if err := a.client.DeleteFolder(ctx, r.ID); err != nil {
+		return err
+	}
+	return a.state.deleteTree(rel)
+}
+
+/**
+ * @description: 删除本地条目，目录只在为空时删除
+ * @param {string} rel 相对路径
+ * @param {*localItem} l 扫描时的本地条目
+ * @return {*}
+ */
+func (a *Agent) deleteLocal(rel string, l *localItem) error {
+	if err := a.checkLocalUnchanged(rel, l); err != nil {
+		return err
+	}
+	if err := os.Remove(a.localPath(rel)); err != nil {
+		if l.Folder {
+			a.logf("%s: local folder is not empty, keeping it", rel)
+			return nil
+		}
+		return err
+	}
+	return a.state.deleteTree(rel)
+}
+
+/**
+ * @description: 两端都有未记录的同名文件时下载比较，内容相同只写入记录，否则按冲突处理
+ * @param {*change} c 同步操作
+ * @return {*}
+ */
+func (a *Agent) compare(ctx context.Context, c *change) error {
+	tmpPath, hash, err := a.fetch(ctx, c.remote)
+	if err != nil {
+		return err
+	}
+	if hash == c.local.Hash {
+		os.Remove(tmpPath)
+		return a.record(c.rel, c.local, c.remote, nil)
+	}
+
+	a.logf("conflict %s", c.rel)
+	return a.resolveConflict(ctx, c, tmpPath)
+}
+
+func (a *Agent) conflict(ctx context.Context, c *change) error {
+	switch {
+	case c.local.Folder && !c.remote.Folder:
+		// 本地是目录而远程是文件：远程文件改名为冲突副本，下次同步时下载
+		name := a.conflictName(path.Base(c.rel))
+		if _, err := a.client.V2.PatchFile(ctx, c.remote.ID, client.PatchRequest{Name: &name}); err != nil {
+			return err
+		}
+		return a.mkdirRemote(ctx, c.rel)
+	case !c.local.Folder && c.remote.Folder:
+		// 本地是文件而远程是目录：本地文件改名为冲突副本并上传
+		conflictRel := path.Join(path.Dir(c.rel), a.conflictName(path.Base(c.rel)))
+		if err := os.Rename(a.localPath(c.rel), a.localPath(conflictRel)); err != nil {
+			return err
+		}
+		if err := os.Mkdir(a.localPath(c.rel), 0755); err != nil {
+			return err
+		}
+		if err := a.record(c.rel, &localItem{Folder: true}, c.remote, nil); err != nil {
+			return err
+		}
+		return a.upload(ctx, conflictRel, nil)
+	}
+	return a.resolveConflict(ctx, c, "")
+}
+
+/**
+ * @description: 两端都修改了同一文件：本地版本改名为冲突副本并上传，原路径使用远程版本
+ * @param {*change} c 同步操作
+ * @param {string} tmpPath 已下载的远程版本，为空时重新下载
+ * @return {*}
+ */
+func (a *Agent) resolveConflict(ctx context.Context, c *change, tmpPath string) error {
+	if tmpPath == "" {
+		var err error
+		if tmpPath, _, err = a.fetch(ctx, c.remote); err != nil {
+			return err
+		}
+	}
+	if err := a.checkLocalUnchanged(c.rel, c.local); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	conflictRel := path.Join(path.Dir(c.rel), a.conflictName(path.Base(c.rel)))
+	if err := os.Rename(a.localPath(c.rel), a.localPath(conflictRel)); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := a.download(ctx, c.rel, nil, c.remote, tmpPath); err != nil {
+		return err
+	}
+	return a.upload(ctx, conflictRel, nil)
+}
+
+/**
+ * @description: 生成冲突副本的名称，如report (conflicted copy host 2026-10-18 153000).pdf
+ * @param {string} name 原名称
+ * @return {string}
+ */
+func (a *Agent) conflictName(name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" { // .bashrc之类的名称没有扩展名
+		base, ext = name, ""
+	}
+	return fmt.Sprintf("%s (conflicted copy %s %s)%s", base, a.hostname, time.Now().Format("2006-01-02 150405"), ext)
+}
@@ -0,0 +1,260 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 16:02:18
+ * @LastEditTime: 2026-10-19 19:34:51
+ * @FilePath: \CloudDisk\syncagent\reconcile_test.go
+ * @Description: 同步操作决策的测试：首次同步、单端和两端修改、删除与修改、文件夹删除与新增子项、中断后继续、冲突副本命名
+ */
+package syncagent
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// 上次同步时远程文件的更新时间
+var syncedAt = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// 上次同步完成时内容为hash的文件记录
+func fileRecord(hash string) *record {
+	return &record{RemoteID: 10, RemoteSize: 100, RemoteUpdated: syncedAt, LocalSize: 100, LocalModTime: syncedAt, Hash: hash}
+}
+
+func folderRecord() *record {
+	return &record{Folder: true, RemoteID: 20}
+}
+
+// 本地文件，hash为空表示大小和修改时间与记录一致而未重新计算哈希
+func localFile(hash string) *localItem {
+	return &localItem{Size: 100, ModTime: syncedAt, Hash: hash}
+}
+
+func localFolder() *localItem {
+	return &localItem{Folder: true}
+}
+
+// 远程文件，updated与记录一致时表示未修改
+func remoteFile(updated time.Time) *remoteItem {
+	return &remoteItem{ID: 10, Size: 100, Updated: updated}
+}
+
+func remoteFolder() *remoteItem {
+	return &remoteItem{Folder: true, ID: 20}
+}
+
+func TestDecide(t *testing.T) {
+	edited := syncedAt.Add(time.Minute)
+	tests := []struct {
+		name   string
+		rec    *record
+		local  *localItem
+		remote *remoteItem
+		want   action
+	}{
+		// 首次同步，没有记录
+		{"first sync local file", nil, localFile("a"), nil, actUpload},
+		{"first sync local folder", nil, localFolder(), nil, actMkdirRemote},
+		{"first sync remote file", nil, nil, remoteFile(syncedAt), actDownload},
+		{"first sync remote folder", nil, nil, remoteFolder(), actMkdirLocal},
+		{"first sync both files", nil, localFile("a"), remoteFile(syncedAt), actCompare},
+		{"first sync both folders", nil, localFolder(), remoteFolder(), actRecord},
+		{"first sync file and folder", nil, localFile("a"), remoteFolder(), actConflict},
+
+		// 已同步过的文件
+		{"unchanged", fileRecord("a"), localFile(""), remoteFile(syncedAt), actRecord},
+		{"touched with same content", fileRecord("a"), localFile("a"), remoteFile(syncedAt), actRecord},
+		{"local edit", fileRecord("a"), localFile("b"), remoteFile(syncedAt), actUpload},
+		{"remote edit", fileRecord("a"), localFile(""), remoteFile(edited), actDownload},
+		{"remote replaced by another file", fileRecord("a"), localFile(""), &remoteItem{ID: 11, Size: 100, Updated: syncedAt}, actDownload},
+		{"both edited", fileRecord("a"), localFile("b"), remoteFile(edited), actConflict},
+		{"local became folder", fileRecord("a"), localFolder(), remoteFile(syncedAt), actConflict},
+
+		// 删除与修改
+		{"local delete", fileRecord("a"), nil, remoteFile(syncedAt), actDeleteRemote},
+		{"local delete remote edit", fileRecord("a"), nil, remoteFile(edited), actDownload},
+		{"remote delete", fileRecord("a"), localFile(""), nil, actDeleteLocal},
+		{"remote delete local edit", fileRecord("a"), localFile("b"), nil, actUpload},
+		{"both deleted", fileRecord("a"), nil, nil, actForget},
+		{"local folder delete", folderRecord(), nil, remoteFolder(), actDeleteRemote},
+		{"remote folder delete", folderRecord(), localFolder(), nil, actDeleteLocal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decide(tt.rec, tt.local, tt.remote); got != tt.want {
+				t.Errorf("decide() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		records map[string]*record
+		locals  map[string]*localItem
+		remotes map[string]*remoteItem
+		want    map[string]action
+	}{
+		{
+			name:    "local folder delete with remote new child keeps folder",
+			records: map[string]*record{"docs": folderRecord(), "docs/a.txt": fileRecord("a")},
+			locals:  map[string]*localItem{},
+			remotes: map[string]*remoteItem{"docs": remoteFolder(), "docs/a.txt": remoteFile(syncedAt), "docs/new.txt": {ID: 30, Size: 1, Updated: syncedAt}},
+			want:    map[string]action{"docs": actMkdirLocal, "docs/a.txt": actDeleteRemote, "docs/new.txt": actDownload},
+		},
+		{
+			name:    "remote folder delete with local new child keeps folder",
+			records: map[string]*record{"docs": folderRecord(), "docs/a.txt": fileRecord("a")},
+			locals:  map[string]*localItem{"docs": localFolder(), "docs/a.txt": localFile(""), "docs/sub": localFolder(), "docs/sub/new.txt": localFile("n")},
+			remotes: map[string]*remoteItem{},
+			want:    map[string]action{"docs": actMkdirRemote, "docs/a.txt": actDeleteLocal, "docs/sub": actMkdirRemote, "docs/sub/new.txt": actUpload},
+		},
+		{
+			name:    "remote folder delete with local edit keeps folder",
+			records: map[string]*record{"docs": folderRecord(), "docs/a.txt": fileRecord("a")},
+			locals:  map[string]*localItem{"docs": localFolder(), "docs/a.txt": localFile("b")},
+			remotes: map[string]*remoteItem{},
+			want:    map[string]action{"docs": actMkdirRemote, "docs/a.txt": actUpload},
+		},
+		{
+			name:    "local folder delete without new content",
+			records: map[string]*record{"docs": folderRecord(), "docs/a.txt": fileRecord("a"), "docs/gone.txt": fileRecord("g")},
+			locals:  map[string]*localItem{},
+			remotes: map[string]*remoteItem{"docs": remoteFolder(), "docs/a.txt": remoteFile(syncedAt)},
+			want:    map[string]action{"docs": actDeleteRemote, "docs/a.txt": actDeleteRemote, "docs/gone.txt": actForget},
+		},
+		{
+			name:    "sibling with a common prefix does not keep folder",
+			records: map[string]*record{"docs": folderRecord()},
+			locals:  map[string]*localItem{"docs-new.txt": localFile("n")},
+			remotes: map[string]*remoteItem{"docs": remoteFolder()},
+			want:    map[string]action{"docs": actDeleteRemote, "docs-new.txt": actUpload},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := plan(tt.records, tt.locals, tt.remotes)
+			if len(changes) != len(tt.want) {
+				t.Fatalf("plan() returned %d changes, want %d", len(changes), len(tt.want))
+			}
+			for _, c := range changes {
+				if want, ok := tt.want[c.rel]; !ok || c.act != want {
+					t.Errorf("%s: action %d, want %d", c.rel, c.act, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanOrder(t *testing.T) {
+	// 父目录排在其下所有条目之前，同一子树连续，新建时父目录先于子项，删除时倒序执行子项先于父目录
+	locals := map[string]*localItem{"a-b": localFolder(), "a": localFolder(), "a/b/c": localFolder(), "a/b": localFolder(), "a.txt": localFile("x")}
+	changes := plan(map[string]*record{}, locals, map[string]*remoteItem{})
+
+	want := []string{"a", "a/b", "a/b/c", "a-b", "a.txt"}
+	for i, c := range changes {
+		if c.rel != want[i] {
+			t.Fatalf("change %d is %s, want order %q", i, c.rel, want)
+		}
+	}
+}
+
+func TestPlanResumeFromState(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, stateDirName), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "uploaded", "b.txt": "downloaded", "c.txt": "pending"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(root, stateDirName, "state.db")
+
+	// 首次同步上传a.txt并写入记录后中断，b.txt已下载到本地但还没写入记录，c.txt还没上传
+	state, err := openState(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploaded := &record{RemoteID: 1, RemoteSize: info.Size(), RemoteUpdated: syncedAt, LocalSize: info.Size(), LocalModTime: info.ModTime(), Hash: hash}
+	if err := state.put("a.txt", uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开状态数据库继续同步
+	state, err = openState(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	records, err := state.all()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := records["a.txt"]; rec == nil || !rec.equal(uploaded) {
+		t.Fatalf("persisted record is %+v, want %+v", rec, uploaded)
+	}
+
+	a := &Agent{localRoot: root}
+	locals, err := a.scanLocal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := locals[stateDirName]; ok {
+		t.Errorf("state directory is scanned")
+	}
+	if locals["a.txt"] == nil || locals["a.txt"].Hash != "" {
+		t.Errorf("recorded file was hashed again: %+v", locals["a.txt"])
+	}
+
+	remotes := map[string]*remoteItem{
+		"a.txt": {ID: 1, Size: info.Size(), Updated: syncedAt},
+		"b.txt": {ID: 2, Size: int64(len("downloaded")), Updated: syncedAt},
+	}
+	want := map[string]action{"a.txt": actRecord, "b.txt": actCompare, "c.txt": actUpload}
+	changes := plan(records, locals, remotes)
+	if len(changes) != len(want) {
+		t.Fatalf("plan() returned %d changes, want %d", len(changes), len(want))
+	}
+	for _, c := range changes {
+		if c.act != want[c.rel] {
+			t.Errorf("%s: action %d, want %d", c.rel, c.act, want[c.rel])
+		}
+	}
+}
+
+func TestConflictName(t *testing.T) {
+	a := &Agent{hostname: "laptop"}
+	stamp := `\(conflicted copy laptop \d{4}-\d{2}-\d{2} \d{6}\)`
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", `^report ` + stamp + `\.pdf$`},
+		{"archive.tar.gz", `^archive\.tar ` + stamp + `\.gz$`},
+		// 以点开头的名称整体作为主名
+		{".bashrc", `^\.bashrc ` + stamp + `$`},
+		{"Makefile", `^Makefile ` + stamp + `$`},
+	}
+	for _, tt := range tests {
+		if got := a.conflictName(tt.name); !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("conflictName(%q) = %q, want match %s", tt.name, got, tt.want)
+		}
+	}
+}
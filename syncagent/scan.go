@@ -0,0 +1,236 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:31:12
+ * @LastEditTime: 2026-10-19 18:40:13
+ * @FilePath: \CloudDisk\syncagent\scan.go
+ * @Description: 扫描本地目录和远程文件夹的当前状态
+ */
+package syncagent
+
+import (
+	"CloudDisk/client"
+	"CloudDisk/dto"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 扫描期间远程文件夹有变化时最多扫描的次数，超过时本次同步失败，下次同步重试
+const maxRemoteScans = 3
+
+var errRemoteBusy = errors.New("remote folder kept changing while it was scanned")
+
+// 本地条目
+type localItem struct {
+	Folder  bool
+	Size    int64
+	ModTime time.Time
+	Hash    string // 只在大小或修改时间与记录不一致时计算
+}
+
+// 远程条目
+type remoteItem struct {
+	Folder  bool
+	ID      int64
+	Size    int64
+	Updated time.Time
+}
+
+// 扫描时远程文件的ETag，上传时用于确认远程文件未被修改
+func (r *remoteItem) etag() string {
+	return (&dto.File{ID: r.ID, Size: r.Size, UpdatedAt: r.Updated}).ETag()
+}
+
+/**
+ * @description: 扫描本地目录，大小或修改时间与记录不一致的文件计算内容哈希
+ * @param {map[string]*record} records 同步记录
+ * @return {map[string]*localItem} 相对路径 -> 本地条目
+ */
+func (a *Agent) scanLocal(records map[string]*record) (map[string]*localItem, error) {
+	items := make(map[string]*localItem)
+	err := filepath.WalkDir(a.localRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == a.localRoot {
+			return nil
+		}
+		if a.isStatePath(p) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(a.localRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			items[rel] = &localItem{Folder: true}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil // 不同步符号链接等特殊文件
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		item := &localItem{Size: info.Size(), ModTime: info.ModTime()}
+		if rec := records[rel]; rec == nil || rec.Folder || rec.LocalSize != item.Size || !rec.LocalModTime.Equal(item.ModTime) {
+			if item.Hash, err = hashFile(p); err != nil {
+				return err
+			}
+		}
+		items[rel] = item
+		return nil
+	})
+	return items, err
+}
+
+/**
+ * @description: 查询远程文件夹下的全部条目
+ * @return {map[string]*remoteItem} 相对路径 -> 远程条目
+ */
+func (a *Agent) scanRemote(ctx context.Context) (map[string]*remoteItem, error) {
+	items := make(map[string]*remoteItem)
+	prefix := strings.TrimSuffix(a.remoteRoot, "/") + "/"
+	for page := 1; ; page++ {
+		result, err := a.client.Search(ctx, client.SearchRequest{
+			RootFolderID: a.remoteID,
+			SortBy:       "path",
+			Page:         page,
+			PageSize:     1000,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range result.Items {
+			rel := strings.TrimPrefix(item.Path, prefix)
+			items[rel] = &remoteItem{
+				Folder:  item.Type == dto.FileTypeFolder,
+				ID:      item.ID,
+				Size:    item.Size,
+				Updated: item.UpdatedAt,
+			}
+		}
+		if len(result.Items) == 0 || int64(page)*1000 >= result.Total {
+			return items, nil
+		}
+	}
+}
+
+/**
+ * @description: 获取远程文件夹的当前状态，变更日志中没有相关变更时复用上次扫描的结果
+ * 扫描按偏移量分页，扫描期间的新建、删除和移动会使条目跨过页边界而被漏掉，漏掉的条目会被当作已删除
+ * 因此扫描后检查扫描前取的游标之后的变更，远程文件夹有变化时重新扫描
+ * @return {map[string]*remoteItem} 相对路径 -> 远程条目
+ */
+func (a *Agent) remoteState(ctx context.Context) (map[string]*remoteItem, error) {
+	if a.remotes != nil && a.cursor != "" {
+		changed, err := a.pollChanges(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			return a.remotes, nil
+		}
+	}
+
+	a.remotes = nil
+	for i := 0; i < maxRemoteScans; i++ {
+		// 扫描前取游标，扫描之后的变更(包括本次同步自己的修改)下次会再次看到
+		cursor := ""
+		result, err := a.client.QueryChanges(ctx, "", 0)
+		var apiErr *client.Error
+		if err == nil {
+			cursor = result.Cursor
+		} else if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			return nil, err
+		}
+
+		remotes, err := a.scanRemote(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// 服务端不支持变更日志时无法确认扫描期间没有变化
+		if cursor == "" {
+			a.remotes, a.cursor = remotes, cursor
+			return remotes, nil
+		}
+		a.cursor = cursor
+		changed, err := a.pollChanges(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			a.remotes = remotes
+			return remotes, nil
+		}
+	}
+	return nil, errRemoteBusy
+}
+
+/**
+ * @description: 读取游标之后的变更，没有相关变更时推进游标
+ * @return {bool} 是否有远程文件夹下的变更
+ */
+func (a *Agent) pollChanges(ctx context.Context) (bool, error) {
+	cursor := a.cursor
+	for {
+		result, err := a.client.QueryChanges(ctx, cursor, 0)
+		if err != nil {
+			return false, err
+		}
+		for _, change := range result.Changes {
+			if a.affectsRemote(change.Path) || (change.OldPath != "" && a.affectsRemote(change.OldPath)) {
+				return true, nil
+			}
+		}
+		cursor = result.Cursor
+		if !result.HasMore {
+			a.cursor = cursor
+			return false, nil
+		}
+	}
+}
+
+/**
+ * @description: 路径的变更是否影响远程文件夹，包括文件夹内的条目和文件夹本身及其上级
+ * @param {string} p 变更的路径
+ * @return {bool}
+ */
+func (a *Agent) affectsRemote(p string) bool {
+	prefix := strings.TrimSuffix(a.remoteRoot, "/") + "/"
+	return p == a.remoteRoot || strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, strings.TrimSuffix(p, "/")+"/")
+}
+
+/**
+ * @description: 计算文件内容的SHA-256
+ * @param {string} p 文件路径
+ * @return {string} 十六进制哈希
+ */
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,143 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-19 18:40:13
+ * @LastEditTime: 2026-10-19 19:34:51
+ * @FilePath: \CloudDisk\syncagent\scan_test.go
+ * @Description: 远程扫描测试：变更是否影响远程文件夹，扫描期间远程文件夹变化时重新扫描
+ */
+package syncagent
+
+import (
+	"CloudDisk/client"
+	"CloudDisk/dto"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAffectsRemote(t *testing.T) {
+	a := &Agent{remoteRoot: "/sync/docs"}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/sync/docs", true},
+		{"/sync/docs/a.txt", true},
+		{"/sync/docs/sub/b.txt", true},
+		// 上级文件夹被移动或删除时远程文件夹随之变化
+		{"/sync", true},
+		{"/", true},
+		// 名称有相同前缀的兄弟条目
+		{"/sync/docs-old", false},
+		{"/sync/docs-old/a.txt", false},
+		{"/sync/other.txt", false},
+		{"/syncx", false},
+	}
+	for _, tt := range tests {
+		if got := a.affectsRemote(tt.path); got != tt.want {
+			t.Errorf("affectsRemote(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// 模拟服务端的搜索和变更日志接口，第n次扫描返回scans[n]，并在扫描时追加changes[n]中的变更
+type fakeRemote struct {
+	mu      sync.Mutex
+	scans   [][]dto.File
+	changes [][]client.Change
+	log     []client.Change
+	scanned int
+}
+
+func (f *fakeRemote) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/search":
+		n := min(f.scanned, len(f.scans)-1)
+		if f.scanned < len(f.changes) {
+			f.log = append(f.log, f.changes[f.scanned]...)
+		}
+		f.scanned++
+		json.NewEncoder(w).Encode(client.SearchResult{Total: int64(len(f.scans[n])), Items: f.scans[n]})
+	case "/api/changes":
+		from, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		result := client.ChangesResult{Changes: []client.Change{}, Cursor: strconv.Itoa(len(f.log))}
+		if r.URL.Query().Has("cursor") {
+			result.Changes = append(result.Changes, f.log[from:]...)
+		}
+		json.NewEncoder(w).Encode(result)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRemoteStateRescansAfterConcurrentChange(t *testing.T) {
+	a1 := dto.File{ID: 1, Name: "a.txt", Type: dto.FileTypeFile, Path: "/sync/a.txt"}
+	b1 := dto.File{ID: 2, Name: "b.txt", Type: dto.FileTypeFile, Path: "/sync/b.txt"}
+	c1 := dto.File{ID: 3, Name: "0.txt", Type: dto.FileTypeFile, Path: "/sync/0.txt"}
+
+	tests := []struct {
+		name    string
+		changes [][]client.Change
+		scans   int
+		want    []string
+	}{
+		// 第一次扫描期间新建的0.txt使b.txt跨过页边界而被漏掉，重新扫描后完整
+		{"change under root", [][]client.Change{{{Action: client.ChangeCreate, Path: "/sync/0.txt"}}}, 2, []string{"0.txt", "a.txt", "b.txt"}},
+		// 其他文件夹的变化不影响扫描结果
+		{"change elsewhere", [][]client.Change{{{Action: client.ChangeCreate, Path: "/other/x.txt"}}}, 1, []string{"a.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := &fakeRemote{scans: [][]dto.File{{a1}, {c1, a1, b1}}, changes: tt.changes}
+			server := httptest.NewServer(remote)
+			defer server.Close()
+
+			a := &Agent{client: client.New(server.URL, "", ""), remoteRoot: "/sync", remoteID: 10}
+			remotes, err := a.remoteState(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if remote.scanned != tt.scans {
+				t.Errorf("scanned %d times, want %d", remote.scanned, tt.scans)
+			}
+			if len(remotes) != len(tt.want) {
+				t.Errorf("got %d items, want %q", len(remotes), tt.want)
+			}
+			for _, rel := range tt.want {
+				if remotes[rel] == nil {
+					t.Errorf("%s is missing", rel)
+				}
+			}
+			if a.cursor != strconv.Itoa(len(remote.log)) {
+				t.Errorf("cursor is %q, want the latest %d", a.cursor, len(remote.log))
+			}
+		})
+	}
+}
+
+func TestRemoteStateGivesUpWhenBusy(t *testing.T) {
+	// 每次扫描期间都有变化时不使用可能不完整的扫描结果
+	change := []client.Change{{Action: client.ChangeUpdate, Path: "/sync/a.txt"}}
+	remote := &fakeRemote{
+		scans:   [][]dto.File{{{ID: 1, Name: "a.txt", Type: dto.FileTypeFile, Path: "/sync/a.txt"}}},
+		changes: [][]client.Change{change, change, change, change},
+	}
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	a := &Agent{client: client.New(server.URL, "", ""), remoteRoot: "/sync", remoteID: 10}
+	if _, err := a.remoteState(context.Background()); err != errRemoteBusy {
+		t.Errorf("got error %v, want %v", err, errRemoteBusy)
+	}
+	if remote.scanned != maxRemoteScans || a.remotes != nil {
+		t.Errorf("scanned %d times and kept %d items", remote.scanned, len(a.remotes))
+	}
+}
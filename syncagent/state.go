@@ -0,0 +1,166 @@
+/*
+ * @Author: shanghanjin
+ * @Date: 2026-10-18 21:31:12
+ * @LastEditTime: 2026-10-18 21:31:12
+ * @FilePath: \CloudDisk\syncagent\state.go
+ * @Description: 同步状态数据库，记录每个条目上次同步完成时本地和远程的状态
+ */
+package syncagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	entriesBucket = []byte("entries") // 相对路径 -> record
+	metaBucket    = []byte("meta")    // 同步配置
+)
+
+// 上次同步完成时的状态，本地和远程在该时刻内容一致
+type record struct {
+	Folder        bool      `json:"folder"`
+	RemoteID      int64     `json:"remoteId"`
+	RemoteSize    int64     `json:"remoteSize"`
+	RemoteUpdated time.Time `json:"remoteUpdated"`
+	LocalSize     int64     `json:"localSize"`
+	LocalModTime  time.Time `json:"localModTime"`
+	Hash          string    `json:"hash"` // 内容的SHA-256，文件夹为空
+}
+
+func (r *record) equal(other *record) bool {
+	return r.Folder == other.Folder && r.RemoteID == other.RemoteID && r.RemoteSize == other.RemoteSize &&
+		r.RemoteUpdated.Equal(other.RemoteUpdated) && r.LocalSize == other.LocalSize &&
+		r.LocalModTime.Equal(other.LocalModTime) && r.Hash == other.Hash
+}
+
+// 同步状态数据库
+type stateDB struct {
+	db *bolt.DB
+}
+
+/**
+ * @description: 打开状态数据库，不存在时创建
+ * @param {string} dbPath 数据库文件路径
+ * @return {*stateDB}
+ */
+func openState(dbPath string) (*stateDB, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{entriesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &stateDB{db: db}, nil
+}
+
+func (s *stateDB) Close() error {
+	return s.db.Close()
+}
+
+/**
+ * @description: 读取全部记录
+ * @return {map[string]*record} 相对路径 -> 记录
+ */
+func (s *stateDB) all() (map[string]*record, error) {
+	records := make(map[string]*record)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(k, v []byte) error {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			records[string(k)] = &rec
+			return nil
+		})
+	})
+	return records, err
+}
+
+/**
+ * @description: 写入一条记录，每次操作完成后立即提交，中断后可以从该状态继续
+ * @param {string} rel 相对路径
+ * @param {*record} rec 记录
+ * @return {*}
+ */
+func (s *stateDB) put(rel string, rec *record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).Put([]byte(rel), data)
+	})
+}
+
+/**
+ * @description: 删除路径及其下所有条目的记录
+ * @param {string} rel 相对路径
+ * @return {*}
+ */
+func (s *stateDB) deleteTree(rel string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(entriesBucket)
+		if err := bucket.Delete([]byte(rel)); err != nil {
+			return err
+		}
+
+		// 同一子树下的键以rel/为前缀，在B+树中连续存放
+		prefix := []byte(rel + "/")
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/**
+ * @description: 清空全部记录
+ * @return {*}
+ */
+func (s *stateDB) reset() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(entriesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(entriesBucket)
+		return err
+	})
+}
+
+/**
+ * @description: 读取配置项
+ * @param {string} key 配置名
+ * @return {string} 不存在时为空
+ */
+func (s *stateDB) getMeta(key string) (string, error) {
+	var value string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket(metaBucket).Get([]byte(key)))
+		return nil
+	})
+	return value, err
+}
+
+func (s *stateDB) setMeta(key string, value string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put([]byte(key), []byte(value))
+	})
+}